@@ -1,14 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	"github.com/SamJohn04/simple-lang-compiler/internal/backend"
 	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/diagnostics"
 	"github.com/SamJohn04/simple-lang-compiler/internal/frontend"
+	"github.com/SamJohn04/simple-lang-compiler/internal/ir"
+	"github.com/SamJohn04/simple-lang-compiler/internal/lint"
+	"github.com/SamJohn04/simple-lang-compiler/internal/lsp"
 )
 
 func main() {
@@ -16,32 +23,39 @@ func main() {
 		fmt.Println("at least 1 argument required")
 		os.Exit(1)
 	}
-	inputFileName := os.Args[1]
-	file, err := os.Open(inputFileName)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	if os.Args[1] == "run" {
+		runProgram()
+		return
+	}
+	if os.Args[1] == "fmt" {
+		os.Exit(formatFiles(os.Args[2:]))
+	}
+	if os.Args[1] == "lint" {
+		os.Exit(lintFiles(os.Args[2:]))
+	}
+	if os.Args[1] == "lsp" {
+		// stdout carries the protocol, so errors go to stderr
+		if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-	defer file.Close()
-
-	// Create an unbuffered channel for lexical tokens
-	lex := make(chan common.Token)
 
-	go frontend.Lexer(file, lex)
-	programRoot, err := frontend.Parser(lex)
+	options, arguments, err := parseOptions(os.Args[1:])
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	loweredProgram, identifiers, err := frontend.SemanticAnalyzer(programRoot)
-	if err != nil {
-		fmt.Println(err)
+	if len(arguments) < 1 {
+		fmt.Println("the file to compile is required")
 		os.Exit(1)
 	}
-	loweredProgram, err = frontend.TypeChecker(loweredProgram, identifiers)
+
+	inputFileName := arguments[0]
+	loweredProgram, identifiers, err := frontendPasses(inputFileName, options.errorLimit)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(reportError(err, options.diagnosticsFormat))
 	}
 
 	intermediateCodes, identifiers, err := backend.IntermediateCodeGenerator(
@@ -49,28 +63,334 @@ func main() {
 		identifiers,
 	)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(reportError(err, options.diagnosticsFormat))
 	}
 
-	cCode, err := backend.CodeGenerator(intermediateCodes, identifiers)
+	intermediateCodes, err = backend.Optimizer(
+		intermediateCodes,
+		identifiers,
+		options.optimization,
+	)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(reportError(err, options.diagnosticsFormat))
+	}
+
+	if options.emit == "cfg-dot" {
+		err = emitControlFlowGraph(arguments[1:], intermediateCodes, identifiers)
+		if err != nil {
+			os.Exit(reportError(err, options.diagnosticsFormat))
+		}
+		return
+	}
+
+	intermediateCodes, identifiers, err = backend.TemporaryAllocator(intermediateCodes, identifiers)
+	if err != nil {
+		os.Exit(reportError(err, options.diagnosticsFormat))
 	}
 
 	// output file name is the input file with the sl removed and 'out' added.
 	// Just in case the file name has no extension, a "." is (potentially) removed and added again
-	// Expects gcc in your system
 	outputFileName := fmt.Sprintf("%v.out", strings.TrimSuffix(inputFileName, ".sl"))
-	if len(os.Args) >= 3 {
-		outputFileName = os.Args[2]
+	if len(arguments) >= 2 {
+		outputFileName = arguments[1]
 	}
+
+	if strings.HasSuffix(outputFileName, ".slbc") {
+		err = toBytecodeFile(outputFileName, intermediateCodes, identifiers)
+		if err != nil {
+			os.Exit(reportError(err, options.diagnosticsFormat))
+		}
+		return
+	}
+
+	cCode, err := backend.CodeGenerator(intermediateCodes, identifiers)
+	if err != nil {
+		os.Exit(reportError(err, options.diagnosticsFormat))
+	}
+
+	// Expects gcc in your system
 	err = toObjectFile(outputFileName, cCode)
 	if err != nil {
-		fmt.Println(err)
+		os.Exit(reportError(err, options.diagnosticsFormat))
+	}
+}
+
+// the exit codes when compilation fails
+const (
+	// the program has mistakes
+	exitCompilationError = 1
+	// the compiler has a mistake
+	exitInternalError = 2
+)
+
+// Shows a compilation failure, with the offending source when it is known.
+// Returns the exit code for the failure.
+func reportError(err error, format string) int {
+	var emitter diagnostics.Emitter = diagnostics.NewRenderer(os.Stderr)
+	if format == "json" {
+		emitter = diagnostics.NewJSONWriter(os.Stderr)
+	}
+	// a runtime error is shown like the others, at the statement that failed if it is known
+	var runtimeError *backend.RuntimeError
+	if errors.As(err, &runtimeError) {
+		err = &common.CompilationError{
+			PointOfFailure: "Runtime",
+			Message:        "runtime error: " + runtimeError.Message,
+			Span:           runtimeError.Span,
+		}
+	}
+	emitter.RenderError(err)
+
+	var internalError *common.InternalError
+	if errors.As(err, &internalError) {
+		return exitInternalError
+	}
+	return exitCompilationError
+}
+
+type options struct {
+	// what to output instead of an executable, e.g., cfg-dot
+	emit string
+	// 0 for no optimizations
+	optimization int
+	// the number of syntax errors after which parsing stops, 0 for no limit
+	errorLimit int
+	// human or json
+	diagnosticsFormat string
+}
+
+const defaultErrorLimit = 20
+
+// separates the options, which start with "-", from the file names
+func parseOptions(args []string) (options, []string, error) {
+	parsed := options{errorLimit: defaultErrorLimit, diagnosticsFormat: "human"}
+	arguments := []string{}
+
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--emit="):
+			parsed.emit = strings.TrimPrefix(arg, "--emit=")
+			if parsed.emit != "cfg-dot" {
+				return options{}, nil, fmt.Errorf("unknown value for --emit: %v", parsed.emit)
+			}
+		case arg == "-O":
+			parsed.optimization = 1
+		case arg == "-O0" || arg == "-O1":
+			parsed.optimization = int(arg[2] - '0')
+		case strings.HasPrefix(arg, "--diagnostics-format="):
+			parsed.diagnosticsFormat = strings.TrimPrefix(arg, "--diagnostics-format=")
+			if parsed.diagnosticsFormat != "human" && parsed.diagnosticsFormat != "json" {
+				return options{}, nil, fmt.Errorf(
+					"unknown value for --diagnostics-format: %v", parsed.diagnosticsFormat,
+				)
+			}
+		case strings.HasPrefix(arg, "--error-limit="):
+			limit, err := strconv.Atoi(strings.TrimPrefix(arg, "--error-limit="))
+			if err != nil || limit < 0 {
+				return options{}, nil, fmt.Errorf("invalid value for --error-limit: %v", arg)
+			}
+			parsed.errorLimit = limit
+		case strings.HasPrefix(arg, "-"):
+			return options{}, nil, fmt.Errorf("unknown option %v", arg)
+		default:
+			arguments = append(arguments, arg)
+		}
+	}
+	return parsed, arguments, nil
+}
+
+// `run <input.sl>` interprets the program directly, without needing gcc
+// `run <input.slbc>` runs the bytecode on the virtual machine
+func runProgram() {
+	options, arguments, err := parseOptions(os.Args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	if len(arguments) < 1 {
+		fmt.Fprintln(os.Stderr, "run requires the file to run")
+		os.Exit(1)
+	}
+	if strings.HasSuffix(arguments[0], ".slbc") {
+		err := runBytecode(arguments[0])
+		if err != nil {
+			os.Exit(reportError(err, options.diagnosticsFormat))
+		}
+		return
+	}
+
+	loweredProgram, identifiers, err := frontendPasses(arguments[0], options.errorLimit)
+	if err != nil {
+		os.Exit(reportError(err, options.diagnosticsFormat))
+	}
+	err = backend.Interpreter(loweredProgram, identifiers, os.Stdin, os.Stdout)
+	if err != nil {
+		os.Exit(reportError(err, options.diagnosticsFormat))
+	}
+}
+
+// `fmt <files>` prints the files formatted, or formats stdin if there are none
+// `fmt --write <files>` formats the files in place
+// `fmt --check <files>` lists the files that are not formatted, failing if there are any
+// Returns the exit code.
+func formatFiles(args []string) int {
+	mode := "print"
+	fileNames := []string{}
+	for _, arg := range args {
+		switch {
+		case arg == "--check" || arg == "--write":
+			mode = strings.TrimPrefix(arg, "--")
+		case strings.HasPrefix(arg, "-"):
+			fmt.Printf("unknown option %v\n", arg)
+			return exitCompilationError
+		default:
+			fileNames = append(fileNames, arg)
+		}
+	}
+	if len(fileNames) == 0 {
+		if mode != "print" {
+			fmt.Printf("--%v requires the files to format\n", mode)
+			return exitCompilationError
+		}
+		formatted, err := frontend.Formatter(os.Stdin, "<stdin>")
+		if err != nil {
+			return reportError(err, "human")
+		}
+		os.Stdout.WriteString(formatted)
+		return 0
+	}
+
+	exitCode := 0
+	for _, fileName := range fileNames {
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			fmt.Println(err)
+			exitCode = exitCompilationError
+			continue
+		}
+		formatted, err := frontend.Formatter(bytes.NewReader(content), fileName)
+		if err != nil {
+			exitCode = max(exitCode, reportError(err, "human"))
+			continue
+		}
+
+		switch mode {
+		case "print":
+			os.Stdout.WriteString(formatted)
+		case "check":
+			if formatted != string(content) {
+				fmt.Println(fileName)
+				exitCode = max(exitCode, exitCompilationError)
+			}
+		case "write":
+			if formatted == string(content) {
+				continue
+			}
+			if err := os.WriteFile(fileName, []byte(formatted), 0644); err != nil {
+				fmt.Println(err)
+				exitCode = max(exitCode, exitCompilationError)
+			}
+		}
+	}
+	return exitCode
+}
+
+// `lint <files>` warns about code that compiles but is likely a mistake
+// `lint --list` lists the rules
+// The rules are enabled by default, and can be disabled and enabled again with
+// --config=<file>, --disable=<rule,...> and --enable=<rule,...>, applied in that order.
+// Returns the exit code, which is 1 if there are any warnings.
+func lintFiles(args []string) int {
+	config := lint.DefaultConfig()
+	configFileName := ""
+	changes := []string{}
+	fileNames := []string{}
+	for _, arg := range args {
+		switch {
+		case arg == "--list":
+			for _, rule := range lint.Rules {
+				fmt.Printf("%-24v %v\n", rule.ID, rule.Description)
+			}
+			return 0
+		case strings.HasPrefix(arg, "--config="):
+			configFileName = strings.TrimPrefix(arg, "--config=")
+		case strings.HasPrefix(arg, "--disable="), strings.HasPrefix(arg, "--enable="):
+			changes = append(changes, arg)
+		case strings.HasPrefix(arg, "-"):
+			fmt.Printf("unknown option %v\n", arg)
+			return exitCompilationError
+		default:
+			fileNames = append(fileNames, arg)
+		}
+	}
+	if len(fileNames) == 0 {
+		fmt.Println("lint requires the files to lint")
+		return exitCompilationError
+	}
+
+	if configFileName != "" {
+		if err := config.ReadFile(configFileName); err != nil {
+			fmt.Println(err)
+			return exitCompilationError
+		}
+	}
+	for _, change := range changes {
+		option, ids, _ := strings.Cut(change, "=")
+		for _, id := range strings.Split(ids, ",") {
+			if err := config.Set(strings.TrimSpace(id), option == "--enable"); err != nil {
+				fmt.Println(err)
+				return exitCompilationError
+			}
+		}
+	}
+
+	renderer := diagnostics.NewRenderer(os.Stderr)
+	exitCode := 0
+	for _, fileName := range fileNames {
+		loweredProgram, identifiers, err := frontendPasses(fileName, defaultErrorLimit)
+		if err != nil {
+			exitCode = max(exitCode, reportError(err, "human"))
+			continue
+		}
+		for _, warning := range lint.Linter(loweredProgram, identifiers, config) {
+			renderer.Render(warning)
+			exitCode = max(exitCode, exitCompilationError)
+		}
+	}
+	return exitCode
+}
+
+// Lexes, parses, lowers and type checks the input file.
+// The instructions without syntax errors are still checked, so that their errors are reported alongside.
+func frontendPasses(inputFileName string, errorLimit int) (
+	common.ProgramAST,
+	[]common.IdentifierInformation,
+	error,
+) {
+	file, err := os.Open(inputFileName)
+	if err != nil {
+		return common.ProgramAST{}, nil, err
+	}
+	defer file.Close()
+
+	// Create an unbuffered channel for lexical tokens
+	lex := make(chan common.Token)
+
+	go frontend.Lexer(file, inputFileName, lex)
+	programRoot, syntaxErr := frontend.Parser(lex, errorLimit)
+	loweredProgram, identifiers, err := frontend.SemanticAnalyzer(programRoot)
+	if errors.Is(err, frontend.ErrSkippedDeclaration) {
+		// the syntax error that left out the declaration is reported instead
+		return common.ProgramAST{}, nil, syntaxErr
+	}
+	if err != nil {
+		return common.ProgramAST{}, nil, errors.Join(syntaxErr, err)
+	}
+	loweredProgram, err = frontend.TypeChecker(loweredProgram, identifiers)
+	if err != nil || syntaxErr != nil {
+		return common.ProgramAST{}, nil, errors.Join(syntaxErr, err)
+	}
+	return loweredProgram, identifiers, nil
 }
 
 func toObjectFile(outputFileName, cCode string) error {
@@ -96,3 +416,59 @@ func toObjectFile(outputFileName, cCode string) error {
 	}
 	return nil
 }
+
+func toBytecodeFile(
+	outputFileName string,
+	intermediateCodes []ir.Instruction,
+	identifiers []common.IdentifierInformation,
+) error {
+	bytecode, err := backend.BytecodeGenerator(intermediateCodes, identifiers)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create bytecode file: %w", err)
+	}
+	if err := backend.WriteBytecode(file, bytecode); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write bytecode file: %w", err)
+	}
+	return file.Close()
+}
+
+func runBytecode(inputFileName string) error {
+	file, err := os.Open(inputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	bytecode, err := backend.ReadBytecode(file)
+	if err != nil {
+		return err
+	}
+	return backend.VirtualMachine(bytecode, os.Stdin, os.Stdout)
+}
+
+// writes the control flow graph as Graphviz DOT, to the output file if one is given and to stdout otherwise
+func emitControlFlowGraph(
+	outputFileNames []string, intermediateCodes []ir.Instruction, identifiers []common.IdentifierInformation,
+) error {
+	graphs, err := backend.ControlFlowGraphs(intermediateCodes)
+	if err != nil {
+		return err
+	}
+	dot := backend.ControlFlowGraphDot(graphs, identifiers)
+
+	if len(outputFileNames) == 0 {
+		_, err = os.Stdout.WriteString(dot)
+		return err
+	}
+	err = os.WriteFile(outputFileNames[0], []byte(dot), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write dot file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package backend
+
+import (
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/ir"
+)
+
+// Temporaries of the same datatype that are never live at the same time share one identifier.
+// The temporaries that are no longer used are dropped, and the identifiers are renumbered,
+// so that the code generators only declare what the code needs.
+func TemporaryAllocator(
+	input []ir.Instruction,
+	identifiers []common.IdentifierInformation,
+) ([]ir.Instruction, []common.IdentifierInformation, error) {
+	graphs, err := ControlFlowGraphs(input)
+	if err != nil {
+		return nil, identifiers, err
+	}
+
+	shared := map[ir.Identifier]ir.Identifier{}
+	for _, graph := range graphs {
+		for temporary, replacement := range allocateTemporaries(graph, identifiers) {
+			shared[temporary] = replacement
+		}
+	}
+	rename := func(identifier ir.Identifier) ir.Identifier {
+		if replacement, ok := shared[identifier]; ok {
+			return replacement
+		}
+		return identifier
+	}
+	codes := renameAll(instructionsFromGraphs(graphs), rename)
+
+	// the temporaries that were merged into others, or are never used at all, are left out of the table
+	used := map[ir.Identifier]bool{}
+	for _, code := range codes {
+		if _, ok := code.(ir.Local); ok {
+			continue
+		}
+		renameIdentifiers(code, func(identifier ir.Identifier) ir.Identifier {
+			used[identifier] = true
+			return identifier
+		})
+	}
+	renumbered := map[ir.Identifier]ir.Identifier{}
+	newIdentifiers := []common.IdentifierInformation{}
+	for index, information := range identifiers {
+		identifier := ir.Identifier(index)
+		if information.Temporary && !used[identifier] {
+			continue
+		}
+		renumbered[identifier] = ir.Identifier(len(newIdentifiers))
+		if information.Temporary {
+			// since name is the same as identifier code
+			information.IdentifierName = renumbered[identifier].String()
+		}
+		newIdentifiers = append(newIdentifiers, information)
+	}
+
+	codes = renameAll(dropUnusedLocals(codes, renumbered), func(identifier ir.Identifier) ir.Identifier {
+		return renumbered[identifier]
+	})
+	return codes, newIdentifiers, nil
+}
+
+// Colours the interference graph of the temporaries of one control flow graph, one temporary at a time.
+// Returns the identifier that each temporary is replaced with, if it is replaced.
+func allocateTemporaries(
+	graph ControlFlowGraph,
+	identifiers []common.IdentifierInformation,
+) map[ir.Identifier]ir.Identifier {
+	interferences := map[ir.Identifier]map[ir.Identifier]bool{}
+	interfere := func(first, second ir.Identifier) {
+		if first == second {
+			return
+		}
+		if interferences[first] == nil {
+			interferences[first] = map[ir.Identifier]bool{}
+		}
+		if interferences[second] == nil {
+			interferences[second] = map[ir.Identifier]bool{}
+		}
+		interferences[first][second] = true
+		interferences[second][first] = true
+	}
+
+	// the temporaries in the order they first appear in
+	temporaries := []ir.Identifier{}
+	seen := map[ir.Identifier]bool{}
+
+	// an identifier that is written to cannot share with anything that is still live afterwards
+	analysis := livenessAnalysis(graph, identifiers)
+	for index, block := range graph.Blocks {
+		live := copyLiveSet(analysis.out[index])
+		for position := len(block.Instructions) - 1; position >= 0; position-- {
+			code := block.Instructions[position]
+			for _, destination := range overwrittenIdentifiers(code, identifiers) {
+				for identifier := range live {
+					interfere(destination, identifier)
+				}
+			}
+			updateLiveSet(live, code, identifiers)
+		}
+		for _, code := range block.Instructions {
+			renameIdentifiers(code, func(identifier ir.Identifier) ir.Identifier {
+				if identifiers[identifier].Temporary && !seen[identifier] {
+					seen[identifier] = true
+					temporaries = append(temporaries, identifier)
+				}
+				return identifier
+			})
+		}
+	}
+
+	// every temporary joins the first earlier one of its datatype it can share with,
+	// that is, the first whose group has none of the temporaries it interferes with
+	replacements := map[ir.Identifier]ir.Identifier{}
+	kept := map[string][]ir.Identifier{}
+	for _, temporary := range temporaries {
+		datatype := identifiers[temporary].Datatype
+		if datatype == nil {
+			continue
+		}
+		taken := map[ir.Identifier]bool{}
+		for neighbour := range interferences[temporary] {
+			if replacement, ok := replacements[neighbour]; ok {
+				taken[replacement] = true
+			} else {
+				taken[neighbour] = true
+			}
+		}
+
+		name := common.DatatypeName(datatype)
+		replaced := false
+		for _, candidate := range kept[name] {
+			if taken[candidate] || !datatype.IsDatatype(identifiers[candidate].Datatype) {
+				continue
+			}
+			replacements[temporary] = candidate
+			replaced = true
+			break
+		}
+		if !replaced {
+			kept[name] = append(kept[name], temporary)
+		}
+	}
+	return replacements
+}
+
+func renameAll(codes []ir.Instruction, rename func(ir.Identifier) ir.Identifier) []ir.Instruction {
+	result := make([]ir.Instruction, 0, len(codes))
+	for _, code := range codes {
+		result = append(result, renameIdentifiers(code, rename))
+	}
+	return result
+}
+
+// returns the instruction with every identifier in it renamed
+func renameIdentifiers(code ir.Instruction, rename func(ir.Identifier) ir.Identifier) ir.Instruction {
+	operand := func(operand ir.Operand) ir.Operand {
+		if identifier, ok := operand.(ir.Identifier); ok {
+			return rename(identifier)
+		}
+		return operand
+	}
+
+	switch code := code.(type) {
+	case ir.Assign:
+		code.Destination = rename(code.Destination)
+		code.Source = operand(code.Source)
+		return code
+
+	case ir.BinOp:
+		code.Destination = rename(code.Destination)
+		code.First = operand(code.First)
+		code.Second = operand(code.Second)
+		return code
+
+	case ir.UnOp:
+		code.Destination = rename(code.Destination)
+		code.Operand = operand(code.Operand)
+		return code
+
+	case ir.IndexLoad:
+		code.Destination = rename(code.Destination)
+		code.Array = rename(code.Array)
+		code.Index = operand(code.Index)
+		return code
+
+	case ir.IndexStore:
+		code.Array = rename(code.Array)
+		code.Index = operand(code.Index)
+		code.Value = operand(code.Value)
+		return code
+
+	case ir.CondJump:
+		code.Condition = operand(code.Condition)
+		return code
+
+	case ir.Param:
+		code.Value = operand(code.Value)
+		return code
+
+	case ir.Call:
+		if code.Result != ir.NoIdentifier {
+			code.Result = rename(code.Result)
+		}
+		code.Function = operand(code.Function)
+		return code
+
+	case ir.Return:
+		if code.Value != nil {
+			code.Value = operand(code.Value)
+		}
+		return code
+
+	case ir.FunctionStart:
+		parameters := make([]ir.Identifier, 0, len(code.Parameters))
+		for _, parameter := range code.Parameters {
+			parameters = append(parameters, rename(parameter))
+		}
+		code.Function = rename(code.Function)
+		code.Parameters = parameters
+		return code
+
+	case ir.Local:
+		code.Identifier = rename(code.Identifier)
+		return code
+	}
+	return code
+}
+
+// Removes the locals that are no longer in the table,
+// and the repeated ones of the temporaries that now share an identifier.
+func dropUnusedLocals(codes []ir.Instruction, kept map[ir.Identifier]ir.Identifier) []ir.Instruction {
+	result := []ir.Instruction{}
+	declared := map[ir.Identifier]bool{}
+	for _, code := range codes {
+		if local, ok := code.(ir.Local); ok {
+			if _, ok := kept[local.Identifier]; !ok || declared[local.Identifier] {
+				continue
+			}
+			declared[local.Identifier] = true
+		}
+		result = append(result, code)
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTemporariesShared(t *testing.T) {
+	source := strings.Builder{}
+	source.WriteString("let mut total = 0;\nlet mut ratio = 0.0;\n")
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&source, "total = total + (%v * 2 - 1) %% 7;\n", i)
+		fmt.Fprintf(&source, "ratio = ratio + %v as float / 2.0;\n", i%5)
+	}
+	source.WriteString("println(total, ratio);\n")
+
+	codes, identifiers := compileSource(t, source.String(), 0)
+	temporaries := 0
+	for _, information := range identifiers {
+		if information.Temporary {
+			temporaries++
+		}
+	}
+	// each statement needs a few temporaries, none of which are live across statements
+	if temporaries > 10 {
+		t.Errorf("%v temporaries are left, want at most 10", temporaries)
+	}
+
+	bytecode, err := BytecodeGenerator(codes, identifiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output strings.Builder
+	if err := VirtualMachine(bytecode, strings.NewReader(""), &output); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "8996 3000\n" {
+		t.Errorf("printed %q, want %q", output.String(), "8996 3000\n")
+	}
+}
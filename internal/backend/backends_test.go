@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Runs the source, which must compile, with the interpreter, and at -O0 and -O1 with the virtual machine and gcc,
+// and checks that each prints the output. The C backend is left out when gcc is not installed.
+func expectOutput(t *testing.T, source string, input string, output string) {
+	t.Helper()
+	program, identifiers, err := checkSource(source)
+	if err != nil {
+		t.Fatalf("compiling: %v", err)
+	}
+	var printed strings.Builder
+	if err := Interpreter(program, identifiers, strings.NewReader(input), &printed); err != nil {
+		t.Errorf("interpreter: %v", err)
+	}
+	if printed.String() != output {
+		t.Errorf("interpreter printed %q, want %q", printed.String(), output)
+	}
+
+	gcc, gccErr := exec.LookPath("gcc")
+	for optimization := 0; optimization <= 1; optimization++ {
+		codes, identifiers := compileSource(t, source, optimization)
+
+		bytecode, err := BytecodeGenerator(codes, identifiers)
+		if err != nil {
+			t.Fatalf("-O%v: generating the bytecode: %v", optimization, err)
+		}
+		printed.Reset()
+		if err := VirtualMachine(bytecode, strings.NewReader(input), &printed); err != nil {
+			t.Errorf("-O%v: virtual machine: %v", optimization, err)
+		}
+		if printed.String() != output {
+			t.Errorf("-O%v: virtual machine printed %q, want %q", optimization, printed.String(), output)
+		}
+
+		if gccErr != nil {
+			continue
+		}
+		cCode, err := CodeGenerator(codes, identifiers)
+		if err != nil {
+			t.Fatalf("-O%v: generating the C code: %v", optimization, err)
+		}
+		directory := t.TempDir()
+		cFile := filepath.Join(directory, "program.c")
+		if err := os.WriteFile(cFile, []byte(cCode), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		executable := filepath.Join(directory, "program")
+		if message, err := exec.Command(gcc, "-Werror", cFile, "-o", executable).CombinedOutput(); err != nil {
+			t.Fatalf("-O%v: gcc: %v\n%s", optimization, err, message)
+		}
+		command := exec.Command(executable)
+		command.Stdin = strings.NewReader(input)
+		executed, err := command.Output()
+		if err != nil {
+			t.Errorf("-O%v: running the executable: %v", optimization, err)
+		}
+		if string(executed) != output {
+			t.Errorf("-O%v: the executable printed %q, want %q", optimization, executed, output)
+		}
+	}
+}
+
+func TestLargeIntLiterals(t *testing.T) {
+	expectOutput(t, `fn same(x: u64) -> u64 {
+    return x;
+};
+fn largest() -> u64 {
+    return 18446744073709551615;
+};
+let x: u64 = 18446744073709551615;
+let mut y: u64 = 0;
+y = 9223372036854775808;
+let values = [1u64, 18446744073709551615];
+println(x, y, same(18446744073709551615), largest(), values);
+println(x == 18446744073709551615, 18446744073709551615 - x);
+let smallest = -9223372036854775808;
+println(smallest, smallest + 1, 9223372036854775807);
+`, "", "18446744073709551615 9223372036854775808 18446744073709551615 18446744073709551615 [1, 18446744073709551615]\n"+
+		"true 0\n-9223372036854775808 -9223372036854775807 9223372036854775807\n")
+}
+
+func TestIntLiteralOutOfRange(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{"let x = 9223372036854775808;", "9223372036854775808 does not fit in int"},
+		{"let x = -9223372036854775809;", "-9223372036854775809 does not fit in int"},
+		{"let x = 18446744073709551615;", "18446744073709551615 does not fit in int"},
+		{"println(9223372036854775808);", "9223372036854775808 does not fit in int"},
+		{"let x = 9223372036854775808 as u64;", "9223372036854775808 does not fit in int"},
+		{"let x: u64 = 18446744073709551616;", "18446744073709551616 does not fit in u64"},
+		{"let x: u32 = 18446744073709551615;", "18446744073709551615 does not fit in u32"},
+		{"let x: i64 = 9223372036854775808;", "9223372036854775808 does not fit in i64"},
+	}
+	for _, test := range tests {
+		_, _, err := checkSource(test.source)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%q: got %v, want an error with %q", test.source, err, test.message)
+		}
+	}
+}
+
+func TestArrayDeclaredWithoutValue(t *testing.T) {
+	expectOutput(t, `fn count(n: int) -> int {
+    let mut seen: [int; 1];
+    seen[0] = seen[0] + n;
+    return seen[0];
+};
+for i in 0..3 {
+    let mut buf: [char; 2];
+    println(buf[0] == '\0', count(i));
+    buf[0] = 'a';
+};
+let grid: [[int; 3]; 2];
+let flags: [bool; 2];
+println(grid, flags);
+`, "", "true 0\ntrue 1\ntrue 2\n[[0, 0, 0], [0, 0, 0]] [false, false]\n")
+}
+
+func TestImmutableDeclarationWithoutValue(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{"let x: int;", "x needs a value where it is declared, since it is not mut"},
+		{"let grid: [int; 2];\ngrid[0] = 1;", "not declared as mutable"},
+	}
+	for _, test := range tests {
+		_, _, err := checkSource(test.source)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%q: got %v, want an error with %q", test.source, err, test.message)
+		}
+	}
+}
+
+func TestRangeUpToIntLimits(t *testing.T) {
+	expectOutput(t, `for i in 9223372036854775806..=9223372036854775807 {
+    println(i);
+};
+for i in -9223372036854775807..=-9223372036854775807 - 1 step -1 {
+    println(i);
+};
+for i in 9223372036854775800..=9223372036854775807 step 5 {
+    println(i);
+};
+for i in 9223372036854775800..9223372036854775807 step 4 {
+    println(i);
+};
+`, "", "9223372036854775806\n9223372036854775807\n-9223372036854775807\n-9223372036854775808\n"+
+		"9223372036854775800\n9223372036854775805\n9223372036854775800\n9223372036854775804\n")
+}
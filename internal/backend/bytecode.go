@@ -0,0 +1,840 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/ir"
+)
+
+// The bytecode is the 3-address code, encoded for the virtual machine.
+// Every identifier becomes a register, and every literal a constant.
+//
+// An .slbc file is laid out as, with all integers in little endian:
+//   - the magic "SLBC" and the version as a uint16
+//   - the number of registers as a uint32, and for each, its kind as a byte and
+//     its length (the number of elements for an array, else 0) as a uint32
+//   - the number of constants as a uint32, and for each, its kind as a byte followed by
+//     an int64 for int, a float64 for float, a byte for char and bool,
+//     or the length as a uint32 and the bytes for a string
+//   - the number of functions as a uint32, and for each, its register, its first instruction,
+//     the number of parameters followed by their registers,
+//     and the number of locals followed by their registers, all as uint32
+//   - the number of instructions as a uint32, and for each,
+//     its opcode as a byte followed by three operands as uint32
+//
+// The program starts at the first instruction.
+type Bytecode struct {
+	registers    []register
+	constants    []any
+	functions    []bytecodeFunction
+	instructions []instruction
+}
+
+const (
+	bytecodeMagic   = "SLBC"
+	bytecodeVersion = 2
+	// the most elements that the arrays of a program can have between them,
+	// since the virtual machine makes every one of them up front
+	maxArrayElements = 1 << 24
+)
+
+type valueKind byte
+
+const (
+	kindUnknown valueKind = iota
+	kindInt
+	kindFloat
+	kindChar
+	kindBool
+	kindString
+	kindFunction
+
+	// the sized numbers, whose values wrap around to the size of the register
+	kindI8
+	kindI16
+	kindI32
+	kindI64
+	kindU8
+	kindU16
+	kindU32
+	kindU64
+	kindF32
+)
+
+var sizedKinds = map[valueKind]common.PrimitiveDatatype{
+	kindI8:  common.TypedI8,
+	kindI16: common.TypedI16,
+	kindI32: common.TypedI32,
+	kindI64: common.TypedI64,
+	kindU8:  common.TypedU8,
+	kindU16: common.TypedU16,
+	kindU32: common.TypedU32,
+	kindU64: common.TypedU64,
+	kindF32: common.TypedF32,
+}
+
+type register struct {
+	kind valueKind
+	// the number of elements for an array, else 0
+	length int
+}
+
+type bytecodeFunction struct {
+	register   int
+	entry      int
+	parameters []int
+	locals     []int
+}
+
+type opcode byte
+
+const (
+	// stops the program
+	opHalt opcode = iota
+
+	// a = b
+	opMove
+	// a = b [] c, where a is an array; copies as many elements as a holds
+	opCopy
+	// a = b [] c
+	opLoad
+	// a [] b = c
+	opStore
+
+	// a = op b
+	opNegate
+	opNot
+
+	// a = b op c
+	opAdd
+	opSubtract
+	opMultiply
+	opDivide
+	opModulo
+	opEquals
+	opNotEquals
+	opGreaterThan
+	opGreaterThanOrEquals
+	opLesserThan
+	opLesserThanOrEquals
+
+	// goto a
+	opJump
+	// if a goto b
+	opJumpIf
+
+	// param a
+	opParam
+	// a = call b, with c parameters; a is noOperand when nothing is returned
+	opCall
+	// return a; a is noOperand when nothing is returned
+	opReturn
+	// a = call getchar
+	opGetchar
+	// call printf, with a parameters
+	opPrintf
+	// call print, with a parameters
+	opPrint
+	// a = call len, with 1 parameter
+	opLength
+)
+
+// an operand is the index of a register,
+// or the index of a constant with the constantOperand bit set
+const (
+	constantOperand uint32 = 1 << 31
+	noOperand       uint32 = math.MaxUint32
+)
+
+type instruction struct {
+	opcode   opcode
+	operands [3]uint32
+}
+
+var opcodeWithBinaryOperator = map[ir.Operator]opcode{
+	ir.Add:                opAdd,
+	ir.Subtract:           opSubtract,
+	ir.Multiply:           opMultiply,
+	ir.Divide:             opDivide,
+	ir.Modulo:             opModulo,
+	ir.Equals:             opEquals,
+	ir.NotEquals:          opNotEquals,
+	ir.GreaterThan:        opGreaterThan,
+	ir.GreaterThanOrEqual: opGreaterThanOrEquals,
+	ir.LesserThan:         opLesserThan,
+	ir.LesserThanOrEqual:  opLesserThanOrEquals,
+}
+
+var binaryOperatorWithOpcode = map[opcode]common.BinaryOperatorNode{
+	opAdd:                 common.BinaryPlus,
+	opSubtract:            common.BinaryMinus,
+	opMultiply:            common.BinaryMul,
+	opDivide:              common.BinaryDiv,
+	opModulo:              common.BinaryModulo,
+	opEquals:              common.BinaryRelationalEquals,
+	opNotEquals:           common.BinaryRelationalNotEquals,
+	opGreaterThan:         common.BinaryRelationalGreaterThan,
+	opGreaterThanOrEquals: common.BinaryRelationalGreaterThanOrEquals,
+	opLesserThan:          common.BinaryRelationalLesserThan,
+	opLesserThanOrEquals:  common.BinaryRelationalLesserThanOrEquals,
+}
+
+// we are compiling to bytecode
+func BytecodeGenerator(
+	input []ir.Instruction,
+	identifiers []common.IdentifierInformation,
+) (Bytecode, error) {
+	functions, mainCodes, err := splitFunctions(input)
+	if err != nil {
+		return Bytecode{}, err
+	}
+
+	generator := bytecodeGenerator{
+		program: Bytecode{
+			registers:    []register{},
+			constants:    []any{},
+			functions:    []bytecodeFunction{},
+			instructions: []instruction{},
+		},
+		constants: map[ir.Literal]uint32{},
+		labels:    map[string]int{},
+		jumps:     []pendingJump{},
+	}
+
+	elements := 0
+	for _, information := range identifiers {
+		kind, length := registerKind(information.Datatype)
+		generator.program.registers = append(generator.program.registers, register{
+			kind:   kind,
+			length: length,
+		})
+		elements += length
+		if elements > maxArrayElements {
+			return Bytecode{}, &common.CompilationError{
+				PointOfFailure: "Bytecode Generator",
+				Message: fmt.Sprintf(
+					"the arrays have more than the %v elements the virtual machine holds",
+					maxArrayElements,
+				),
+				Span: information.Declaration,
+				Code: common.CodeInvalidArrayLength,
+			}
+		}
+	}
+
+	err = generator.generateCodes(mainCodes)
+	if err != nil {
+		return Bytecode{}, err
+	}
+	generator.emit(opHalt, 0, 0, 0)
+
+	for _, function := range functions {
+		generator.program.functions = append(generator.program.functions, bytecodeFunction{
+			register:   function.identifier,
+			entry:      len(generator.program.instructions),
+			parameters: function.parameters,
+			locals:     function.locals,
+		})
+		err = generator.generateCodes(function.codes)
+		if err != nil {
+			return Bytecode{}, err
+		}
+		// for void functions that reach their end
+		generator.emit(opReturn, noOperand, 0, 0)
+	}
+
+	for _, jump := range generator.jumps {
+		target, ok := generator.labels[jump.label]
+		if !ok {
+			return Bytecode{}, bytecodeGeneratorError("jump to an unknown label " + jump.label)
+		}
+		generator.program.instructions[jump.instruction].operands[jump.operand] = uint32(target)
+	}
+	return generator.program, nil
+}
+
+type bytecodeGenerator struct {
+	program Bytecode
+
+	// the same literal is only stored once
+	constants map[ir.Literal]uint32
+	labels    map[string]int
+	// jumps are resolved once every label is known
+	jumps []pendingJump
+}
+
+type pendingJump struct {
+	instruction int
+	operand     int
+	label       string
+}
+
+func (g *bytecodeGenerator) generateCodes(input []ir.Instruction) error {
+	for _, code := range input {
+		err := g.generateCode(code)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *bytecodeGenerator) generateCode(code ir.Instruction) error {
+	switch code := code.(type) {
+	case ir.Label:
+		g.labels[code.Name] = len(g.program.instructions)
+		return nil
+
+	case ir.Jump:
+		g.jump(0, code.Label)
+		g.emit(opJump, 0, 0, 0)
+		return nil
+
+	case ir.CondJump:
+		condition, err := g.operand(code.Condition)
+		if err != nil {
+			return err
+		}
+		g.jump(1, code.Label)
+		g.emit(opJumpIf, condition, 0, 0)
+		return nil
+
+	case ir.Param:
+		parameter, err := g.operand(code.Value)
+		if err != nil {
+			return err
+		}
+		g.emit(opParam, parameter, 0, 0)
+		return nil
+
+	case ir.Call:
+		result := noOperand
+		if code.Result != ir.NoIdentifier {
+			result = uint32(code.Result)
+		}
+		switch code.Function {
+		case ir.Printf:
+			g.emit(opPrintf, uint32(code.Arguments), 0, 0)
+			return nil
+		case ir.PrintValues:
+			g.emit(opPrint, uint32(code.Arguments), 0, 0)
+			return nil
+		case ir.Length:
+			g.emit(opLength, result, 0, 0)
+			return nil
+		case ir.Getchar:
+			g.emit(opGetchar, result, 0, 0)
+			return nil
+		}
+		function, err := g.operand(code.Function)
+		if err != nil {
+			return err
+		}
+		g.emit(opCall, result, function, uint32(code.Arguments))
+		return nil
+
+	case ir.Return:
+		if code.Value == nil {
+			g.emit(opReturn, noOperand, 0, 0)
+			return nil
+		}
+		value, err := g.operand(code.Value)
+		if err != nil {
+			return err
+		}
+		g.emit(opReturn, value, 0, 0)
+		return nil
+
+	case ir.IndexStore:
+		operands, err := g.operands(code.Array, code.Index, code.Value)
+		if err != nil {
+			return err
+		}
+		g.emit(opStore, operands[0], operands[1], operands[2])
+		return nil
+
+	case ir.Assign:
+		operands, err := g.operands(code.Destination, code.Source)
+		if err != nil {
+			return err
+		}
+		if g.isArray(code.Destination) {
+			start, err := g.operand(ir.Int(0))
+			if err != nil {
+				return err
+			}
+			g.emit(opCopy, operands[0], operands[1], start)
+		} else {
+			g.emit(opMove, operands[0], operands[1], 0)
+		}
+		return nil
+
+	case ir.IndexLoad:
+		operands, err := g.operands(code.Destination, code.Array, code.Index)
+		if err != nil {
+			return err
+		}
+		if g.isArray(code.Destination) {
+			g.emit(opCopy, operands[0], operands[1], operands[2])
+		} else {
+			g.emit(opLoad, operands[0], operands[1], operands[2])
+		}
+		return nil
+
+	case ir.UnOp:
+		operands, err := g.operands(code.Destination, code.Operand)
+		if err != nil {
+			return err
+		}
+		switch code.Operator {
+		case ir.Negate:
+			g.emit(opNegate, operands[0], operands[1], 0)
+		case ir.Not:
+			g.emit(opNot, operands[0], operands[1], 0)
+		default:
+			return bytecodeGeneratorError("unknown unary operator in " + code.String())
+		}
+		return nil
+
+	case ir.BinOp:
+		operation, ok := opcodeWithBinaryOperator[code.Operator]
+		if !ok {
+			return bytecodeGeneratorError("unknown binary operator in " + code.String())
+		}
+		operands, err := g.operands(code.Destination, code.First, code.Second)
+		if err != nil {
+			return err
+		}
+		g.emit(operation, operands[0], operands[1], operands[2])
+		return nil
+	}
+	return bytecodeGeneratorError("unexpected instruction " + code.String())
+}
+
+func (g *bytecodeGenerator) emit(operation opcode, a, b, c uint32) {
+	g.program.instructions = append(g.program.instructions, instruction{
+		opcode:   operation,
+		operands: [3]uint32{a, b, c},
+	})
+}
+
+// the next instruction jumps to the label through the given operand
+func (g *bytecodeGenerator) jump(operand int, label string) {
+	g.jumps = append(g.jumps, pendingJump{
+		instruction: len(g.program.instructions),
+		operand:     operand,
+		label:       label,
+	})
+}
+
+func (g *bytecodeGenerator) isArray(identifier ir.Identifier) bool {
+	return g.program.registers[identifier].length > 0
+}
+
+func (g *bytecodeGenerator) operands(operands ...ir.Operand) ([]uint32, error) {
+	results := []uint32{}
+	for _, operand := range operands {
+		result, err := g.operand(operand)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (g *bytecodeGenerator) operand(operand ir.Operand) (uint32, error) {
+	switch operand := operand.(type) {
+	case ir.Identifier:
+		if operand < 0 || int(operand) >= len(g.program.registers) {
+			return 0, bytecodeGeneratorError("unknown identifier " + operand.String())
+		}
+		return uint32(operand), nil
+
+	case ir.Literal:
+		if index, ok := g.constants[operand]; ok {
+			return index, nil
+		}
+		value, err := constantValue(operand)
+		if err != nil {
+			return 0, err
+		}
+		index := uint32(len(g.program.constants)) | constantOperand
+		g.program.constants = append(g.program.constants, value)
+		g.constants[operand] = index
+		return index, nil
+	}
+	return 0, bytecodeGeneratorError("unexpected operand " + operand.String())
+}
+
+func registerKind(datatype common.Datatype) (valueKind, int) {
+	switch datatype := datatype.(type) {
+	case common.PrimitiveDatatype:
+		return primitiveKind(datatype), 0
+
+	case common.ArrayDatatype:
+		element := datatype.ElementType
+		for {
+			array, ok := element.(common.ArrayDatatype)
+			if !ok {
+				break
+			}
+			element = array.ElementType
+		}
+		kind, _ := registerKind(element)
+		_, length, _ := datatype.ToString()
+		return kind, length
+
+	case common.StringDatatype:
+		return kindString, 0
+
+	case common.FunctionDatatype:
+		return kindFunction, 0
+	}
+	return kindUnknown, 0
+}
+
+func primitiveKind(datatype common.PrimitiveDatatype) valueKind {
+	switch datatype {
+	case common.TypedInt:
+		return kindInt
+	case common.TypedFloat:
+		return kindFloat
+	case common.TypedChar:
+		return kindChar
+	case common.TypedBool:
+		return kindBool
+	}
+	for kind, sized := range sizedKinds {
+		if sized == datatype {
+			return kind
+		}
+	}
+	return kindUnknown
+}
+
+// constants are held the same way as the values of the interpreter
+func constantValue(literal ir.Literal) (any, error) {
+	switch literal.Kind {
+	case ir.LiteralString:
+		return unescape(literal.Value[1 : len(literal.Value)-1]), nil
+
+	case ir.LiteralChar:
+		characters := unescape(literal.Value[1 : len(literal.Value)-1])
+		if len(characters) != 1 {
+			return nil, bytecodeGeneratorError("invalid character literal " + literal.Value)
+		}
+		return int8(characters[0]), nil
+
+	case ir.LiteralBool:
+		return literal.Value == "true", nil
+
+	case ir.LiteralFloat:
+		value, err := strconv.ParseFloat(literal.Value, 64)
+		if err != nil {
+			return nil, bytecodeGeneratorError("invalid float literal " + literal.Value)
+		}
+		return value, nil
+	}
+
+	value, err := strconv.ParseInt(literal.Value, 10, 64)
+	if err != nil {
+		return nil, bytecodeGeneratorError("invalid literal " + literal.Value)
+	}
+	return value, nil
+}
+
+// writes the bytecode as an .slbc file
+func WriteBytecode(writer io.Writer, program Bytecode) error {
+	output := []byte(bytecodeMagic)
+	output = binary.LittleEndian.AppendUint16(output, bytecodeVersion)
+
+	output = binary.LittleEndian.AppendUint32(output, uint32(len(program.registers)))
+	for _, register := range program.registers {
+		output = append(output, byte(register.kind))
+		output = binary.LittleEndian.AppendUint32(output, uint32(register.length))
+	}
+
+	output = binary.LittleEndian.AppendUint32(output, uint32(len(program.constants)))
+	for _, constant := range program.constants {
+		switch value := constant.(type) {
+		case int64:
+			output = append(output, byte(kindInt))
+			output = binary.LittleEndian.AppendUint64(output, uint64(value))
+		case float64:
+			output = append(output, byte(kindFloat))
+			output = binary.LittleEndian.AppendUint64(output, math.Float64bits(value))
+		case int8:
+			output = append(output, byte(kindChar), byte(value))
+		case bool:
+			output = append(output, byte(kindBool), 0)
+			if value {
+				output[len(output)-1] = 1
+			}
+		case string:
+			output = append(output, byte(kindString))
+			output = binary.LittleEndian.AppendUint32(output, uint32(len(value)))
+			output = append(output, value...)
+		default:
+			return bytecodeGeneratorError("constant of an unknown kind")
+		}
+	}
+
+	output = binary.LittleEndian.AppendUint32(output, uint32(len(program.functions)))
+	for _, function := range program.functions {
+		output = binary.LittleEndian.AppendUint32(output, uint32(function.register))
+		output = binary.LittleEndian.AppendUint32(output, uint32(function.entry))
+		output = binary.LittleEndian.AppendUint32(output, uint32(len(function.parameters)))
+		for _, parameter := range function.parameters {
+			output = binary.LittleEndian.AppendUint32(output, uint32(parameter))
+		}
+		output = binary.LittleEndian.AppendUint32(output, uint32(len(function.locals)))
+		for _, local := range function.locals {
+			output = binary.LittleEndian.AppendUint32(output, uint32(local))
+		}
+	}
+
+	output = binary.LittleEndian.AppendUint32(output, uint32(len(program.instructions)))
+	for _, instruction := range program.instructions {
+		output = append(output, byte(instruction.opcode))
+		for _, operand := range instruction.operands {
+			output = binary.LittleEndian.AppendUint32(output, operand)
+		}
+	}
+
+	_, err := writer.Write(output)
+	return err
+}
+
+// reads an .slbc file, checking that it is well formed
+func ReadBytecode(reader io.Reader) (Bytecode, error) {
+	input := bytecodeReader{reader: bufio.NewReader(reader)}
+	program := Bytecode{
+		registers:    []register{},
+		constants:    []any{},
+		functions:    []bytecodeFunction{},
+		instructions: []instruction{},
+	}
+
+	magic := make([]byte, len(bytecodeMagic))
+	input.read(magic)
+	if input.err == nil && string(magic) != bytecodeMagic {
+		return Bytecode{}, bytecodeFormatError("not an .slbc file")
+	}
+	if version := input.uint16(); input.err == nil && version != bytecodeVersion {
+		return Bytecode{}, bytecodeFormatError(fmt.Sprintf("unsupported version %v", version))
+	}
+
+	count := input.uint32()
+	for i := uint32(0); i < count && input.err == nil; i++ {
+		kind := valueKind(input.byte())
+		length := input.uint32()
+		program.registers = append(program.registers, register{kind: kind, length: int(length)})
+	}
+
+	count = input.uint32()
+	for i := uint32(0); i < count && input.err == nil; i++ {
+		switch valueKind(input.byte()) {
+		case kindInt:
+			program.constants = append(program.constants, int64(input.uint64()))
+		case kindFloat:
+			program.constants = append(program.constants, math.Float64frombits(input.uint64()))
+		case kindChar:
+			program.constants = append(program.constants, int8(input.byte()))
+		case kindBool:
+			program.constants = append(program.constants, input.byte() != 0)
+		case kindString:
+			program.constants = append(program.constants, input.string())
+		default:
+			return Bytecode{}, bytecodeFormatError("constant of an unknown kind")
+		}
+	}
+
+	count = input.uint32()
+	for i := uint32(0); i < count && input.err == nil; i++ {
+		function := bytecodeFunction{
+			register:   int(input.uint32()),
+			entry:      int(input.uint32()),
+			parameters: []int{},
+			locals:     []int{},
+		}
+		parameters := input.uint32()
+		for j := uint32(0); j < parameters && input.err == nil; j++ {
+			function.parameters = append(function.parameters, int(input.uint32()))
+		}
+		locals := input.uint32()
+		for j := uint32(0); j < locals && input.err == nil; j++ {
+			function.locals = append(function.locals, int(input.uint32()))
+		}
+		program.functions = append(program.functions, function)
+	}
+
+	count = input.uint32()
+	for i := uint32(0); i < count && input.err == nil; i++ {
+		instruction := instruction{opcode: opcode(input.byte())}
+		for j := range instruction.operands {
+			instruction.operands[j] = input.uint32()
+		}
+		program.instructions = append(program.instructions, instruction)
+	}
+
+	if input.err != nil {
+		return Bytecode{}, bytecodeFormatError(input.err.Error())
+	}
+	return program, program.validate()
+}
+
+type bytecodeReader struct {
+	reader *bufio.Reader
+	// the first error is kept, and every read after it does nothing
+	err error
+}
+
+func (r *bytecodeReader) read(buffer []byte) {
+	if r.err != nil {
+		return
+	}
+	_, r.err = io.ReadFull(r.reader, buffer)
+	if r.err == io.EOF || r.err == io.ErrUnexpectedEOF {
+		r.err = errors.New("unexpected end of file")
+	}
+}
+
+// reads a length and that many bytes, which grow with what is read,
+// so that a corrupted length cannot ask for more memory than the file has
+func (r *bytecodeReader) string() string {
+	length := r.uint32()
+	if r.err != nil {
+		return ""
+	}
+	var value strings.Builder
+	if _, err := io.CopyN(&value, r.reader, int64(length)); err != nil {
+		r.err = errors.New("unexpected end of file")
+	}
+	return value.String()
+}
+
+func (r *bytecodeReader) byte() byte {
+	buffer := make([]byte, 1)
+	r.read(buffer)
+	return buffer[0]
+}
+
+func (r *bytecodeReader) uint16() uint16 {
+	buffer := make([]byte, 2)
+	r.read(buffer)
+	return binary.LittleEndian.Uint16(buffer)
+}
+
+func (r *bytecodeReader) uint32() uint32 {
+	buffer := make([]byte, 4)
+	r.read(buffer)
+	return binary.LittleEndian.Uint32(buffer)
+}
+
+func (r *bytecodeReader) uint64() uint64 {
+	buffer := make([]byte, 8)
+	r.read(buffer)
+	return binary.LittleEndian.Uint64(buffer)
+}
+
+// makes sure that the virtual machine never reads outside of the program
+func (b Bytecode) validate() error {
+	isRegister := func(operand uint32) bool {
+		return operand < uint32(len(b.registers))
+	}
+	isArray := func(operand uint32) bool {
+		return isRegister(operand) && b.registers[operand].length > 0
+	}
+	isScalar := func(operand uint32) bool {
+		return isRegister(operand) && b.registers[operand].length == 0
+	}
+	isOperand := func(operand uint32) bool {
+		if operand&constantOperand != 0 {
+			return operand&^constantOperand < uint32(len(b.constants))
+		}
+		return isRegister(operand)
+	}
+	isInstruction := func(operand uint32) bool {
+		return operand < uint32(len(b.instructions))
+	}
+
+	elements := 0
+	for _, register := range b.registers {
+		elements += register.length
+		if register.length > maxArrayElements || elements > maxArrayElements {
+			return bytecodeFormatError("arrays larger than the virtual machine holds")
+		}
+	}
+
+	functions := map[int]bool{}
+	for _, function := range b.functions {
+		if !isRegister(uint32(function.register)) || !isInstruction(uint32(function.entry)) {
+			return bytecodeFormatError("function outside of the program")
+		}
+		for _, owned := range append(append([]int{}, function.parameters...), function.locals...) {
+			if !isRegister(uint32(owned)) {
+				return bytecodeFormatError("function with an unknown register")
+			}
+		}
+		functions[function.register] = true
+	}
+
+	for index, instruction := range b.instructions {
+		a, second, c := instruction.operands[0], instruction.operands[1], instruction.operands[2]
+		valid := true
+		switch {
+		case instruction.opcode == opHalt:
+		case instruction.opcode == opMove, instruction.opcode == opNegate, instruction.opcode == opNot:
+			valid = isScalar(a) && isOperand(second)
+		case instruction.opcode == opCopy, instruction.opcode == opStore:
+			valid = isArray(a) && isOperand(second) && isOperand(c)
+		case instruction.opcode == opLoad,
+			instruction.opcode >= opAdd && instruction.opcode <= opLesserThanOrEquals:
+			valid = isScalar(a) && isOperand(second) && isOperand(c)
+		case instruction.opcode == opJump:
+			valid = isInstruction(a)
+		case instruction.opcode == opJumpIf:
+			valid = isOperand(a) && isInstruction(second)
+		case instruction.opcode == opParam:
+			valid = isOperand(a)
+		case instruction.opcode == opCall:
+			valid = (a == noOperand || isScalar(a)) && functions[int(second)]
+		case instruction.opcode == opReturn:
+			valid = a == noOperand || isOperand(a)
+		case instruction.opcode == opGetchar:
+			valid = isScalar(a)
+		case instruction.opcode == opPrintf:
+			valid = a > 0
+		case instruction.opcode == opPrint:
+			valid = true
+		case instruction.opcode == opLength:
+			valid = isScalar(a)
+		default:
+			return bytecodeFormatError(fmt.Sprintf("unknown opcode at instruction %v", index))
+		}
+		if !valid {
+			return bytecodeFormatError(fmt.Sprintf("invalid operands at instruction %v", index))
+		}
+	}
+	return nil
+}
+
+func bytecodeGeneratorError(message string) *common.InternalError {
+	return &common.InternalError{
+		PointOfFailure: "Bytecode Generator",
+		Message:        message,
+	}
+}
+
+func bytecodeFormatError(message string) error {
+	return fmt.Errorf("invalid bytecode: %v", message)
+}
@@ -0,0 +1,227 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/ir"
+)
+
+// the 3-address code of the main program or of a single function, split into basic blocks
+type ControlFlowGraph struct {
+	// NoIdentifier for the main program
+	Function   ir.Identifier
+	Parameters []ir.Identifier
+	Locals     []ir.Identifier
+
+	// the first block is the entry
+	Blocks []BasicBlock
+}
+
+// a run of instructions that is only entered at the start and only left at the end
+type BasicBlock struct {
+	Instructions []ir.Instruction
+	Predecessors []int
+	Successors   []int
+}
+
+// one graph for the main program, followed by one for every function
+func ControlFlowGraphs(input []ir.Instruction) ([]ControlFlowGraph, error) {
+	functions, mainCodes, err := splitFunctions(input)
+	if err != nil {
+		return nil, err
+	}
+
+	graph, err := buildControlFlowGraph(mainCodes)
+	if err != nil {
+		return nil, err
+	}
+	graph.Function = ir.NoIdentifier
+	graphs := []ControlFlowGraph{graph}
+
+	for _, function := range functions {
+		graph, err := buildControlFlowGraph(function.codes)
+		if err != nil {
+			return nil, err
+		}
+		graph.Function = ir.Identifier(function.identifier)
+		for _, parameter := range function.parameters {
+			graph.Parameters = append(graph.Parameters, ir.Identifier(parameter))
+		}
+		for _, local := range function.locals {
+			graph.Locals = append(graph.Locals, ir.Identifier(local))
+		}
+		graphs = append(graphs, graph)
+	}
+	return graphs, nil
+}
+
+func buildControlFlowGraph(codes []ir.Instruction) (ControlFlowGraph, error) {
+	graph := ControlFlowGraph{
+		Parameters: []ir.Identifier{},
+		Locals:     []ir.Identifier{},
+		Blocks:     []BasicBlock{},
+	}
+	labels := map[string]int{}
+
+	// a block starts at a label or after a jump or return
+	startBlock := true
+	for _, code := range codes {
+		if label, ok := code.(ir.Label); ok {
+			startBlock = true
+			if _, ok := labels[label.Name]; ok {
+				return ControlFlowGraph{}, controlFlowGraphError("label " + label.Name + " is declared twice")
+			}
+		}
+		if startBlock {
+			graph.Blocks = append(graph.Blocks, BasicBlock{
+				Instructions: []ir.Instruction{},
+				Predecessors: []int{},
+				Successors:   []int{},
+			})
+			startBlock = false
+		}
+
+		current := len(graph.Blocks) - 1
+		graph.Blocks[current].Instructions = append(graph.Blocks[current].Instructions, code)
+
+		switch code := code.(type) {
+		case ir.Label:
+			labels[code.Name] = current
+		case ir.Jump, ir.CondJump, ir.Return:
+			startBlock = true
+		}
+	}
+	// so that there is always an entry, even for an empty function
+	if len(graph.Blocks) == 0 {
+		graph.Blocks = append(graph.Blocks, BasicBlock{
+			Instructions: []ir.Instruction{},
+			Predecessors: []int{},
+			Successors:   []int{},
+		})
+	}
+
+	for index, block := range graph.Blocks {
+		successors := []int{}
+		fallsThrough := true
+
+		if len(block.Instructions) > 0 {
+			switch code := block.Instructions[len(block.Instructions)-1].(type) {
+			case ir.Jump:
+				target, ok := labels[code.Label]
+				if !ok {
+					return ControlFlowGraph{}, controlFlowGraphError("jump to an unknown label " + code.Label)
+				}
+				successors = append(successors, target)
+				fallsThrough = false
+
+			case ir.CondJump:
+				target, ok := labels[code.Label]
+				if !ok {
+					return ControlFlowGraph{}, controlFlowGraphError("jump to an unknown label " + code.Label)
+				}
+				successors = append(successors, target)
+
+			case ir.Return:
+				fallsThrough = false
+			}
+		}
+		// a conditional jump to the very next block has a single successor
+		if fallsThrough && index+1 < len(graph.Blocks) &&
+			(len(successors) == 0 || successors[0] != index+1) {
+			successors = append(successors, index+1)
+		}
+
+		graph.Blocks[index].Successors = successors
+		for _, successor := range successors {
+			graph.Blocks[successor].Predecessors = append(graph.Blocks[successor].Predecessors, index)
+		}
+	}
+	return graph, nil
+}
+
+// the graphs in the Graphviz DOT language, with every function in a cluster of its own named after it
+func ControlFlowGraphDot(graphs []ControlFlowGraph, identifiers []common.IdentifierInformation) string {
+	dot := strings.Builder{}
+	dot.WriteString("digraph cfg {\n")
+	dot.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+
+	for graphIndex, graph := range graphs {
+		name := "main"
+		if graph.Function != ir.NoIdentifier {
+			// with fn, so that a function called main is told apart from the main program
+			name = "fn " + identifiers[graph.Function].IdentifierName
+		}
+		fmt.Fprintf(&dot, "\n\tsubgraph cluster_%v {\n", graphIndex)
+		fmt.Fprintf(&dot, "\t\tlabel=\"%v\";\n", dotEscape(name))
+
+		for blockIndex, block := range graph.Blocks {
+			// \l ends a left-justified line
+			label := strings.Builder{}
+			fmt.Fprintf(&label, "B%v\\l", blockIndex)
+			for _, code := range block.Instructions {
+				label.WriteString(dotEscape(code.String()))
+				label.WriteString("\\l")
+			}
+			fmt.Fprintf(&dot, "\t\tg%v_b%v [label=\"%v\"];\n", graphIndex, blockIndex, label.String())
+		}
+
+		for blockIndex, block := range graph.Blocks {
+			isConditional := false
+			if len(block.Instructions) > 0 {
+				_, isConditional = block.Instructions[len(block.Instructions)-1].(ir.CondJump)
+			}
+			for position, successor := range block.Successors {
+				attributes := ""
+				if isConditional && len(block.Successors) == 2 {
+					// the jump is taken first, and the block falls through otherwise
+					attributes = " [label=\"true\"]"
+					if position == 1 {
+						attributes = " [label=\"false\"]"
+					}
+				}
+				fmt.Fprintf(&dot, "\t\tg%v_b%v -> g%v_b%v%v;\n",
+					graphIndex, blockIndex, graphIndex, successor, attributes)
+			}
+		}
+		dot.WriteString("\t}\n")
+	}
+
+	dot.WriteString("}\n")
+	return dot.String()
+}
+
+func dotEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text)
+}
+
+func controlFlowGraphError(message string) *common.InternalError {
+	return &common.InternalError{
+		PointOfFailure: "Control Flow Graph",
+		Message:        message,
+	}
+}
+
+// puts the graphs back together as 3-address code, with the functions after the main program
+func instructionsFromGraphs(graphs []ControlFlowGraph) []ir.Instruction {
+	codes := []ir.Instruction{}
+	for _, graph := range graphs {
+		if graph.Function != ir.NoIdentifier {
+			codes = append(codes, ir.FunctionStart{
+				Function:   graph.Function,
+				Parameters: graph.Parameters,
+			})
+			for _, local := range graph.Locals {
+				codes = append(codes, ir.Local{Identifier: local})
+			}
+		}
+		for _, block := range graph.Blocks {
+			codes = append(codes, block.Instructions...)
+		}
+		if graph.Function != ir.NoIdentifier {
+			codes = append(codes, ir.FunctionEnd{})
+		}
+	}
+	return codes
+}
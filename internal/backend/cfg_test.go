@@ -0,0 +1,27 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestControlFlowGraphDotLabels(t *testing.T) {
+	codes, identifiers := compileSource(t, `fn add(a: int, b: int) -> int {
+    return a + b;
+};
+fn main() {
+    println(add(1, 2));
+};
+main();
+`, 0)
+	graphs, err := ControlFlowGraphs(codes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dot := ControlFlowGraphDot(graphs, identifiers)
+	for _, label := range []string{`label="main";`, `label="fn add";`, `label="fn main";`} {
+		if !strings.Contains(dot, label) {
+			t.Errorf("no cluster with %v in\n%v", label, dot)
+		}
+	}
+}
@@ -2,173 +2,606 @@ package backend
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/ir"
 )
 
 // we are compiling to C
 func CodeGenerator(
-	input []string,
+	input []ir.Instruction,
 	identifiers []common.IdentifierInformation,
 ) (string, error) {
 	codes := strings.Builder{}
-	buffer := []string{}
-	var err error
 
 	writeStart(&codes)
 
-	codes.WriteString("int main() {\n\t")
+	functions, mainCodes, err := splitFunctions(input)
+	if err != nil {
+		return "", err
+	}
 
-	for index, information := range identifiers {
-		if information.Datatype == nil || information.Datatype.IsDatatype(common.TypedUnknown) {
-			// this can only be introduced into the program
-			// by declaring and not initialising a value
-			continue
+	// identifiers belonging to a function are declared inside that function
+	ownedByFunction := map[int]bool{}
+	for _, function := range functions {
+		for _, parameter := range function.parameters {
+			ownedByFunction[parameter] = true
 		}
-		datatype, length, err := information.Datatype.ToString()
+		for _, local := range function.locals {
+			ownedByFunction[local] = true
+		}
+	}
+
+	// prototypes, so that the order of the functions does not matter
+	for _, function := range functions {
+		signature, err := functionSignature(function, identifiers)
 		if err != nil {
-			fmt.Printf("WARN: %v", err)
+			return "", err
+		}
+		fmt.Fprintf(&codes, "\n%v;\n", signature)
+	}
+
+	for _, function := range functions {
+		signature, err := functionSignature(function, identifiers)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&codes, "\n%v {\n\t", signature)
+		for _, local := range function.locals {
+			writeDeclaration(&codes, local, identifiers[local])
+		}
+		err = writeCodesForLines(&codes, function.codes, identifiers)
+		if err != nil {
+			return "", err
+		}
+		codes.WriteString("\n}\n")
+	}
+
+	codes.WriteString("\nint main() {\n\t")
+
+	for index, information := range identifiers {
+		if ownedByFunction[index] {
 			continue
 		}
-		if length <= 1 {
-			fmt.Fprintf(&codes, "%v _t%v;\n\t", datatype, index)
+		if _, ok := information.Datatype.(common.FunctionDatatype); ok {
 			continue
 		}
-		fmt.Fprintf(
-			&codes,
-			"%v _arr%v[%v];\n\t",
-			datatype,
-			index,
-			length,
-		)
-		fmt.Fprintf(&codes, "%v* _t%v = _arr%v;", datatype, index, index)
-		codes.WriteString("\n\t")
+		writeDeclaration(&codes, index, information)
 	}
 
-	for _, line := range input {
-		buffer, err = writeCodeForLine(&codes, line, buffer, identifiers)
-		codes.WriteString("\n\t")
+	err = writeCodesForLines(&codes, mainCodes, identifiers)
+	if err != nil {
+		return "", err
+	}
+
+	codes.WriteString("\n}")
+	return codes.String(), nil
+}
+
+// a function as found in the 3-address code, between "function" and "end function"
+type function struct {
+	identifier int
+	parameters []int
+	locals     []int
+	codes      []ir.Instruction
+}
+
+// separates the functions from the codes of the main program
+func splitFunctions(input []ir.Instruction) ([]function, []ir.Instruction, error) {
+	functions := []function{}
+	mainCodes := []ir.Instruction{}
+	var current *function
+
+	for _, code := range input {
+		switch code := code.(type) {
+		case ir.FunctionStart:
+			if current != nil {
+				return nil, nil, codeGeneratorError("function declared inside another function")
+			}
+			current = &function{
+				identifier: int(code.Function),
+				parameters: []int{},
+				locals:     []int{},
+				codes:      []ir.Instruction{},
+			}
+			for _, parameter := range code.Parameters {
+				current.parameters = append(current.parameters, int(parameter))
+			}
+
+		case ir.Local:
+			if current == nil {
+				return nil, nil, codeGeneratorError("local declared outside of a function")
+			}
+			current.locals = append(current.locals, int(code.Identifier))
+
+		case ir.FunctionEnd:
+			if current == nil {
+				return nil, nil, codeGeneratorError("end of a function that was never started")
+			}
+			functions = append(functions, *current)
+			current = nil
+
+		default:
+			if current != nil {
+				current.codes = append(current.codes, code)
+			} else {
+				mainCodes = append(mainCodes, code)
+			}
+		}
+	}
+	if current != nil {
+		return nil, nil, codeGeneratorError("function is never ended")
+	}
+	return functions, mainCodes, nil
+}
+
+func functionSignature(
+	function function,
+	identifiers []common.IdentifierInformation,
+) (string, error) {
+	functionDatatype, ok := identifiers[function.identifier].Datatype.(common.FunctionDatatype)
+	if !ok {
+		return "", codeGeneratorError("function declared on a non-function identifier")
+	}
+
+	returnType := "void"
+	if !functionDatatype.ReturnType.IsDatatype(common.VoidDatatype{}) {
+		datatype, _, err := functionDatatype.ReturnType.ToString()
 		if err != nil {
 			return "", err
 		}
+		returnType = datatype
 	}
 
-	codes.WriteString("\n}")
-	return codes.String(), nil
+	parameters := []string{}
+	for _, parameter := range function.parameters {
+		datatype, _, err := identifiers[parameter].Datatype.ToString()
+		if err != nil {
+			return "", err
+		}
+		parameters = append(parameters, fmt.Sprintf("%v _t%v", datatype, parameter))
+	}
+	if len(parameters) == 0 {
+		parameters = append(parameters, "void")
+	}
+
+	return fmt.Sprintf(
+		"%v _t%v(%v)",
+		returnType,
+		function.identifier,
+		strings.Join(parameters, ", "),
+	), nil
+}
+
+func writeDeclaration(
+	codes *strings.Builder,
+	index int,
+	information common.IdentifierInformation,
+) {
+	if information.Datatype == nil || information.Datatype.IsDatatype(common.TypedUnknown) {
+		// this can only be introduced into the program
+		// by declaring and not initialising a value
+		return
+	}
+	datatype, length, err := information.Datatype.ToString()
+	if err != nil {
+		fmt.Printf("WARN: %v", err)
+		return
+	}
+	// an array of one element is still an array
+	if _, isArray := information.Datatype.(common.ArrayDatatype); !isArray {
+		fmt.Fprintf(codes, "%v _t%v;\n\t", datatype, index)
+		return
+	}
+	// zero-filled as well as zeroed where an array declared without a value is declared,
+	// e.g., let mut buf: [char; 64];
+	fmt.Fprintf(
+		codes,
+		"%v _arr%v[%v] = {0};\n\t",
+		datatype,
+		index,
+		length,
+	)
+	fmt.Fprintf(codes, "%v* _t%v = _arr%v;", datatype, index, index)
+	codes.WriteString("\n\t")
+}
+
+func writeCodesForLines(
+	codes *strings.Builder,
+	input []ir.Instruction,
+	identifiers []common.IdentifierInformation,
+) error {
+	buffer := []ir.Operand{}
+	var err error
+
+	for _, code := range input {
+		buffer, err = writeCodeForLine(codes, code, buffer, identifiers)
+		codes.WriteString("\n\t")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func writeCodeForLine(
 	codes *strings.Builder,
-	line string,
-	buffer []string,
+	code ir.Instruction,
+	buffer []ir.Operand,
 	identifiers []common.IdentifierInformation,
-) ([]string, error) {
-	if line[len(line)-1] == ':' {
-		// label
+) ([]ir.Operand, error) {
+	switch code := code.(type) {
+	case ir.Label:
 		// TODO add buffer check
-		codes.WriteString(line)
-		return []string{}, nil
-	}
-	words := strings.Split(line, " ")
-	switch words[0] {
-	case "goto":
-		// jump
-		codes.WriteString(line)
-		codes.WriteString(";")
-		return []string{}, nil
+		codes.WriteString(code.String())
+		return []ir.Operand{}, nil
+
+	case ir.Jump:
+		fmt.Fprintf(codes, "%v;", code)
+		return []ir.Operand{}, nil
 
-	case "if":
-		// if R goto L
-		fmt.Fprintf(codes, "if (%v) { %v; }", words[1], strings.Join(words[2:], " "))
-		return []string{}, nil
+	case ir.CondJump:
+		fmt.Fprintf(codes, "if (%v) { goto %v; }", code.Condition, code.Label)
+		return []ir.Operand{}, nil
 
-	case "param":
-		// param t
-		buffer = append(buffer, strings.Join(words[1:], " ")) // for strings with spaces
+	case ir.Param:
+		buffer = append(buffer, code.Value)
 		return buffer, nil
 
-	case "call":
-		// call func_name
-		fmt.Fprintf(codes, "%v(", words[1])
-		for i, b := range buffer {
-			codes.Write([]byte(b))
-			if i < len(buffer)-1 {
-				fmt.Fprint(codes, ", ")
+	case ir.Call:
+		if code.Function == ir.Getchar {
+			fmt.Fprintf(codes, "%v = fgetc(stdin);", code.Result)
+			return []ir.Operand{}, nil
+		}
+		if code.Function == ir.Length && len(buffer) == 1 {
+			fmt.Fprintf(codes, "%v = (long long) strlen(%v);", code.Result, buffer[0])
+			return []ir.Operand{}, nil
+		}
+		if code.Function == ir.PrintValues {
+			err := writePrint(codes, buffer, identifiers)
+			return []ir.Operand{}, err
+		}
+		arguments := []string{}
+		for _, operand := range buffer {
+			if code.Function == ir.Printf {
+				arguments = append(arguments, printfArgument(operand, identifiers))
+			} else {
+				arguments = append(arguments, cOperand(operand))
 			}
 		}
-		fmt.Fprint(codes, ");")
-		return []string{}, nil
+		if code.Result != ir.NoIdentifier {
+			fmt.Fprintf(codes, "%v = ", code.Result)
+		}
+		writeCall(codes, code.Function.String(), arguments)
+		codes.WriteString(";")
+		return []ir.Operand{}, nil
+
+	case ir.Return:
+		fmt.Fprintf(codes, "%v;", code)
+		return []ir.Operand{}, nil
+
+	case ir.IndexStore:
+		fmt.Fprintf(codes, "%v[%v] = %v;", code.Array, code.Index, code.Value)
+		return []ir.Operand{}, nil
+
+	case ir.BinOp:
+		if isString(code.First, identifiers) {
+			writeStringOperation(codes, code)
+			return []ir.Operand{}, nil
+		}
+		if datatype, ok := wraps(code.Operator, code.Destination, identifiers); ok {
+			fmt.Fprintf(
+				codes, "%v = (%v) ((uint64_t) %v %v (uint64_t) %v);",
+				code.Destination, datatype, code.First, code.Operator, code.Second,
+			)
+			return []ir.Operand{}, nil
+		}
+		fmt.Fprintf(codes, "%v = %v %v %v;", code.Destination, code.First, code.Operator, code.Second)
+		return []ir.Operand{}, nil
+
+	case ir.UnOp:
+		if datatype, ok := wraps(code.Operator, code.Destination, identifiers); ok {
+			fmt.Fprintf(
+				codes, "%v = (%v) (%v (uint64_t) %v);",
+				code.Destination, datatype, code.Operator, code.Operand,
+			)
+			return []ir.Operand{}, nil
+		}
+		fmt.Fprintf(codes, "%v = %v %v;", code.Destination, code.Operator, code.Operand)
+		return []ir.Operand{}, nil
+
+	case ir.Assign:
+		copied, err := writeArrayCopy(codes, code.Destination, code.Source.String(), identifiers)
+		if err != nil || copied {
+			return []ir.Operand{}, err
+		}
+		// a value of another primitive datatype is converted with a cast, e.g., from as
+		destination := identifiers[code.Destination].Datatype
+		source := operandDatatype(code.Source, identifiers)
+		if source != nil && !source.IsDatatype(destination) &&
+			common.ConversionBetween(source, destination) != common.ConversionInvalid {
+			cast, _, err := destination.ToString()
+			if err != nil {
+				return []ir.Operand{}, err
+			}
+			writeCast(codes, code.Destination, cast, code.Source, source, destination)
+			return []ir.Operand{}, nil
+		}
+		fmt.Fprintf(codes, "%v = %v;", code.Destination, code.Source)
+		return []ir.Operand{}, nil
+
+	case ir.IndexLoad:
+		source := fmt.Sprintf("(%v + %v)", code.Array, code.Index)
+		copied, err := writeArrayCopy(codes, code.Destination, source, identifiers)
+		if err != nil || copied {
+			return []ir.Operand{}, err
+		}
+		fmt.Fprintf(codes, "%v = %v[%v];", code.Destination, code.Array, code.Index)
+		return []ir.Operand{}, nil
 	}
 
-	if words[1] == "[]" {
-		fmt.Fprintf(codes, "%v[%v] = %v;", words[0], words[2], strings.Join(words[4:], " "))
-		return []string{}, nil
+	return []ir.Operand{}, codeGeneratorError(fmt.Sprintf("unexpected instruction %v", code))
+}
+
+// Whether the operation on a sized integer is worked out as a uint64_t, which wraps around instead of overflowing,
+// and the C type the result is narrowed to, e.g., 2147483647i32 + 1i32 is -2147483648.
+func wraps(
+	operator ir.Operator,
+	destination ir.Identifier,
+	identifiers []common.IdentifierInformation,
+) (string, bool) {
+	switch operator {
+	// ir.Negate is the same - as ir.Subtract
+	case ir.Add, ir.Subtract, ir.Multiply:
+		datatype, ok := identifiers[destination].Datatype.(common.PrimitiveDatatype)
+		if !ok || !datatype.IsSized() {
+			return "", false
+		}
+		name, _, err := datatype.ToString()
+		return name, err == nil
 	}
+	return "", false
+}
 
-	if words[1] != "=" {
-		return []string{}, codeGeneratorError(
-			fmt.Sprintf("expected v = ..., found %v instead of =", words[1]),
+// A float becomes a sized integer by way of an int64_t, which wraps the same way as the other integers,
+// where C leaves a float that does not fit in an unsigned integer undefined, e.g., -2.7 as u8 is 254.
+// Only the floats too large for an int64_t are converted to a u64 directly.
+func writeCast(
+	codes *strings.Builder,
+	destination ir.Identifier,
+	cast string,
+	operand ir.Operand,
+	from common.Datatype,
+	to common.Datatype,
+) {
+	primitive, _ := to.(common.PrimitiveDatatype)
+	isFloat := from.IsDatatype(common.TypedFloat) || from.IsDatatype(common.TypedF32)
+	switch {
+	case !isFloat || !primitive.IsSized():
+		fmt.Fprintf(codes, "%v = (%v) %v;", destination, cast, operand)
+	case primitive == common.TypedU64:
+		fmt.Fprintf(
+			codes, "%v = %v >= 9223372036854775808.0 ? (uint64_t) %v : (uint64_t) (int64_t) %v;",
+			destination, operand, operand, operand,
 		)
+	default:
+		fmt.Fprintf(codes, "%v = (%v) (int64_t) %v;", destination, cast, operand)
 	}
+}
 
-	if words[2] == "call" && words[3] == "getchar" {
-		// i = input
-		fmt.Fprintf(codes, "%s = fgetc(stdin);", words[0])
-		return []string{}, nil
+func isString(operand ir.Operand, identifiers []common.IdentifierInformation) bool {
+	switch operand := operand.(type) {
+	case ir.Literal:
+		return operand.Kind == ir.LiteralString
+	case ir.Identifier:
+		datatype := identifiers[operand].Datatype
+		return datatype != nil && datatype.IsDatatype(common.StringDatatype{})
 	}
+	return false
+}
 
-	_, length, err := identifiers[indexFromIdentifier(words[0])].Datatype.ToString()
+// the datatype of a literal or an identifier, nil if it has none
+func operandDatatype(operand ir.Operand, identifiers []common.IdentifierInformation) common.Datatype {
+	switch operand := operand.(type) {
+	case ir.Literal:
+		return map[ir.LiteralKind]common.Datatype{
+			ir.LiteralInt:    common.TypedInt,
+			ir.LiteralFloat:  common.TypedFloat,
+			ir.LiteralChar:   common.TypedChar,
+			ir.LiteralBool:   common.TypedBool,
+			ir.LiteralString: common.StringDatatype{},
+		}[operand.Kind]
+	case ir.Identifier:
+		return identifiers[operand].Datatype
+	}
+	return nil
+}
+
+// strings are compared by their characters, and concatenated into a new string
+func writeStringOperation(codes *strings.Builder, code ir.BinOp) {
+	switch code.Operator {
+	case ir.Equals:
+		fmt.Fprintf(codes, "%v = strcmp(%v, %v) == 0;", code.Destination, code.First, code.Second)
+	case ir.NotEquals:
+		fmt.Fprintf(codes, "%v = strcmp(%v, %v) != 0;", code.Destination, code.First, code.Second)
+	default:
+		fmt.Fprintf(codes, "%v = concat__str(%v, %v);", code.Destination, code.First, code.Second)
+	}
+}
+
+// arrays are copied element by element, returns false if the destination is not an array
+func writeArrayCopy(
+	codes *strings.Builder,
+	destination ir.Identifier,
+	source string,
+	identifiers []common.IdentifierInformation,
+) (bool, error) {
+	datatype := identifiers[destination].Datatype
+	_, length, err := datatype.ToString()
 	if err != nil {
-		return []string{}, err
+		return false, err
+	}
+	if _, isArray := datatype.(common.ArrayDatatype); !isArray {
+		return false, nil
 	}
-	if length > 1 {
-		datatype := identifiers[indexFromIdentifier(words[0])].Datatype
-		destination := words[0]
-		source := words[2]
-		if len(words) > 3 && words[3] == "[]" {
-			source = fmt.Sprintf("(%v + %v)", source, words[4])
+	fmt.Fprintf(
+		codes,
+		"copy__%v(%v, %v, %v);",
+		datatype.ToRepresentation(),
+		destination,
+		source,
+		length,
+	)
+	return true, nil
+}
+
+func writeCall(codes *strings.Builder, name string, arguments []string) {
+	fmt.Fprintf(codes, "%v(", name)
+	for i, argument := range arguments {
+		codes.WriteString(argument)
+		if i < len(arguments)-1 {
+			fmt.Fprint(codes, ", ")
 		}
-		fmt.Fprintf(
-			codes,
-			"copy__%v(%v, %v, %v);",
-			datatype.ToRepresentation(),
-			destination,
-			source,
-			length,
-		)
-		return []string{}, nil
 	}
+	fmt.Fprint(codes, ")")
+}
+
+// the sized integers are passed as a long long, which is what printf expects of them, e.g., for %lld
+func printfArgument(operand ir.Operand, identifiers []common.IdentifierInformation) string {
+	datatype, ok := operandDatatype(operand, identifiers).(common.PrimitiveDatatype)
+	switch {
+	case !ok || !datatype.IsSized():
+		return cOperand(operand)
+	case datatype.IsUnsigned():
+		return fmt.Sprintf("(unsigned long long) %v", operand)
+	}
+	return fmt.Sprintf("(long long) %v", operand)
+}
+
+// integers are long long, which is what printf expects of them
+func cOperand(operand ir.Operand) string {
+	if literal, ok := operand.(ir.Literal); ok && literal.Kind == ir.LiteralInt {
+		return literal.Value + "LL"
+	}
+	return operand.String()
+}
 
-	if len(words) > 3 && words[3] == "[]" {
-		fmt.Fprintf(codes, "%v = %v[%v];", words[0], words[2], words[4])
-		return []string{}, nil
+// A single printf, with the conversion of each parameter chosen by its datatype.
+// String literals become a part of the format, and booleans are printed as true or false.
+func writePrint(
+	codes *strings.Builder,
+	buffer []ir.Operand,
+	identifiers []common.IdentifierInformation,
+) error {
+	format := ""
+	arguments := []string{}
+	for _, operand := range buffer {
+		if literal, ok := operand.(ir.Literal); ok && literal.Kind == ir.LiteralString {
+			format += strings.ReplaceAll(literal.Value[1:len(literal.Value)-1], "%", "%%")
+			continue
+		}
+		datatype := operandDatatype(operand, identifiers)
+
+		value := cOperand(operand)
+		primitive, _ := datatype.(common.PrimitiveDatatype)
+		switch {
+		case datatype == nil:
+			return codeGeneratorError(fmt.Sprintf("unexpected print parameter %v", operand))
+		case primitive.IsUnsigned():
+			format += "%llu"
+			value = printfArgument(operand, identifiers)
+		case datatype.IsDatatype(common.TypedInt), primitive.IsSized():
+			format += "%lld"
+			value = printfArgument(operand, identifiers)
+		case datatype.IsDatatype(common.TypedFloat), datatype.IsDatatype(common.TypedF32):
+			format += "%g"
+		case datatype.IsDatatype(common.TypedChar):
+			format += "%c"
+		case datatype.IsDatatype(common.TypedBool):
+			format += "%s"
+			value = fmt.Sprintf("(%v ? \"true\" : \"false\")", value)
+		case datatype.IsDatatype(common.StringDatatype{}):
+			format += "%s"
+		default:
+			return codeGeneratorError(
+				fmt.Sprintf("cannot print a value of type %v", common.DatatypeName(datatype)),
+			)
+		}
+		arguments = append(arguments, value)
 	}
 
-	fmt.Fprintf(codes, "%v;", line)
-	return []string{}, nil
+	if format == "" {
+		// print()
+		return nil
+	}
+	fmt.Fprintf(codes, "printf(\"%v\"", format)
+	for _, argument := range arguments {
+		fmt.Fprintf(codes, ", %v", argument)
+	}
+	codes.WriteString(");")
+	return nil
 }
 
 func writeStart(codes *strings.Builder) {
 	codes.WriteString("#include <stdio.h>\n")
 	codes.WriteString("#include <stdbool.h>\n")
+	codes.WriteString("#include <stdint.h>\n")
 	codes.WriteString("#include <string.h>\n")
+	codes.WriteString("#include <stdlib.h>\n")
 
+	// Strings are never changed once made, so they are shared rather than copied.
+	// A literal lives for the whole program,
+	// and the result of a concatenation is kept until the program exits, when it is freed.
 	codes.WriteString(`
-void copy__str(char** dest, char** src, long long length) {
-	for (long long i = 0; i < length; i++) {
-		strncpy(dest[i], src[i], 1022);
-		dest[i][1023] = '\0';
+char** strings__ = NULL;
+long long strings__count = 0;
+long long strings__capacity = 0;
+
+void free__str(void) {
+	for (long long i = 0; i < strings__count; i++) {
+		free(strings__[i]);
 	}
+	free(strings__);
 }
-	`)
+
+char* concat__str(const char* first, const char* second) {
+	size_t firstLength = strlen(first);
+	size_t secondLength = strlen(second);
+	char* result = malloc(firstLength + secondLength + 1);
+	if (strings__count == strings__capacity) {
+		if (strings__capacity == 0) {
+			atexit(free__str);
+		}
+		strings__capacity = strings__capacity * 2 + 16;
+		strings__ = realloc(strings__, strings__capacity * sizeof(char*));
+	}
+	if (result == NULL || strings__ == NULL) {
+		fprintf(stderr, "out of memory\n");
+		exit(1);
+	}
+	memcpy(result, first, firstLength);
+	memcpy(result + firstLength, second, secondLength + 1);
+	strings__[strings__count++] = result;
+	return result;
+}
+`)
 
 	representations := map[string]string{
 		"bool":      "b",
 		"char":      "c",
 		"long long": "l",
 		"double":    "d",
+		"char*":     "str",
+		"int8_t":    "i8",
+		"int16_t":   "i16",
+		"int32_t":   "i32",
+		"int64_t":   "i64",
+		"uint8_t":   "u8",
+		"uint16_t":  "u16",
+		"uint32_t":  "u32",
+		"uint64_t":  "u64",
+		"float":     "f32",
 	}
 
 	for datatype, representation := range representations {
@@ -185,11 +618,6 @@ void copy__%v(%v* dest, %v* src, long long length) {
 	}
 }
 
-func indexFromIdentifier(identifier string) int {
-	i, _ := strconv.Atoi(identifier[2:])
-	return i
-}
-
 func codeGeneratorError(message string) *common.InternalError {
 	return &common.InternalError{
 		PointOfFailure: "Code Generator",
@@ -0,0 +1,168 @@
+package backend
+
+import (
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/ir"
+)
+
+// Removes the assignments whose value is never read, returns true if there were any.
+// Calls are kept for what they do, and so are the operations that could stop the program.
+func removeDeadAssignments(
+	graph *ControlFlowGraph,
+	identifiers []common.IdentifierInformation,
+) bool {
+	changed := false
+	analysis := livenessAnalysis(*graph, identifiers)
+
+	for index, block := range graph.Blocks {
+		live := copyLiveSet(analysis.out[index])
+		kept := []ir.Instruction{}
+
+		for position := len(block.Instructions) - 1; position >= 0; position-- {
+			code := block.Instructions[position]
+			destination, ok := definedIdentifier(code)
+			if ok && !live[destination] && isRemovable(code, identifiers) {
+				changed = true
+				continue
+			}
+			updateLiveSet(live, code, identifiers)
+			kept = append(kept, code)
+		}
+
+		// kept is in reverse
+		for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+			kept[i], kept[j] = kept[j], kept[i]
+		}
+		graph.Blocks[index].Instructions = kept
+	}
+	return changed
+}
+
+// whether the instruction does nothing but assign a scalar
+func isRemovable(code ir.Instruction, identifiers []common.IdentifierInformation) bool {
+	destination, _ := definedIdentifier(code)
+	if _, ok := scalarKind(identifiers[destination].Datatype); !ok {
+		return false
+	}
+
+	switch code := code.(type) {
+	case ir.Assign, ir.UnOp:
+		return true
+
+	case ir.BinOp:
+		if code.Operator != ir.Divide && code.Operator != ir.Modulo {
+			return true
+		}
+		// a division by zero is left for the program to run into
+		divisor, ok := operandValue(code.Second)
+		if !ok {
+			return false
+		}
+		nonZero, _ := convertValue(kindBool, divisor).(bool)
+		return nonZero
+	}
+	// array accesses can go out of bounds, and calls can do anything
+	return false
+}
+
+// blocks that no path from the entry reaches are removed, returns true if there were any
+func removeUnreachableBlocks(graph *ControlFlowGraph) bool {
+	reachable := make([]bool, len(graph.Blocks))
+	pending := []int{0}
+	reachable[0] = true
+	for len(pending) > 0 {
+		block := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, successor := range graph.Blocks[block].Successors {
+			if !reachable[successor] {
+				reachable[successor] = true
+				pending = append(pending, successor)
+			}
+		}
+	}
+
+	// the indices of the blocks change, so the edges are renumbered
+	newIndex := make([]int, len(graph.Blocks))
+	blocks := []BasicBlock{}
+	for index, block := range graph.Blocks {
+		if reachable[index] {
+			newIndex[index] = len(blocks)
+			blocks = append(blocks, block)
+		}
+	}
+	if len(blocks) == len(graph.Blocks) {
+		return false
+	}
+
+	for index := range blocks {
+		blocks[index].Successors = renumberBlocks(blocks[index].Successors, reachable, newIndex)
+		blocks[index].Predecessors = renumberBlocks(blocks[index].Predecessors, reachable, newIndex)
+	}
+	graph.Blocks = blocks
+	return true
+}
+
+func renumberBlocks(edges []int, reachable []bool, newIndex []int) []int {
+	result := []int{}
+	for _, edge := range edges {
+		if reachable[edge] {
+			result = append(result, newIndex[edge])
+		}
+	}
+	return result
+}
+
+// Removes the jumps to the label right after them, and then the labels that are never jumped to.
+// Returns true if anything was removed.
+func removeRedundantJumps(codes []ir.Instruction) ([]ir.Instruction, bool) {
+	changed := false
+	withoutJumps := []ir.Instruction{}
+	for index, code := range codes {
+		label := ""
+		switch code := code.(type) {
+		case ir.Jump:
+			label = code.Label
+		case ir.CondJump:
+			label = code.Label
+		}
+		if label != "" && labelFollows(codes[index+1:], label) {
+			changed = true
+			continue
+		}
+		withoutJumps = append(withoutJumps, code)
+	}
+
+	used := map[string]bool{}
+	for _, code := range withoutJumps {
+		switch code := code.(type) {
+		case ir.Jump:
+			used[code.Label] = true
+		case ir.CondJump:
+			used[code.Label] = true
+		}
+	}
+
+	result := []ir.Instruction{}
+	for _, code := range withoutJumps {
+		if label, ok := code.(ir.Label); ok && !used[label.Name] {
+			changed = true
+			continue
+		}
+		result = append(result, code)
+	}
+	return result, changed
+}
+
+// whether the label is among the labels at the start of the codes
+func labelFollows(codes []ir.Instruction, name string) bool {
+	for _, code := range codes {
+		label, ok := code.(ir.Label)
+		if !ok {
+			return false
+		}
+		if label.Name == name {
+			return true
+		}
+	}
+	return false
+}
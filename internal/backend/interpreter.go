@@ -0,0 +1,1212 @@
+package backend
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+)
+
+// deep enough for any sensible recursion, while keeping runaway recursion from eating the memory
+const maxCallDepth = 10000
+
+// Runs the program straight from its AST, without going through C.
+// The behaviour is meant to match that of the generated C code.
+//
+// Values are held as
+//   - int64 for int
+//   - float64 for float
+//   - int8 for char, since char is signed in C
+//   - bool for bool
+//   - string for strings
+//   - []any for arrays
+func Interpreter(
+	input common.ProgramAST,
+	identifiers []common.IdentifierInformation,
+	reader io.Reader,
+	writer io.Writer,
+) error {
+	run := interpreter{
+		identifiers: identifiers,
+		functions:   map[int]common.FunctionAST{},
+		input:       bufio.NewReader(reader),
+		output:      bufio.NewWriter(writer),
+	}
+	defer run.output.Flush()
+
+	run.collectFunctions(input)
+	_, _, err := run.executeProgram(input, frame{})
+	return err
+}
+
+// This error is returned when the interpreted program fails while running.
+// This usually points to a mistake in the program, such as a division by zero.
+type RuntimeError struct {
+	// 0 when the line is not known, e.g., when running bytecode
+	LineNumber int
+	Message    string
+	// the statement that was being run, if it is known
+	Span common.Span
+}
+
+func (e *RuntimeError) Error() string {
+	if e.LineNumber == 0 {
+		return fmt.Sprintf("Runtime Error: %v", e.Message)
+	}
+	return fmt.Sprintf("Runtime Error: %v (line number %v)", e.Message, e.LineNumber)
+}
+
+type interpreter struct {
+	identifiers []common.IdentifierInformation
+	functions   map[int]common.FunctionAST
+
+	input  *bufio.Reader
+	output *bufio.Writer
+
+	// the statement being run, for error messages
+	span      common.Span
+	callDepth int
+}
+
+// the values of the variables of a single function call, or of the main program
+type frame map[int]any
+
+// what the program should do after a statement
+type flow int
+
+const (
+	flowNext flow = iota
+	flowReturn
+	// the value is the loop that is left or continued
+	flowBreak
+	flowContinue
+)
+
+func (r *interpreter) collectFunctions(program common.ProgramAST) {
+	for _, instruction := range program.Instructions {
+		switch statement := instruction.(type) {
+		case common.FunctionAST:
+			r.functions[statement.Function] = statement
+			r.collectFunctions(statement.Program)
+
+		case common.IfStatementAST:
+			for _, ifExpression := range statement.IfExpressions {
+				r.collectFunctions(ifExpression.Program)
+			}
+
+		case common.WhileStatementAST:
+			r.collectFunctions(statement.Program)
+
+		case common.ForStatementAST:
+			r.collectFunctions(statement.Program)
+		}
+	}
+}
+
+func (r *interpreter) executeProgram(program common.ProgramAST, variables frame) (flow, any, error) {
+	for _, instruction := range program.Instructions {
+		result, value, err := r.executeInstruction(instruction, variables)
+		if err != nil || result != flowNext {
+			return result, value, err
+		}
+	}
+	return flowNext, nil, nil
+}
+
+func (r *interpreter) executeInstruction(
+	instruction common.InstructionAST,
+	variables frame,
+) (flow, any, error) {
+	switch statement := instruction.(type) {
+	case common.AssignmentAST:
+		r.span = statement.Span
+		return flowNext, nil, r.assign(statement, variables)
+
+	case common.IfStatementAST:
+		r.span = statement.Span
+		for _, ifExpression := range statement.IfExpressions {
+			condition, err := r.evaluateCondition(ifExpression.Condition, variables)
+			if err != nil {
+				return flowNext, nil, err
+			}
+			if condition {
+				return r.executeProgram(ifExpression.Program, variables)
+			}
+		}
+		return flowNext, nil, nil
+
+	case common.WhileStatementAST:
+		for {
+			r.span = statement.Span
+			condition, err := r.evaluateCondition(statement.Condition, variables)
+			if err != nil || !condition {
+				return flowNext, nil, err
+			}
+			result, value, err := r.executeProgram(statement.Program, variables)
+			if done, result := endsLoop(statement.Loop, result, value); err != nil || done {
+				return result, value, err
+			}
+		}
+
+	case common.ForStatementAST:
+		r.span = statement.Span
+		if statement.Array != nil {
+			return r.forArray(statement, variables)
+		}
+		return r.forRange(statement, variables)
+
+	case common.OutputStatementAST:
+		r.span = statement.Span
+		if statement.Function != common.OutputPrintf {
+			return flowNext, nil, r.print(statement, variables)
+		}
+		return flowNext, nil, r.printf(statement, variables)
+
+	case common.FunctionAST:
+		// functions are collected before running the program
+		return flowNext, nil, nil
+
+	case common.ReturnAST:
+		r.span = statement.Span
+		if statement.Value == nil {
+			return flowReturn, nil, nil
+		}
+		value, err := r.evaluate(statement.Value, variables)
+		return flowReturn, value, err
+
+	case common.CallStatementAST:
+		r.span = statement.Span
+		_, err := r.call(statement.Call, variables)
+		return flowNext, nil, err
+
+	case common.ArrayDeclarationAST:
+		variables[statement.Identifier] = zeroValue(r.identifiers[statement.Identifier].Datatype)
+		return flowNext, nil, nil
+
+	case common.BreakAST:
+		return flowBreak, statement.Loop, nil
+
+	case common.ContinueAST:
+		return flowContinue, statement.Loop, nil
+
+	default:
+		return flowNext, nil, interpreterError("unknown instruction")
+	}
+}
+
+func (r *interpreter) assign(assignment common.AssignmentAST, variables frame) error {
+	value, err := r.evaluate(assignment.AssignValue, variables)
+	if err != nil {
+		return err
+	}
+	datatype := r.identifiers[assignment.AssignToIdentifier].Datatype
+	if len(assignment.ArrayValues) == 0 {
+		// e.g., let x: float = 1;
+		variables[assignment.AssignToIdentifier] = convertTo(datatype, copyValue(value))
+		return nil
+	}
+
+	target, ok := variables[assignment.AssignToIdentifier]
+	if !ok {
+		return r.runtimeError("array used before being assigned a value")
+	}
+	for index, access := range assignment.ArrayValues {
+		array, ok := target.([]any)
+		if !ok {
+			return interpreterError("array access on a non-array")
+		}
+		position, err := r.evaluateIndex(access, array, variables)
+		if err != nil {
+			return err
+		}
+		if arrayDatatype, ok := datatype.(common.ArrayDatatype); ok {
+			datatype = arrayDatatype.ElementType
+		}
+		if index == len(assignment.ArrayValues)-1 {
+			array[position] = convertTo(datatype, copyValue(value))
+			return nil
+		}
+		target = array[position]
+	}
+	return nil
+}
+
+// Whether the loop stops after running its body, and with what flow.
+// A break or continue of an outer loop stops this one as well, and is passed on.
+func endsLoop(loop *common.Loop, result flow, value any) (bool, flow) {
+	switch result {
+	case flowNext:
+		return false, flowNext
+	case flowBreak, flowContinue:
+		if value == loop {
+			return result == flowBreak, flowNext
+		}
+	}
+	return true, result
+}
+
+func (r *interpreter) forRange(statement common.ForStatementAST, variables frame) (flow, any, error) {
+	bounds := []int64{}
+	for _, bound := range []common.ExpressionAST{statement.Start, statement.End} {
+		value, err := r.evaluate(bound, variables)
+		if err != nil {
+			return flowNext, nil, err
+		}
+		number, ok := value.(int64)
+		if !ok {
+			return flowNext, nil, interpreterError("range bound is not an int")
+		}
+		bounds = append(bounds, number)
+	}
+	step := int64(1)
+	if statement.Step != nil {
+		value, err := r.evaluate(statement.Step, variables)
+		if err != nil {
+			return flowNext, nil, err
+		}
+		number, ok := value.(int64)
+		if !ok || number == 0 {
+			return flowNext, nil, interpreterError("step is not a non-zero int")
+		}
+		step = number
+	}
+
+	for current, end := bounds[0], bounds[1]; ; current += step {
+		if step > 0 && (current > end || current == end && !statement.Inclusive) ||
+			step < 0 && (current < end || current == end && !statement.Inclusive) {
+			return flowNext, nil, nil
+		}
+		variables[statement.Variable] = current
+		result, value, err := r.executeProgram(statement.Program, variables)
+		if done, result := endsLoop(statement.Loop, result, value); err != nil || done {
+			return result, value, err
+		}
+		r.span = statement.Span
+		// a step past the int range is past the end as well
+		if step > 0 && current > math.MaxInt64-step || step < 0 && current < math.MinInt64-step {
+			return flowNext, nil, nil
+		}
+	}
+}
+
+func (r *interpreter) forArray(statement common.ForStatementAST, variables frame) (flow, any, error) {
+	value, err := r.evaluate(statement.Array, variables)
+	if err != nil {
+		return flowNext, nil, err
+	}
+	// the body may change the array, but the loop goes over it as it was
+	array, ok := copyValue(value).([]any)
+	if !ok {
+		return flowNext, nil, interpreterError("for over a non-array")
+	}
+	for _, element := range array {
+		variables[statement.Variable] = element
+		result, value, err := r.executeProgram(statement.Program, variables)
+		if done, result := endsLoop(statement.Loop, result, value); err != nil || done {
+			return result, value, err
+		}
+	}
+	return flowNext, nil, nil
+}
+
+func (r *interpreter) printf(output common.OutputStatementAST, variables frame) error {
+	arguments := []any{}
+	for _, argument := range output.Arguments {
+		value, err := r.evaluate(argument, variables)
+		if err != nil {
+			return err
+		}
+		arguments = append(arguments, value)
+	}
+	format, ok := arguments[0].(string)
+	if !ok {
+		return interpreterError("printf without a format string")
+	}
+	text, err := formatOutput(format, arguments[1:])
+	if err != nil {
+		return r.runtimeError(err.Error())
+	}
+	r.output.WriteString(text)
+	return nil
+}
+
+func (r *interpreter) print(output common.OutputStatementAST, variables frame) error {
+	for index, argument := range output.Arguments {
+		value, err := r.evaluate(argument, variables)
+		if err != nil {
+			return err
+		}
+		if index > 0 {
+			r.output.WriteString(" ")
+		}
+		r.output.WriteString(formatValue(value))
+	}
+	if output.Function == common.OutputPrintln {
+		r.output.WriteString("\n")
+	}
+	return nil
+}
+
+func (r *interpreter) evaluateCondition(
+	condition common.ExpressionAST,
+	variables frame,
+) (bool, error) {
+	value, err := r.evaluate(condition, variables)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, interpreterError("non-boolean condition")
+	}
+	return result, nil
+}
+
+func (r *interpreter) evaluateIndex(
+	access common.ExpressionAST,
+	array []any,
+	variables frame,
+) (int, error) {
+	value, err := r.evaluate(access, variables)
+	if err != nil {
+		return 0, err
+	}
+	position, ok := toInteger(value)
+	if !ok {
+		return 0, interpreterError("non-integer array index")
+	}
+	if position < 0 || position >= int64(len(array)) {
+		return 0, r.runtimeError(fmt.Sprintf(
+			"array index %v out of bounds for an array of %v elements",
+			position,
+			len(array),
+		))
+	}
+	return int(position), nil
+}
+
+func (r *interpreter) evaluate(expression common.ExpressionAST, variables frame) (any, error) {
+	switch e := expression.(type) {
+	case common.Literal:
+		value, err := literalValue(e)
+		if err != nil {
+			return nil, r.runtimeError(err.Error())
+		}
+		return value, nil
+
+	case common.Identifier:
+		value, ok := variables[e.Id]
+		if !ok {
+			return nil, r.runtimeError(fmt.Sprintf(
+				"%v used before being assigned a value",
+				r.identifiers[e.Id].IdentifierName,
+			))
+		}
+		for _, access := range e.ArrayValues {
+			if text, ok := value.(string); ok {
+				return r.evaluateCharacter(access, text, variables)
+			}
+			array, ok := value.([]any)
+			if !ok {
+				return nil, interpreterError("array access on a non-array")
+			}
+			position, err := r.evaluateIndex(access, array, variables)
+			if err != nil {
+				return nil, err
+			}
+			value = array[position]
+		}
+		return value, nil
+
+	case common.ArrayExpression:
+		datatype, err := e.GetDatatype(r.identifiers)
+		if err != nil {
+			return nil, err
+		}
+		// e.g., the 2 and 3 of [1u8, 2, 3] are u8
+		elementDatatype := datatype.(common.ArrayDatatype).ElementType
+		elements := []any{}
+		for _, element := range e.Elements {
+			value, err := r.evaluate(element, variables)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, convertTo(elementDatatype, copyValue(value)))
+		}
+		return elements, nil
+
+	case common.UnaryExpression:
+		operand, err := r.evaluate(e.Operand, variables)
+		if err != nil {
+			return nil, err
+		}
+		return unaryOperation(e.Operator, operand)
+
+	case common.BinaryExpression:
+		first, err := r.evaluate(e.FirstOperand, variables)
+		if err != nil {
+			return nil, err
+		}
+		// && and || only evaluate the second operand when it is needed
+		if e.Operator == common.BinaryAnd || e.Operator == common.BinaryOr {
+			firstResult, ok := first.(bool)
+			if !ok {
+				return nil, interpreterError("non-boolean operand in a logical expression")
+			}
+			if firstResult == (e.Operator == common.BinaryOr) {
+				return firstResult, nil
+			}
+			return r.evaluate(e.SecondOperand, variables)
+		}
+		second, err := r.evaluate(e.SecondOperand, variables)
+		if err != nil {
+			return nil, err
+		}
+		value, err := binaryOperation(e.Operator, first, second)
+		if err != nil {
+			return nil, r.runtimeError(err.Error())
+		}
+		return value, nil
+
+	case common.InputExpression:
+		// whatever has been printed should be visible before waiting on the user
+		r.output.Flush()
+		c, err := r.input.ReadByte()
+		if err == io.EOF {
+			// fgetc returns EOF, which becomes -1 once stored in a char
+			return int8(-1), nil
+		} else if err != nil {
+			return nil, r.runtimeError(err.Error())
+		}
+		return int8(c), nil
+
+	case common.CallExpression:
+		return r.call(e, variables)
+
+	case common.LengthExpression:
+		operand, err := r.evaluate(e.Operand, variables)
+		if err != nil {
+			return nil, err
+		}
+		switch operand := operand.(type) {
+		case string:
+			return int64(len(operand)), nil
+		case []any:
+			return int64(len(operand)), nil
+		}
+		return nil, interpreterError("len of a value that is neither a string nor an array")
+
+	case common.CastExpression:
+		operand, err := r.evaluate(e.Operand, variables)
+		if err != nil {
+			return nil, err
+		}
+		datatype, ok := e.Datatype.(common.PrimitiveDatatype)
+		if !ok {
+			return nil, interpreterError("cast to a non-primitive datatype")
+		}
+		// the same conversions as the vm makes when assigning to a register
+		return convertValue(primitiveKind(datatype), operand), nil
+
+	default:
+		return nil, interpreterError("unknown expression")
+	}
+}
+
+func (r *interpreter) evaluateCharacter(
+	access common.ExpressionAST,
+	text string,
+	variables frame,
+) (any, error) {
+	value, err := r.evaluate(access, variables)
+	if err != nil {
+		return nil, err
+	}
+	position, ok := toInteger(value)
+	if !ok {
+		return nil, interpreterError("non-integer string index")
+	}
+	if position < 0 || position >= int64(len(text)) {
+		return nil, r.runtimeError(fmt.Sprintf(
+			"string index %v out of bounds for a string of %v characters",
+			position,
+			len(text),
+		))
+	}
+	return int8(text[position]), nil
+}
+
+func (r *interpreter) call(call common.CallExpression, variables frame) (any, error) {
+	function, ok := r.functions[call.Function]
+	if !ok {
+		return nil, interpreterError("call to an unknown function")
+	}
+
+	callee := frame{}
+	for index, argument := range call.Arguments {
+		value, err := r.evaluate(argument, variables)
+		if err != nil {
+			return nil, err
+		}
+		parameter := function.Parameters[index]
+		callee[parameter] = convertTo(r.identifiers[parameter].Datatype, copyValue(value))
+	}
+
+	if r.callDepth >= maxCallDepth {
+		return nil, r.runtimeError("maximum call depth exceeded")
+	}
+	r.callDepth++
+	span := r.span
+
+	_, value, err := r.executeProgram(function.Program, callee)
+
+	r.callDepth--
+	r.span = span
+	if functionDatatype, ok := r.identifiers[call.Function].Datatype.(common.FunctionDatatype); ok &&
+		value != nil {
+		value = convertTo(functionDatatype.ReturnType, value)
+	}
+	return value, err
+}
+
+// the value of an array declared without a value, whose elements are 0, false or '\0'
+func zeroValue(datatype common.Datatype) any {
+	switch datatype := datatype.(type) {
+	case common.ArrayDatatype:
+		elements := make([]any, datatype.NumberOfElements)
+		for index := range elements {
+			elements[index] = zeroValue(datatype.ElementType)
+		}
+		return elements
+
+	case common.PrimitiveDatatype:
+		switch datatype {
+		case common.TypedFloat:
+			return float64(0)
+		case common.TypedChar:
+			return int8(0)
+		case common.TypedBool:
+			return false
+		}
+		return convertTo(datatype, int64(0))
+	}
+	return int64(0)
+}
+
+// the value as a variable of the datatype holds it, e.g., 1 is 1.0 in a float, and 300 is 44 in a u8
+func convertTo(datatype common.Datatype, value any) any {
+	if primitive, ok := datatype.(common.PrimitiveDatatype); ok {
+		return convertValue(primitiveKind(primitive), value)
+	}
+	return value
+}
+
+func (r *interpreter) runtimeError(message string) *RuntimeError {
+	return &RuntimeError{
+		LineNumber: r.span.StartLine,
+		Span:       r.span,
+		Message:    message,
+	}
+}
+
+func interpreterError(message string) *common.InternalError {
+	return &common.InternalError{
+		PointOfFailure: "Interpreter",
+		Message:        message,
+	}
+}
+
+func literalValue(literal common.Literal) (any, error) {
+	switch datatype := literal.Datatype.(type) {
+	case common.PrimitiveDatatype:
+		switch datatype {
+		case common.TypedInt:
+			value, err := strconv.ParseInt(literal.Value, 10, 64)
+			if err != nil {
+				// a literal too large for an int is given to a u64, or negated into the smallest int,
+				// so it is kept by its bits
+				bits, bitsErr := strconv.ParseUint(literal.Value, 10, 64)
+				return int64(bits), bitsErr
+			}
+			return value, nil
+
+		case common.TypedFloat:
+			return strconv.ParseFloat(literal.Value, 64)
+
+		case common.TypedBool:
+			return literal.Value == "true", nil
+
+		case common.TypedF32:
+			value, err := strconv.ParseFloat(literal.Value, 32)
+			return float32(value), err
+
+		case common.TypedChar:
+			characters := unescape(literal.Value[1 : len(literal.Value)-1])
+			if len(characters) != 1 {
+				return nil, errors.New("invalid character literal " + literal.Value)
+			}
+			return int8(characters[0]), nil
+		}
+		if datatype.IsSized() {
+			value, err := strconv.ParseUint(literal.Value, 10, 64)
+			return wrapInteger(datatype, int64(value)), err
+		}
+
+	case common.StringDatatype:
+		return unescape(literal.Value[1 : len(literal.Value)-1]), nil
+	}
+	return nil, errors.New("literal of an unknown datatype")
+}
+
+// resolves the escape sequences of a C string or character literal
+func unescape(literal string) string {
+	result := strings.Builder{}
+	for i := 0; i < len(literal); i++ {
+		if literal[i] != '\\' || i == len(literal)-1 {
+			result.WriteByte(literal[i])
+			continue
+		}
+		i++
+		switch literal[i] {
+		case 'n':
+			result.WriteByte('\n')
+		case 't':
+			result.WriteByte('\t')
+		case 'r':
+			result.WriteByte('\r')
+		case 'a':
+			result.WriteByte('\a')
+		case 'b':
+			result.WriteByte('\b')
+		case 'f':
+			result.WriteByte('\f')
+		case 'v':
+			result.WriteByte('\v')
+		case 'x':
+			// \x followed by hexadecimal digits
+			end := i + 1
+			for end < len(literal) && strings.IndexByte("0123456789abcdefABCDEF", literal[end]) >= 0 {
+				end++
+			}
+			value, _ := strconv.ParseUint(literal[i+1:end], 16, 64)
+			result.WriteByte(byte(value))
+			i = end - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// up to three octal digits
+			end := i
+			for end < len(literal) && end < i+3 && literal[end] >= '0' && literal[end] <= '7' {
+				end++
+			}
+			value, _ := strconv.ParseUint(literal[i:end], 8, 64)
+			result.WriteByte(byte(value))
+			i = end - 1
+		default:
+			// \\, \', \" and \? stand for themselves
+			result.WriteByte(literal[i])
+		}
+	}
+	return result.String()
+}
+
+func copyValue(value any) any {
+	array, ok := value.([]any)
+	if !ok {
+		return value
+	}
+	result := make([]any, len(array))
+	for index, element := range array {
+		result[index] = copyValue(element)
+	}
+	return result
+}
+
+// A value of a sized integer, kept the way C would hold it, e.g., 300 in a u8 is 44.
+// A u64 is kept by its bits, so that the largest ones look negative.
+type sizedInteger struct {
+	datatype common.PrimitiveDatatype
+	value    int64
+}
+
+// wraps the value around to the size of the datatype
+func wrapInteger(datatype common.PrimitiveDatatype, value int64) sizedInteger {
+	shift := 64 - datatype.Bits()
+	if datatype.IsUnsigned() {
+		return sizedInteger{datatype: datatype, value: int64(uint64(value) << shift >> shift)}
+	}
+	return sizedInteger{datatype: datatype, value: value << shift >> shift}
+}
+
+// the datatype of a value of a sized number, or false for every other value
+func sizedDatatype(value any) (common.PrimitiveDatatype, bool) {
+	switch value := value.(type) {
+	case sizedInteger:
+		return value.datatype, true
+	case float32:
+		return common.TypedF32, true
+	}
+	return common.TypedUnknown, false
+}
+
+func unaryOperation(operator common.UnaryOperatorNode, operand any) (any, error) {
+	switch operator {
+	case common.UnaryMinus:
+		switch value := operand.(type) {
+		case int64:
+			return -value, nil
+		case float64:
+			return -value, nil
+		case float32:
+			return -value, nil
+		case sizedInteger:
+			return wrapInteger(value.datatype, -value.value), nil
+		}
+
+	case common.UnaryNot:
+		if value, ok := operand.(bool); ok {
+			return !value, nil
+		}
+	}
+	return nil, interpreterError("unsupported unary operation")
+}
+
+func binaryOperation(operator common.BinaryOperatorNode, first, second any) (any, error) {
+	if firstString, ok := first.(string); ok {
+		secondString, ok := second.(string)
+		if !ok {
+			return nil, errors.New("unsupported operands")
+		}
+		return stringOperation(operator, firstString, secondString)
+	}
+	if result, ok, err := sizedOperation(operator, first, second); ok {
+		return result, err
+	}
+
+	_, firstIsFloat := first.(float64)
+	_, secondIsFloat := second.(float64)
+	// as in C, the operands are promoted to double if either of them is one
+	if firstIsFloat || secondIsFloat {
+		firstValue, _ := toFloat(first)
+		secondValue, _ := toFloat(second)
+		return floatOperation(operator, firstValue, secondValue)
+	}
+
+	firstValue, firstOk := toInteger(first)
+	secondValue, secondOk := toInteger(second)
+	if !firstOk || !secondOk {
+		return nil, errors.New("unsupported operands")
+	}
+	return integerOperation(operator, firstValue, secondValue)
+}
+
+// The operands are worked out in the datatype they are promoted to, when either of them is a sized number.
+// Returns false if neither of them is one.
+func sizedOperation(operator common.BinaryOperatorNode, first, second any) (any, bool, error) {
+	firstDatatype, firstSized := sizedDatatype(first)
+	secondDatatype, secondSized := sizedDatatype(second)
+	if !firstSized && !secondSized {
+		return nil, false, nil
+	}
+	if !firstSized {
+		firstDatatype = valueDatatype(first)
+	}
+	if !secondSized {
+		secondDatatype = valueDatatype(second)
+	}
+	datatype, err := common.Promote(firstDatatype, secondDatatype)
+	if err != nil {
+		return nil, true, errors.New("unsupported operands")
+	}
+
+	switch datatype {
+	case common.TypedFloat:
+		firstValue, _ := toFloat(first)
+		secondValue, _ := toFloat(second)
+		result, err := floatOperation(operator, firstValue, secondValue)
+		return result, true, err
+
+	case common.TypedF32:
+		firstValue, _ := toFloat32(first)
+		secondValue, _ := toFloat32(second)
+		// an operation on two f32 is exact as a float, and only rounded once it is an f32 again
+		result, err := floatOperation(operator, float64(firstValue), float64(secondValue))
+		if float, ok := result.(float64); ok {
+			return float32(float), true, err
+		}
+		return result, true, err
+	}
+
+	firstValue, _ := toInteger(first)
+	secondValue, _ := toInteger(second)
+	firstValue = wrapInteger(datatype, firstValue).value
+	secondValue = wrapInteger(datatype, secondValue).value
+	var result any
+	if datatype == common.TypedU64 {
+		result, err = unsignedOperation(operator, uint64(firstValue), uint64(secondValue))
+	} else {
+		result, err = integerOperation(operator, firstValue, secondValue)
+	}
+	if integer, ok := result.(int64); ok {
+		return wrapInteger(datatype, integer), true, err
+	}
+	return result, true, err
+}
+
+// the datatype of a value that is not of a sized number
+func valueDatatype(value any) common.PrimitiveDatatype {
+	switch value.(type) {
+	case int64:
+		return common.TypedInt
+	case float64:
+		return common.TypedFloat
+	case int8:
+		return common.TypedChar
+	case bool:
+		return common.TypedBool
+	}
+	return common.TypedUnknown
+}
+
+// only the division, the modulo and the comparisons of a u64 differ from those of an int
+func unsignedOperation(operator common.BinaryOperatorNode, first, second uint64) (any, error) {
+	switch operator {
+	case common.BinaryDiv:
+		if second == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return int64(first / second), nil
+	case common.BinaryModulo:
+		if second == 0 {
+			return nil, errors.New("modulo by zero")
+		}
+		return int64(first % second), nil
+
+	case common.BinaryRelationalGreaterThan:
+		return first > second, nil
+	case common.BinaryRelationalGreaterThanOrEquals:
+		return first >= second, nil
+	case common.BinaryRelationalLesserThan:
+		return first < second, nil
+	case common.BinaryRelationalLesserThanOrEquals:
+		return first <= second, nil
+	}
+	return integerOperation(operator, int64(first), int64(second))
+}
+
+func stringOperation(operator common.BinaryOperatorNode, first, second string) (any, error) {
+	switch operator {
+	case common.BinaryPlus:
+		return first + second, nil
+	case common.BinaryRelationalEquals:
+		return first == second, nil
+	case common.BinaryRelationalNotEquals:
+		return first != second, nil
+	}
+	return nil, errors.New("unsupported string operation")
+}
+
+func integerOperation(operator common.BinaryOperatorNode, first, second int64) (any, error) {
+	switch operator {
+	case common.BinaryPlus:
+		return first + second, nil
+	case common.BinaryMinus:
+		return first - second, nil
+	case common.BinaryMul:
+		return first * second, nil
+	case common.BinaryDiv:
+		if second == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return first / second, nil
+	case common.BinaryModulo:
+		if second == 0 {
+			return nil, errors.New("modulo by zero")
+		}
+		return first % second, nil
+
+	case common.BinaryRelationalEquals:
+		return first == second, nil
+	case common.BinaryRelationalNotEquals:
+		return first != second, nil
+	case common.BinaryRelationalGreaterThan:
+		return first > second, nil
+	case common.BinaryRelationalGreaterThanOrEquals:
+		return first >= second, nil
+	case common.BinaryRelationalLesserThan:
+		return first < second, nil
+	case common.BinaryRelationalLesserThanOrEquals:
+		return first <= second, nil
+	}
+	return nil, errors.New("unsupported binary operation")
+}
+
+func floatOperation(operator common.BinaryOperatorNode, first, second float64) (any, error) {
+	switch operator {
+	case common.BinaryPlus:
+		return first + second, nil
+	case common.BinaryMinus:
+		return first - second, nil
+	case common.BinaryMul:
+		return first * second, nil
+	case common.BinaryDiv:
+		return first / second, nil
+	case common.BinaryModulo:
+		return math.Mod(first, second), nil
+
+	case common.BinaryRelationalEquals:
+		return first == second, nil
+	case common.BinaryRelationalNotEquals:
+		return first != second, nil
+	case common.BinaryRelationalGreaterThan:
+		return first > second, nil
+	case common.BinaryRelationalGreaterThanOrEquals:
+		return first >= second, nil
+	case common.BinaryRelationalLesserThan:
+		return first < second, nil
+	case common.BinaryRelationalLesserThanOrEquals:
+		return first <= second, nil
+	}
+	return nil, errors.New("unsupported binary operation")
+}
+
+// chars and bools take part in integer arithmetic with their numeric values, like in C
+func toInteger(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case sizedInteger:
+		return v.value, true
+	case int8:
+		return int64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case sizedInteger:
+		if v.datatype == common.TypedU64 {
+			return float64(uint64(v.value)), true
+		}
+	}
+	v, ok := toInteger(value)
+	return float64(v), ok
+}
+
+// an integer is rounded to an f32 straight away, rather than by way of a float, as C does
+func toFloat32(value any) (float32, bool) {
+	switch v := value.(type) {
+	case float32:
+		return v, true
+	case float64:
+		return float32(v), true
+	case sizedInteger:
+		if v.datatype == common.TypedU64 {
+			return float32(uint64(v.value)), true
+		}
+	}
+	v, ok := toInteger(value)
+	return float32(v), ok
+}
+
+// Formats the value the way print does, e.g., [1.5, 2] for an array of floats.
+func formatValue(value any) string {
+	switch value := value.(type) {
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return formatFloat("%", 'g', value)
+	case float32:
+		return formatFloat("%", 'g', float64(value))
+	case sizedInteger:
+		if value.datatype.IsUnsigned() {
+			return strconv.FormatUint(uint64(value.value), 10)
+		}
+		return strconv.FormatInt(value.value, 10)
+	case int8:
+		return string([]byte{byte(value)})
+	case bool:
+		return strconv.FormatBool(value)
+	case string:
+		return value
+	case []any:
+		elements := []string{}
+		for _, element := range value {
+			elements = append(elements, formatValue(element))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	}
+	return fmt.Sprint(value)
+}
+
+// Formats the output the way printf would.
+// Supports the flags, width, precision and length modifiers of C,
+// with the d, i, u, x, X, o, c, s, f, F, e, E, g, G and % conversions.
+func formatOutput(format string, arguments []any) (string, error) {
+	output := strings.Builder{}
+	argumentIndex := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			output.WriteByte(format[i])
+			continue
+		}
+
+		end := i + 1
+		for end < len(format) && strings.IndexByte("-+ #0", format[end]) >= 0 {
+			end++
+		}
+		for end < len(format) && format[end] >= '0' && format[end] <= '9' {
+			end++
+		}
+		if end < len(format) && format[end] == '.' {
+			end++
+			for end < len(format) && format[end] >= '0' && format[end] <= '9' {
+				end++
+			}
+		}
+		// flags, width and precision mean the same thing to Go
+		specification := format[i:end]
+
+		lengthStart := end
+		for end < len(format) && strings.IndexByte("hlLqjzt", format[end]) >= 0 {
+			end++
+		}
+		length := format[lengthStart:end]
+
+		if end >= len(format) {
+			// an incomplete conversion is printed as is
+			output.WriteString(format[i:])
+			break
+		}
+		conversion := format[end]
+		directive := format[i : end+1]
+		i = end
+
+		if conversion == '%' {
+			output.WriteByte('%')
+			continue
+		}
+		if argumentIndex >= len(arguments) {
+			return "", fmt.Errorf("printf is missing an argument for %v", directive)
+		}
+		argument := arguments[argumentIndex]
+		argumentIndex++
+
+		switch conversion {
+		case 'd', 'i':
+			value, ok := toInteger(argument)
+			if !ok {
+				return "", fmt.Errorf("%v expects an integer", directive)
+			}
+			fmt.Fprintf(&output, specification+"d", truncateSigned(value, length))
+
+		case 'u', 'x', 'X', 'o':
+			value, ok := toInteger(argument)
+			if !ok {
+				return "", fmt.Errorf("%v expects an integer", directive)
+			}
+			verb := conversion
+			if verb == 'u' {
+				verb = 'd'
+			}
+			fmt.Fprintf(&output, specification+string(verb), truncateUnsigned(value, length))
+
+		case 'c':
+			value, ok := toInteger(argument)
+			if !ok {
+				return "", fmt.Errorf("%v expects a character", directive)
+			}
+			fmt.Fprintf(&output, specification+"s", string([]byte{byte(value)}))
+
+		case 's':
+			value, ok := argument.(string)
+			if !ok {
+				return "", fmt.Errorf("%v expects a string", directive)
+			}
+			fmt.Fprintf(&output, specification+"s", value)
+
+		case 'f', 'F', 'e', 'E', 'g', 'G':
+			// an f32 is promoted to a double, as in C
+			value, ok := argument.(float64)
+			if float, isF32 := argument.(float32); isF32 {
+				value, ok = float64(float), true
+			}
+			if !ok {
+				return "", fmt.Errorf("%v expects a float", directive)
+			}
+			output.WriteString(formatFloat(specification, conversion, value))
+
+		default:
+			return "", fmt.Errorf("unsupported conversion %v in printf", directive)
+		}
+	}
+	return output.String(), nil
+}
+
+// values are passed as their own C type, and read back as the type of the conversion
+func truncateSigned(value int64, length string) int64 {
+	switch length {
+	case "hh":
+		return int64(int8(value))
+	case "h":
+		return int64(int16(value))
+	case "":
+		return int64(int32(value))
+	default:
+		return value
+	}
+}
+
+func truncateUnsigned(value int64, length string) uint64 {
+	switch length {
+	case "hh":
+		return uint64(uint8(value))
+	case "h":
+		return uint64(uint16(value))
+	case "":
+		return uint64(uint32(value))
+	default:
+		return uint64(value)
+	}
+}
+
+func formatFloat(specification string, conversion byte, value float64) string {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		// C spells these as inf and nan
+		text := "nan"
+		if math.IsInf(value, 1) {
+			text = "inf"
+		} else if math.IsInf(value, -1) {
+			text = "-inf"
+		}
+		if conversion >= 'A' && conversion <= 'Z' {
+			text = strings.ToUpper(text)
+		}
+		// the 0 flag and the precision do not apply to these
+		rest := strings.TrimLeft(specification[1:], "-+ #0")
+		width, _, _ := strings.Cut(rest, ".")
+		if strings.Contains(specification[1:len(specification)-len(rest)], "-") {
+			width = "-" + width
+		}
+		return fmt.Sprintf("%"+width+"s", text)
+	}
+	if (conversion == 'g' || conversion == 'G') && !strings.Contains(specification, ".") {
+		// Go picks the shortest representation, C defaults to a precision of 6
+		specification += ".6"
+	}
+	return fmt.Sprintf(specification+string(conversion), value)
+}
@@ -0,0 +1,139 @@
+package backend
+
+import (
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/ir"
+)
+
+// the identifiers whose values may still be read, at the start and at the end of every block
+type liveness struct {
+	in  []map[ir.Identifier]bool
+	out []map[ir.Identifier]bool
+}
+
+// Finds the live identifiers by working backwards from the exits of the graph until nothing changes.
+// Nothing is live once the main program or a function is over,
+// since the identifiers of one graph are never read by another.
+func livenessAnalysis(
+	graph ControlFlowGraph,
+	identifiers []common.IdentifierInformation,
+) liveness {
+	result := liveness{
+		in:  make([]map[ir.Identifier]bool, len(graph.Blocks)),
+		out: make([]map[ir.Identifier]bool, len(graph.Blocks)),
+	}
+	for index := range graph.Blocks {
+		result.in[index] = map[ir.Identifier]bool{}
+		result.out[index] = map[ir.Identifier]bool{}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for index := len(graph.Blocks) - 1; index >= 0; index-- {
+			block := graph.Blocks[index]
+
+			out := map[ir.Identifier]bool{}
+			for _, successor := range block.Successors {
+				for identifier := range result.in[successor] {
+					out[identifier] = true
+				}
+			}
+
+			live := copyLiveSet(out)
+			for position := len(block.Instructions) - 1; position >= 0; position-- {
+				updateLiveSet(live, block.Instructions[position], identifiers)
+			}
+
+			// the sets only ever grow, so comparing their sizes is enough
+			if len(out) != len(result.out[index]) || len(live) != len(result.in[index]) {
+				changed = true
+			}
+			result.out[index] = out
+			result.in[index] = live
+		}
+	}
+	return result
+}
+
+// steps the live set back over an instruction
+func updateLiveSet(
+	live map[ir.Identifier]bool,
+	code ir.Instruction,
+	identifiers []common.IdentifierInformation,
+) {
+	for _, destination := range overwrittenIdentifiers(code, identifiers) {
+		delete(live, destination)
+	}
+	for _, identifier := range usedIdentifiers(code, identifiers) {
+		live[identifier] = true
+	}
+}
+
+// The identifiers whose whole value the instruction replaces.
+func overwrittenIdentifiers(
+	code ir.Instruction,
+	identifiers []common.IdentifierInformation,
+) []ir.Identifier {
+	if destination, ok := definedIdentifier(code); ok {
+		return []ir.Identifier{destination}
+	}
+	if startsArrayLiteral(code, identifiers) {
+		return []ir.Identifier{code.(ir.IndexStore).Array}
+	}
+	return []ir.Identifier{}
+}
+
+// An array literal stores every element in order into its temporary,
+// so the store of its first element replaces the whole array without reading it.
+func startsArrayLiteral(code ir.Instruction, identifiers []common.IdentifierInformation) bool {
+	store, ok := code.(ir.IndexStore)
+	return ok && identifiers[store.Array].Temporary && store.Index == ir.Int(0)
+}
+
+// the identifiers that the instruction reads
+func usedIdentifiers(
+	code ir.Instruction,
+	identifiers []common.IdentifierInformation,
+) []ir.Identifier {
+	operands := []ir.Operand{}
+	switch code := code.(type) {
+	case ir.Assign:
+		operands = append(operands, code.Source)
+	case ir.BinOp:
+		operands = append(operands, code.First, code.Second)
+	case ir.UnOp:
+		operands = append(operands, code.Operand)
+	case ir.IndexLoad:
+		operands = append(operands, code.Array, code.Index)
+	case ir.IndexStore:
+		// only some of the elements are written, so the array is read as well
+		if !startsArrayLiteral(code, identifiers) {
+			operands = append(operands, code.Array)
+		}
+		operands = append(operands, code.Index, code.Value)
+	case ir.CondJump:
+		operands = append(operands, code.Condition)
+	case ir.Param:
+		operands = append(operands, code.Value)
+	case ir.Return:
+		if code.Value != nil {
+			operands = append(operands, code.Value)
+		}
+	}
+
+	used := []ir.Identifier{}
+	for _, operand := range operands {
+		if identifier, ok := operand.(ir.Identifier); ok {
+			used = append(used, identifier)
+		}
+	}
+	return used
+}
+
+func copyLiveSet(live map[ir.Identifier]bool) map[ir.Identifier]bool {
+	result := make(map[ir.Identifier]bool, len(live))
+	for identifier := range live {
+		result[identifier] = true
+	}
+	return result
+}
@@ -0,0 +1,302 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/ir"
+)
+
+// Improves the 3-address code without changing what the program does.
+// Level 0 leaves the code as it is.
+// Level 1 folds and propagates constants, removes the branches that can never be taken,
+// and removes the code whose result is never used.
+func Optimizer(
+	input []ir.Instruction,
+	identifiers []common.IdentifierInformation,
+	level int,
+) ([]ir.Instruction, error) {
+	if level <= 0 {
+		return input, nil
+	}
+
+	codes := input
+	// every pass can open up more work for the others
+	for changed := true; changed; {
+		folder := constantFolder{
+			constants:   constantIdentifiers(codes, identifiers),
+			identifiers: identifiers,
+		}
+		codes = folder.fold(codes)
+
+		graphs, err := ControlFlowGraphs(codes)
+		if err != nil {
+			return nil, err
+		}
+		removed := false
+		for index := range graphs {
+			removed = removeUnreachableBlocks(&graphs[index]) || removed
+			removed = removeDeadAssignments(&graphs[index], identifiers) || removed
+		}
+		codes = instructionsFromGraphs(graphs)
+
+		var simplified bool
+		codes, simplified = removeRedundantJumps(codes)
+
+		changed = folder.changed || removed || simplified
+	}
+	return codes, nil
+}
+
+// The identifiers that always hold the same literal.
+// Only immutable identifiers (which includes the temporaries) that are assigned exactly once qualify,
+// since their assignment comes before every use.
+func constantIdentifiers(
+	codes []ir.Instruction,
+	identifiers []common.IdentifierInformation,
+) map[ir.Identifier]ir.Literal {
+	assignments := map[ir.Identifier]int{}
+	literals := map[ir.Identifier]ir.Literal{}
+
+	for _, code := range codes {
+		destination, ok := definedIdentifier(code)
+		if !ok {
+			continue
+		}
+		assignments[destination]++
+		if assign, ok := code.(ir.Assign); ok {
+			if literal, ok := assign.Source.(ir.Literal); ok {
+				literals[destination] = literal
+			}
+		}
+	}
+
+	constants := map[ir.Identifier]ir.Literal{}
+	for identifier, literal := range literals {
+		if assignments[identifier] != 1 || identifiers[identifier].Mutable {
+			continue
+		}
+		kind, ok := scalarKind(identifiers[identifier].Datatype)
+		if !ok {
+			continue
+		}
+		// the literal is held the way the identifier would hold it, e.g., 5 in a float is 5.0
+		literal, ok = convertLiteral(literal, kind)
+		if ok {
+			constants[identifier] = literal
+		}
+	}
+	return constants
+}
+
+// the identifier that the instruction assigns a value to, if any
+func definedIdentifier(code ir.Instruction) (ir.Identifier, bool) {
+	switch code := code.(type) {
+	case ir.Assign:
+		return code.Destination, true
+	case ir.BinOp:
+		return code.Destination, true
+	case ir.UnOp:
+		return code.Destination, true
+	case ir.IndexLoad:
+		return code.Destination, true
+	case ir.Call:
+		return code.Result, code.Result != ir.NoIdentifier
+	}
+	return ir.NoIdentifier, false
+}
+
+type constantFolder struct {
+	constants   map[ir.Identifier]ir.Literal
+	identifiers []common.IdentifierInformation
+	changed     bool
+}
+
+func (f *constantFolder) fold(codes []ir.Instruction) []ir.Instruction {
+	result := make([]ir.Instruction, 0, len(codes))
+	for _, code := range codes {
+		code, keep := f.foldCode(code)
+		if keep {
+			result = append(result, code)
+		} else {
+			f.changed = true
+		}
+	}
+	return result
+}
+
+// returns false if the instruction is no longer needed
+func (f *constantFolder) foldCode(code ir.Instruction) (ir.Instruction, bool) {
+	switch code := code.(type) {
+	case ir.Assign:
+		code.Source = f.operand(code.Source)
+		return code, true
+
+	case ir.BinOp:
+		code.First = f.operand(code.First)
+		code.Second = f.operand(code.Second)
+		first, firstOk := operandValue(code.First)
+		second, secondOk := operandValue(code.Second)
+		if !firstOk || !secondOk {
+			return code, true
+		}
+		operator, ok := binaryOperatorWithOpcode[opcodeWithBinaryOperator[code.Operator]]
+		if !ok {
+			return code, true
+		}
+		// division by zero and the like are left for the program to run into
+		value, err := binaryOperation(operator, first, second)
+		if err != nil {
+			return code, true
+		}
+		return f.assignValue(code, code.Destination, value), true
+
+	case ir.UnOp:
+		code.Operand = f.operand(code.Operand)
+		operand, ok := operandValue(code.Operand)
+		if !ok {
+			return code, true
+		}
+		operator := common.UnaryMinus
+		if code.Operator == ir.Not {
+			operator = common.UnaryNot
+		} else if character, ok := operand.(int8); ok {
+			// as in C, a char is promoted to an integer before being negated
+			operand = int64(character)
+		}
+		value, err := unaryOperation(operator, operand)
+		if err != nil {
+			return code, true
+		}
+		return f.assignValue(code, code.Destination, value), true
+
+	case ir.CondJump:
+		code.Condition = f.operand(code.Condition)
+		condition, ok := operandValue(code.Condition)
+		if !ok {
+			return code, true
+		}
+		f.changed = true
+		if condition, _ := convertValue(kindBool, condition).(bool); condition {
+			return ir.Jump{Label: code.Label}, true
+		}
+		return code, false
+
+	case ir.IndexLoad:
+		code.Index = f.operand(code.Index)
+		return code, true
+
+	case ir.IndexStore:
+		code.Index = f.operand(code.Index)
+		code.Value = f.operand(code.Value)
+		return code, true
+
+	case ir.Param:
+		code.Value = f.operand(code.Value)
+		return code, true
+
+	case ir.Return:
+		if code.Value != nil {
+			code.Value = f.operand(code.Value)
+		}
+		return code, true
+	}
+	return code, true
+}
+
+// replaces a constant identifier with its literal
+func (f *constantFolder) operand(operand ir.Operand) ir.Operand {
+	identifier, ok := operand.(ir.Identifier)
+	if !ok {
+		return operand
+	}
+	literal, ok := f.constants[identifier]
+	if !ok {
+		return operand
+	}
+	f.changed = true
+	return literal
+}
+
+// replaces the code with an assignment of the value, as long as the value can be written as a literal
+func (f *constantFolder) assignValue(
+	code ir.Instruction,
+	destination ir.Identifier,
+	value any,
+) ir.Instruction {
+	kind, ok := scalarKind(f.identifiers[destination].Datatype)
+	if !ok {
+		return code
+	}
+	literal, ok := literalFromValue(convertValue(kind, value))
+	if !ok {
+		return code
+	}
+	f.changed = true
+	return ir.Assign{Destination: destination, Source: literal}
+}
+
+// the value of an operand that is a literal, strings excluded
+func operandValue(operand ir.Operand) (any, bool) {
+	literal, ok := operand.(ir.Literal)
+	if !ok || literal.Kind == ir.LiteralString {
+		return nil, false
+	}
+	value, err := constantValue(literal)
+	return value, err == nil
+}
+
+// the kind of a datatype that can be written as a literal
+func scalarKind(datatype common.Datatype) (valueKind, bool) {
+	kind, length := registerKind(datatype)
+	switch kind {
+	case kindInt, kindFloat, kindChar, kindBool:
+		return kind, length == 0
+	}
+	return kindUnknown, false
+}
+
+func convertLiteral(literal ir.Literal, kind valueKind) (ir.Literal, bool) {
+	value, ok := operandValue(literal)
+	if !ok {
+		return ir.Literal{}, false
+	}
+	return literalFromValue(convertValue(kind, value))
+}
+
+// writes the value as a literal, if it has one
+func literalFromValue(value any) (ir.Literal, bool) {
+	switch value := value.(type) {
+	case int64:
+		// C has no literal for the smallest integer, only its negation
+		if value == math.MinInt64 {
+			return ir.Literal{}, false
+		}
+		return ir.Literal{Kind: ir.LiteralInt, Value: strconv.FormatInt(value, 10)}, true
+
+	case float64:
+		if math.IsInf(value, 0) || math.IsNaN(value) {
+			return ir.Literal{}, false
+		}
+		text := strconv.FormatFloat(value, 'g', -1, 64)
+		// so that it is not taken for an integer
+		if !strings.ContainsAny(text, ".e") {
+			text += ".0"
+		}
+		return ir.Literal{Kind: ir.LiteralFloat, Value: text}, true
+
+	case int8:
+		character := byte(value)
+		if character >= ' ' && character <= '~' && character != '\'' && character != '\\' {
+			return ir.Literal{Kind: ir.LiteralChar, Value: fmt.Sprintf("'%c'", character)}, true
+		}
+		return ir.Literal{Kind: ir.LiteralChar, Value: fmt.Sprintf("'\\%03o'", character)}, true
+
+	case bool:
+		return ir.Literal{Kind: ir.LiteralBool, Value: strconv.FormatBool(value)}, true
+	}
+	return ir.Literal{}, false
+}
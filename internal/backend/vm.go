@@ -0,0 +1,418 @@
+package backend
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+)
+
+// Runs the bytecode on a register machine, one register per identifier.
+// Calls save the registers of the function, so that every call has its own.
+func VirtualMachine(program Bytecode, reader io.Reader, writer io.Writer) error {
+	machine := virtualMachine{
+		program:    program,
+		functions:  map[int]bytecodeFunction{},
+		registers:  make([]any, len(program.registers)),
+		parameters: []any{},
+		input:      bufio.NewReader(reader),
+		output:     bufio.NewWriter(writer),
+	}
+	defer machine.output.Flush()
+
+	for _, function := range program.functions {
+		machine.functions[function.register] = function
+	}
+	for index := range program.registers {
+		machine.reset(index)
+	}
+
+	_, err := machine.run(0)
+	return err
+}
+
+type virtualMachine struct {
+	program   Bytecode
+	functions map[int]bytecodeFunction
+	registers []any
+	// the values given by param, for the next call
+	parameters []any
+
+	input  *bufio.Reader
+	output *bufio.Writer
+
+	callDepth int
+}
+
+// runs from the given instruction until the program halts or the function returns
+func (m *virtualMachine) run(pc int) (any, error) {
+	for {
+		if pc >= len(m.program.instructions) {
+			return nil, m.runtimeError(pc, "ran past the end of the program")
+		}
+		instruction := m.program.instructions[pc]
+		a, b, c := instruction.operands[0], instruction.operands[1], instruction.operands[2]
+		pc++
+
+		switch instruction.opcode {
+		case opHalt:
+			return nil, nil
+
+		case opMove:
+			value, err := m.read(pc-1, b)
+			if err != nil {
+				return nil, err
+			}
+			m.write(a, value)
+
+		case opCopy:
+			source, err := m.read(pc-1, b)
+			if err != nil {
+				return nil, err
+			}
+			start, err := m.index(pc-1, source, c, m.program.registers[a].length)
+			if err != nil {
+				return nil, err
+			}
+			destination := m.registers[a].([]any)
+			copy(destination, source.([]any)[start:start+len(destination)])
+
+		case opLoad:
+			array, err := m.read(pc-1, b)
+			if err != nil {
+				return nil, err
+			}
+			if text, ok := array.(string); ok {
+				character, err := m.character(pc-1, text, c)
+				if err != nil {
+					return nil, err
+				}
+				m.write(a, character)
+				continue
+			}
+			position, err := m.index(pc-1, array, c, 1)
+			if err != nil {
+				return nil, err
+			}
+			m.write(a, array.([]any)[position])
+
+		case opStore:
+			array := m.registers[a]
+			position, err := m.index(pc-1, array, b, 1)
+			if err != nil {
+				return nil, err
+			}
+			value, err := m.read(pc-1, c)
+			if err != nil {
+				return nil, err
+			}
+			array.([]any)[position] = convertValue(m.program.registers[a].kind, value)
+
+		case opNegate, opNot:
+			operand, err := m.read(pc-1, b)
+			if err != nil {
+				return nil, err
+			}
+			operator := common.UnaryMinus
+			if instruction.opcode == opNot {
+				operator = common.UnaryNot
+			}
+			value, err := unaryOperation(operator, operand)
+			if err != nil {
+				return nil, err
+			}
+			m.write(a, value)
+
+		case opJump:
+			pc = int(a)
+
+		case opJumpIf:
+			condition, err := m.read(pc-1, a)
+			if err != nil {
+				return nil, err
+			}
+			if result, _ := condition.(bool); result {
+				pc = int(b)
+			}
+
+		case opParam:
+			value, err := m.read(pc-1, a)
+			if err != nil {
+				return nil, err
+			}
+			m.parameters = append(m.parameters, copyValue(value))
+
+		case opCall:
+			value, err := m.call(pc-1, int(b), int(c))
+			if err != nil {
+				return nil, err
+			}
+			if a != noOperand {
+				m.write(a, value)
+			}
+
+		case opReturn:
+			if a == noOperand {
+				return nil, nil
+			}
+			value, err := m.read(pc-1, a)
+			return copyValue(value), err
+
+		case opGetchar:
+			// whatever has been printed should be visible before waiting on the user
+			m.output.Flush()
+			character, err := m.input.ReadByte()
+			if err == io.EOF {
+				m.write(a, int8(-1))
+			} else if err != nil {
+				return nil, m.runtimeError(pc-1, err.Error())
+			} else {
+				m.write(a, int8(character))
+			}
+
+		case opPrintf:
+			arguments := m.takeParameters(int(a))
+			if len(arguments) == 0 {
+				return nil, m.runtimeError(pc-1, "printf without a format string")
+			}
+			format, ok := arguments[0].(string)
+			if !ok {
+				return nil, m.runtimeError(pc-1, "printf without a format string")
+			}
+			text, err := formatOutput(format, arguments[1:])
+			if err != nil {
+				return nil, m.runtimeError(pc-1, err.Error())
+			}
+			m.output.WriteString(text)
+
+		case opLength:
+			arguments := m.takeParameters(1)
+			text, ok := "", len(arguments) == 1
+			if ok {
+				text, ok = arguments[0].(string)
+			}
+			if !ok {
+				return nil, m.runtimeError(pc-1, "len without a string")
+			}
+			m.write(a, int64(len(text)))
+
+		case opPrint:
+			for _, argument := range m.takeParameters(int(a)) {
+				m.output.WriteString(formatValue(argument))
+			}
+
+		default:
+			operator, ok := binaryOperatorWithOpcode[instruction.opcode]
+			if !ok {
+				return nil, m.runtimeError(pc-1, "unknown opcode")
+			}
+			first, err := m.read(pc-1, b)
+			if err != nil {
+				return nil, err
+			}
+			second, err := m.read(pc-1, c)
+			if err != nil {
+				return nil, err
+			}
+			value, err := binaryOperation(operator, first, second)
+			if err != nil {
+				return nil, m.runtimeError(pc-1, err.Error())
+			}
+			m.write(a, value)
+		}
+	}
+}
+
+func (m *virtualMachine) call(pc, register, count int) (any, error) {
+	function, ok := m.functions[register]
+	if !ok {
+		return nil, m.runtimeError(pc, "call to an unknown function")
+	}
+	arguments := m.takeParameters(count)
+	if len(arguments) != len(function.parameters) {
+		return nil, m.runtimeError(pc, "call with the wrong number of parameters")
+	}
+	if m.callDepth >= maxCallDepth {
+		return nil, m.runtimeError(pc, "maximum call depth exceeded")
+	}
+
+	// the registers of the caller are put back once the call is over
+	owned := append(append([]int{}, function.parameters...), function.locals...)
+	saved := make([]any, len(owned))
+	for index, register := range owned {
+		saved[index] = m.registers[register]
+		m.reset(register)
+	}
+	for index, parameter := range function.parameters {
+		m.write(uint32(parameter), arguments[index])
+	}
+
+	m.callDepth++
+	value, err := m.run(function.entry)
+	m.callDepth--
+
+	for index, register := range owned {
+		m.registers[register] = saved[index]
+	}
+	return value, err
+}
+
+// takes the values of the last count params
+func (m *virtualMachine) takeParameters(count int) []any {
+	if count > len(m.parameters) {
+		count = len(m.parameters)
+	}
+	start := len(m.parameters) - count
+	arguments := append([]any{}, m.parameters[start:]...)
+	m.parameters = m.parameters[:start]
+	return arguments
+}
+
+// arrays are given their elements up front, while everything else starts unassigned
+func (m *virtualMachine) reset(index int) {
+	register := m.program.registers[index]
+	if register.length == 0 {
+		m.registers[index] = nil
+		return
+	}
+	elements := make([]any, register.length)
+	for position := range elements {
+		elements[position] = convertValue(register.kind, int64(0))
+	}
+	m.registers[index] = elements
+}
+
+func (m *virtualMachine) read(pc int, operand uint32) (any, error) {
+	if operand&constantOperand != 0 {
+		return m.program.constants[operand&^constantOperand], nil
+	}
+	value := m.registers[operand]
+	if value == nil {
+		return nil, m.runtimeError(pc, fmt.Sprintf("_t%v read before being assigned a value", operand))
+	}
+	return value, nil
+}
+
+func (m *virtualMachine) write(register uint32, value any) {
+	m.registers[register] = convertValue(m.program.registers[register].kind, value)
+}
+
+// checks that length elements from the index fit in the array
+func (m *virtualMachine) index(pc int, array any, operand uint32, length int) (int, error) {
+	elements, ok := array.([]any)
+	if !ok {
+		return 0, m.runtimeError(pc, "array access on a non-array")
+	}
+	value, err := m.read(pc, operand)
+	if err != nil {
+		return 0, err
+	}
+	position, ok := toInteger(value)
+	if !ok {
+		return 0, m.runtimeError(pc, "non-integer array index")
+	}
+	if position < 0 || position+int64(length) > int64(len(elements)) {
+		return 0, m.runtimeError(pc, fmt.Sprintf(
+			"array index %v out of bounds for an array of %v elements",
+			position,
+			len(elements),
+		))
+	}
+	return int(position), nil
+}
+
+// the character of the string at the index
+func (m *virtualMachine) character(pc int, text string, operand uint32) (int8, error) {
+	value, err := m.read(pc, operand)
+	if err != nil {
+		return 0, err
+	}
+	position, ok := toInteger(value)
+	if !ok {
+		return 0, m.runtimeError(pc, "non-integer string index")
+	}
+	if position < 0 || position >= int64(len(text)) {
+		return 0, m.runtimeError(pc, fmt.Sprintf(
+			"string index %v out of bounds for a string of %v characters",
+			position,
+			len(text),
+		))
+	}
+	return int8(text[position]), nil
+}
+
+func (m *virtualMachine) runtimeError(pc int, message string) *RuntimeError {
+	return &RuntimeError{
+		Message: fmt.Sprintf("%v (instruction %v)", message, pc),
+	}
+}
+
+// values are converted to the kind of the register they are stored in, like assignments in C
+func convertValue(kind valueKind, value any) any {
+	if datatype, ok := sizedKinds[kind]; ok {
+		return convertSized(datatype, value)
+	}
+	if float, ok := value.(float32); ok {
+		// an f32 is converted the way its value as a float would be
+		value = float64(float)
+	}
+	switch kind {
+	case kindInt:
+		if integer, ok := toInteger(value); ok {
+			return integer
+		}
+		if float, ok := value.(float64); ok {
+			return int64(float)
+		}
+
+	case kindChar:
+		if integer, ok := toInteger(value); ok {
+			return int8(integer)
+		}
+		if float, ok := value.(float64); ok {
+			return int8(float)
+		}
+
+	case kindFloat:
+		if float, ok := toFloat(value); ok {
+			return float
+		}
+
+	case kindBool:
+		if boolean, ok := value.(bool); ok {
+			return boolean
+		}
+		if float, ok := toFloat(value); ok {
+			return float != 0
+		}
+	}
+	return value
+}
+
+// e.g., 300 becomes 44 in a u8, and -1.5 becomes -1 in an i8
+func convertSized(datatype common.PrimitiveDatatype, value any) any {
+	if datatype == common.TypedF32 {
+		if float, ok := toFloat32(value); ok {
+			return float
+		}
+		return value
+	}
+	var float float64
+	switch number := value.(type) {
+	case float32:
+		float = float64(number)
+	case float64:
+		float = number
+	default:
+		if integer, ok := toInteger(value); ok {
+			return wrapInteger(datatype, integer)
+		}
+		return value
+	}
+	if datatype.IsUnsigned() && float >= math.MaxInt64 {
+		return wrapInteger(datatype, int64(uint64(float)))
+	}
+	return wrapInteger(datatype, int64(float))
+}
@@ -0,0 +1,262 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/frontend"
+	"github.com/SamJohn04/simple-lang-compiler/internal/ir"
+)
+
+// runs the front end on the source, whose mistakes are returned
+func checkSource(source string) (common.ProgramAST, []common.IdentifierInformation, error) {
+	lex := make(chan common.Token)
+	go frontend.Lexer(strings.NewReader(source), "test.sl", lex)
+	root, err := frontend.Parser(lex, 0)
+	if err != nil {
+		return common.ProgramAST{}, nil, err
+	}
+	program, identifiers, err := frontend.SemanticAnalyzer(root)
+	if err != nil {
+		return common.ProgramAST{}, nil, err
+	}
+	program, err = frontend.TypeChecker(program, identifiers)
+	return program, identifiers, err
+}
+
+// compiles the source, which must compile, to the 3-address code the way slc does
+func compileSource(
+	t *testing.T,
+	source string,
+	optimization int,
+) ([]ir.Instruction, []common.IdentifierInformation) {
+	t.Helper()
+	program, identifiers, err := checkSource(source)
+	if err != nil {
+		t.Fatalf("compiling: %v", err)
+	}
+	codes, identifiers, err := IntermediateCodeGenerator(program, identifiers)
+	if err != nil {
+		t.Fatalf("generating the 3-address code: %v", err)
+	}
+	codes, err = Optimizer(codes, identifiers, optimization)
+	if err != nil {
+		t.Fatalf("optimizing: %v", err)
+	}
+	codes, identifiers, err = TemporaryAllocator(codes, identifiers)
+	if err != nil {
+		t.Fatalf("allocating temporaries: %v", err)
+	}
+	return codes, identifiers
+}
+
+// compiles the source, which must compile, and goes through an .slbc file the way slc does
+func encodeProgram(t *testing.T, source string, optimization int) []byte {
+	t.Helper()
+	codes, identifiers := compileSource(t, source, optimization)
+	bytecode, err := BytecodeGenerator(codes, identifiers)
+	if err != nil {
+		t.Fatalf("generating the bytecode: %v", err)
+	}
+
+	var file bytes.Buffer
+	if err := WriteBytecode(&file, bytecode); err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	return file.Bytes()
+}
+
+func TestVirtualMachine(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		input  string
+		output string
+	}{
+		{
+			name: "functions and loops",
+			source: `fn fib(n: int) -> int {
+    if n < 2 {
+        return n;
+    };
+    return fib(n - 1) + fib(n - 2);
+};
+let mut i = 0;
+while i < 10 {
+    print(fib(i));
+    i = i + 1;
+};
+println();
+let mut total = 0.0;
+for j in 1..5 {
+    total = total + j as float / 2.0;
+};
+printf("%lld %.2f %c %s\n", fib(15), total, 'z', "done");
+`,
+			output: "0112358132134\n610 5.00 z done\n",
+		},
+		{
+			name: "arrays, strings and sized numbers",
+			source: `let mut grid: [[int; 3]; 2];
+for r in 0..2 {
+    for c in 0..3 {
+        grid[r][c] = r * 3 + c;
+    };
+};
+println(grid, len(grid));
+let name = "world";
+let greeting = "hello, " + name;
+println(greeting, len(greeting), greeting[7], name == "world");
+let mask = 250u8 + 10;
+let small: i16 = -300;
+println(mask, 0u8 - 1, small as u8, -2.7 as u8, 7 / 2, 7 % 3, !(1 < 2));
+`,
+			output: "[[0, 1, 2], [3, 4, 5]] 2\nhello, world 12 w true\n4 255 212 254 3 1 false\n",
+		},
+		{
+			name: "input",
+			source: `let mut count = 0;
+let mut c = getchar();
+while c != '\n' {
+    if c == 'a' {
+        count = count + 1;
+    };
+    c = getchar();
+};
+println("a:", count);
+`,
+			input:  "banana\n",
+			output: "a: 3\n",
+		},
+	}
+
+	for _, test := range tests {
+		for optimization := 0; optimization <= 1; optimization++ {
+			file := encodeProgram(t, test.source, optimization)
+			program, err := ReadBytecode(bytes.NewReader(file))
+			if err != nil {
+				t.Errorf("%v, -O%v: decoding: %v", test.name, optimization, err)
+				continue
+			}
+			var output strings.Builder
+			err = VirtualMachine(program, strings.NewReader(test.input), &output)
+			if err != nil {
+				t.Errorf("%v, -O%v: running: %v", test.name, optimization, err)
+			}
+			if output.String() != test.output {
+				t.Errorf("%v, -O%v: printed %q, want %q", test.name, optimization, output.String(), test.output)
+			}
+		}
+	}
+}
+
+func TestReadBytecodeTruncated(t *testing.T) {
+	file := encodeProgram(t, `let s = "some text";
+fn twice(x: int) -> int {
+    return x * 2;
+};
+println(s, twice(4), 1.5);
+`, 0)
+	// every prefix of the file stops in the middle of something
+	for length := 0; length < len(file); length++ {
+		if _, err := ReadBytecode(bytes.NewReader(file[:length])); err == nil {
+			t.Errorf("the first %v of %v bytes were read without an error", length, len(file))
+		}
+	}
+}
+
+func TestReadBytecodeCorrupted(t *testing.T) {
+	file := encodeProgram(t, `let arr = [1, 2, 3];
+let mut i = 0;
+while i < len(arr) {
+    printf("%lld\n", arr[i]);
+    i = i + 1;
+};
+`, 0)
+
+	program, err := ReadBytecode(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each instruction is an opcode and three operands of 4 bytes, at the end of the file
+	firstInstruction := len(file) - 13*len(program.instructions)
+
+	corrupt := func(offset int, value byte) []byte {
+		corrupted := append([]byte{}, file...)
+		corrupted[offset] = value
+		return corrupted
+	}
+	corruptLength := func(offset int, length uint32) []byte {
+		corrupted := append([]byte{}, file...)
+		binary.LittleEndian.PutUint32(corrupted[offset:], length)
+		return corrupted
+	}
+
+	// the registers come after the magic, the version and their number, each as a kind and a length
+	arrayLength, stringLength := 0, 0
+	for index, register := range program.registers {
+		if register.length > 0 {
+			arrayLength = 10 + 5*index + 1
+			break
+		}
+	}
+	// and then the constants
+	offset := 10 + 5*len(program.registers) + 4
+	for _, constant := range program.constants {
+		switch constant := constant.(type) {
+		case string:
+			stringLength = offset + 1
+			offset += 1 + 4 + len(constant)
+		case int64, float64:
+			offset += 1 + 8
+		default:
+			offset += 1 + 1
+		}
+	}
+	if arrayLength == 0 || stringLength == 0 {
+		t.Fatal("the program has no array or no string")
+	}
+	for _, test := range []struct {
+		name string
+		file []byte
+	}{
+		{"empty", []byte{}},
+		{"magic", corrupt(0, 'X')},
+		{"version", corrupt(4, bytecodeVersion+1)},
+		{"opcode", corrupt(firstInstruction, 0xff)},
+		{"operand", corrupt(firstInstruction+4, 0x7f)},
+		{"trailing text", []byte("SLBC is not this")},
+		{"string length", corruptLength(stringLength, 0xffffffff)},
+		{"array length", corruptLength(arrayLength, 0xffffffff)},
+		{"array length over the limit", corruptLength(arrayLength, maxArrayElements+1)},
+	} {
+		if _, err := ReadBytecode(bytes.NewReader(test.file)); err == nil {
+			t.Errorf("%v: read without an error", test.name)
+		}
+	}
+
+	// whatever a byte is changed to, reading the file must not panic
+	for offset := range file {
+		for _, value := range []byte{0x00, 0x01, 0x7f, 0x80, 0xff} {
+			func() {
+				defer func() {
+					if recovered := recover(); recovered != nil {
+						t.Errorf("byte %v set to %#x: panic: %v", offset, value, recovered)
+					}
+				}()
+				ReadBytecode(bytes.NewReader(corrupt(offset, value)))
+			}()
+		}
+	}
+}
+
+func TestArraysTooLarge(t *testing.T) {
+	codes, identifiers := compileSource(t, "let mut a: [int; 10000000];\nlet mut b: [int; 10000000];\n", 0)
+	_, err := BytecodeGenerator(codes, identifiers)
+	if err == nil || !strings.Contains(err.Error(), "elements the virtual machine holds") {
+		t.Errorf("got %v, want an error for arrays too large", err)
+	}
+}
@@ -3,6 +3,11 @@ package common
 import (
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/ir"
 )
 
 type ProgramAST struct {
@@ -13,7 +18,7 @@ func (p ProgramAST) PerformAllChecks(identifiers []IdentifierInformation) error
 	for _, instruction := range p.Instructions {
 		err := instruction.PerformChecks(identifiers)
 		if err != nil {
-			return err
+			return withSpan(instruction.GetSpan(), err)
 		}
 	}
 	return nil
@@ -21,12 +26,12 @@ func (p ProgramAST) PerformAllChecks(identifiers []IdentifierInformation) error
 
 func (p ProgramAST) ThreeAddressCode(
 	identifiers []IdentifierInformation, numberOfGotos *int,
-) ([]string, []IdentifierInformation, error) {
-	threeAddressCodes := []string{}
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	threeAddressCodes := []ir.Instruction{}
 	for _, instruction := range p.Instructions {
 		codes, ident, err := instruction.ThreeAddressCode(identifiers, numberOfGotos)
 		if err != nil {
-			return []string{}, identifiers, err
+			return []ir.Instruction{}, identifiers, err
 		}
 		threeAddressCodes = append(threeAddressCodes, codes...)
 		identifiers = ident
@@ -42,28 +47,56 @@ func (p ProgramAST) Display() {
 
 type InstructionAST interface {
 	PerformChecks(identifiers []IdentifierInformation) error
+	GetSpan() Span
 	ThreeAddressCode(
 		identifiers []IdentifierInformation,
 		numberOfGotos *int,
-	) ([]string, []IdentifierInformation, error)
+	) ([]ir.Instruction, []IdentifierInformation, error)
 }
 
 type AssignmentAST struct {
 	AssignToIdentifier int
 	ArrayValues        []ExpressionAST
 	AssignValue        ExpressionAST
+	Span               Span
 }
 
 func (a AssignmentAST) PerformChecks(identifiers []IdentifierInformation) error {
-	assignedDatatype, err := a.AssignValue.GetDatatype(identifiers)
+	assignedDatatype, err := datatypeFor(a.AssignValue, a.assignedTo(identifiers), identifiers)
 	if err != nil {
 		return err
 	}
 
+	if assignedDatatype.IsDatatype(VoidDatatype{}) {
+		return errorAt(a.Span, CodeVoidValue, "a function without a return value cannot be assigned")
+	}
+
 	identifierDatatype := identifiers[a.AssignToIdentifier].Datatype
 	if len(a.ArrayValues) > 0 &&
 		(identifierDatatype == nil || identifierDatatype.IsDatatype(TypedUnknown)) {
-		return errors.New("undeclared identifier cannot have array accesses")
+		return errorAt(
+			a.Span, CodeInvalidArrayAccess, "undeclared identifier cannot have array accesses",
+		)
+	}
+	if annotation := identifiers[a.AssignToIdentifier].Annotation; annotation != nil &&
+		(identifierDatatype == nil || identifierDatatype.IsDatatype(TypedUnknown)) {
+		// the first value of an identifier declared with its datatype
+		adapted, err := literalAdapts(a.AssignValue, annotation)
+		if err != nil {
+			return err
+		}
+		if !adapted && !initializes(assignedDatatype, annotation) {
+			return withDeclaration(errorAt(
+				a.AssignValue.GetSpan(),
+				CodeMismatchedTypes,
+				fmt.Sprintf(
+					"a value of type %v cannot be assigned to a variable declared as %v",
+					DatatypeName(assignedDatatype), DatatypeName(annotation),
+				),
+			), identifiers[a.AssignToIdentifier])
+		}
+		identifiers[a.AssignToIdentifier].Datatype = annotation
+		return nil
 	}
 	if identifierDatatype == nil || identifierDatatype.IsDatatype(TypedUnknown) {
 		identifiers[a.AssignToIdentifier].Datatype = assignedDatatype
@@ -71,28 +104,66 @@ func (a AssignmentAST) PerformChecks(identifiers []IdentifierInformation) error
 	}
 
 	arrayDatatype, ok := identifierDatatype.(ArrayDatatype)
-	for range a.ArrayValues {
+	for _, access := range a.ArrayValues {
+		if err := checkIndex(access, identifiers); err != nil {
+			return err
+		}
+		if identifierDatatype.IsDatatype(StringDatatype{}) {
+			return errorAt(
+				a.Span, CodeInvalidArrayAccess, "the characters of a string cannot be changed",
+			)
+		}
 		if !ok {
-			return errors.New("more array accesses than nested arrays")
+			return errorAt(a.Span, CodeInvalidArrayAccess, "more array accesses than nested arrays")
 		}
 		identifierDatatype = arrayDatatype.ElementType
 		arrayDatatype, ok = identifierDatatype.(ArrayDatatype)
 	}
 
 	if ok {
-		return errors.New("assignment of an array in an array is not possible")
+		return errorAt(
+			a.Span, CodeInvalidArrayAccess, "assignment of an array in an array is not possible",
+		)
+	}
+	adapted, err := literalAdapts(a.AssignValue, identifierDatatype)
+	if err != nil {
+		return err
 	}
-	if !identifierDatatype.IsDatatype(assignedDatatype) {
-		return errors.New("identifier datatype and operand datatype do not match")
+	if !adapted && !identifierDatatype.IsDatatype(assignedDatatype) {
+		return withDeclaration(errorAt(
+			a.AssignValue.GetSpan(),
+			CodeMismatchedTypes,
+			"identifier datatype and operand datatype do not match",
+		), identifiers[a.AssignToIdentifier])
 	}
 	return nil
 }
 
+// the datatype of what is assigned to, if it is known
+func (a AssignmentAST) assignedTo(identifiers []IdentifierInformation) Datatype {
+	datatype := identifiers[a.AssignToIdentifier].Datatype
+	if datatype == nil || datatype.IsDatatype(TypedUnknown) {
+		return identifiers[a.AssignToIdentifier].Annotation
+	}
+	for range a.ArrayValues {
+		arrayDatatype, ok := datatype.(ArrayDatatype)
+		if !ok {
+			return nil
+		}
+		datatype = arrayDatatype.ElementType
+	}
+	return datatype
+}
+
+func (a AssignmentAST) GetSpan() Span {
+	return a.Span
+}
+
 func (a AssignmentAST) ThreeAddressCode(
 	identifiers []IdentifierInformation,
 	numberOfGotos *int,
-) ([]string, []IdentifierInformation, error) {
-	result, codes, identifiers, err := a.AssignValue.ThreeAddressCode(identifiers)
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	result, codes, identifiers, err := a.AssignValue.ThreeAddressCode(identifiers, numberOfGotos)
 	if err != nil {
 		return codes, identifiers, err
 	}
@@ -100,19 +171,19 @@ func (a AssignmentAST) ThreeAddressCode(
 	if len(a.ArrayValues) == 0 {
 		codes = append(
 			codes,
-			fmt.Sprintf("%v = %v", identifierFromIndex(a.AssignToIdentifier), result),
+			ir.Assign{Destination: ir.Identifier(a.AssignToIdentifier), Source: result},
 		)
 		return codes, identifiers, nil
 	}
 
 	arrayDatatype, arrayOk := identifiers[a.AssignToIdentifier].Datatype.(ArrayDatatype)
-	arrayResult := "0"
+	var arrayResult ir.Operand = ir.Int(0)
 	for _, access := range a.ArrayValues {
 		if !arrayOk {
 			return codes, identifiers, errors.New("non-array where array expected")
 		}
 
-		assignTo, arrayCodes, ident, err := access.ThreeAddressCode(identifiers)
+		assignTo, arrayCodes, ident, err := access.ThreeAddressCode(identifiers, numberOfGotos)
 		if err != nil {
 			return codes, identifiers, err
 		}
@@ -123,25 +194,35 @@ func (a AssignmentAST) ThreeAddressCode(
 		variable2, ident := nextIdentifier(ident, TypedInt)
 		codes = append(
 			codes,
-			fmt.Sprintf("%v = %v * %v", variable1, arrayResult, arrayDatatype.NumberOfElements),
-			fmt.Sprintf("%v = %v + %v", variable2, variable1, assignTo),
+			ir.BinOp{
+				Destination: variable1,
+				Operator:    ir.Multiply,
+				First:       arrayResult,
+				Second:      ir.Int(arrayDatatype.NumberOfElements),
+			},
+			ir.BinOp{
+				Destination: variable2,
+				Operator:    ir.Add,
+				First:       variable1,
+				Second:      assignTo,
+			},
 		)
 
 		arrayResult = variable2
 		identifiers = ident
 		arrayDatatype, arrayOk = arrayDatatype.ElementType.(ArrayDatatype)
 	}
-	codes = append(codes, fmt.Sprintf(
-		"%v [] %v = %v",
-		identifierFromIndex(a.AssignToIdentifier),
-		arrayResult,
-		result,
-	))
+	codes = append(codes, ir.IndexStore{
+		Array: ir.Identifier(a.AssignToIdentifier),
+		Index: arrayResult,
+		Value: result,
+	})
 	return codes, identifiers, nil
 }
 
 type IfStatementAST struct {
 	IfExpressions []IfExpression
+	Span          Span
 }
 
 type IfExpression struct {
@@ -156,7 +237,11 @@ func (i IfStatementAST) PerformChecks(identifiers []IdentifierInformation) error
 			return err
 		}
 		if !conditionDatatype.IsDatatype(TypedBool) {
-			return errors.New("non-boolean value in an if condition")
+			return errorAt(
+				expression.Condition.GetSpan(),
+				CodeNonBooleanCondition,
+				"non-boolean value in an if condition",
+			)
 		}
 		err = expression.Program.PerformAllChecks(identifiers)
 		if err != nil {
@@ -166,24 +251,29 @@ func (i IfStatementAST) PerformChecks(identifiers []IdentifierInformation) error
 	return nil
 }
 
+func (i IfStatementAST) GetSpan() Span {
+	return i.Span
+}
+
 func (i IfStatementAST) ThreeAddressCode(
 	identifiers []IdentifierInformation,
 	numberOfGotos *int,
-) ([]string, []IdentifierInformation, error) {
-	codes := []string{}
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	codes := []ir.Instruction{}
 	ifEndGoto := getNextGoto(numberOfGotos)
 
 	for _, ifExpression := range i.IfExpressions {
 		variable, conditionCodes, id, err := ifExpression.Condition.ThreeAddressCode(
 			identifiers,
+			numberOfGotos,
 		)
 		if err != nil {
-			return []string{}, identifiers, err
+			return []ir.Instruction{}, identifiers, err
 		}
 
 		programCodes, id, err := ifExpression.Program.ThreeAddressCode(id, numberOfGotos)
 		if err != nil {
-			return []string{}, identifiers, err
+			return []ir.Instruction{}, identifiers, err
 		}
 
 		holdGoto := getNextGoto(numberOfGotos)
@@ -192,19 +282,19 @@ func (i IfStatementAST) ThreeAddressCode(
 		codes = append(codes, conditionCodes...)
 		codes = append(
 			codes,
-			fmt.Sprintf("if %v goto %v", variable, holdGoto),
-			fmt.Sprintf("goto %v", nextGoto),
-			fmt.Sprintf("%v:", holdGoto),
+			ir.CondJump{Condition: variable, Label: holdGoto},
+			ir.Jump{Label: nextGoto},
+			ir.Label{Name: holdGoto},
 		)
 		codes = append(codes, programCodes...)
 		codes = append(
 			codes,
-			fmt.Sprintf("goto %v", ifEndGoto),
-			fmt.Sprintf("%v:", nextGoto),
+			ir.Jump{Label: ifEndGoto},
+			ir.Label{Name: nextGoto},
 		)
 		identifiers = id
 	}
-	codes = append(codes, fmt.Sprintf("%v:", ifEndGoto))
+	codes = append(codes, ir.Label{Name: ifEndGoto})
 
 	return codes, identifiers, nil
 }
@@ -212,6 +302,8 @@ func (i IfStatementAST) ThreeAddressCode(
 type WhileStatementAST struct {
 	Condition ExpressionAST
 	Program   ProgramAST
+	Loop      *Loop
+	Span      Span
 }
 
 func (w WhileStatementAST) PerformChecks(identifiers []IdentifierInformation) error {
@@ -220,7 +312,9 @@ func (w WhileStatementAST) PerformChecks(identifiers []IdentifierInformation) er
 		return err
 	}
 	if !conditionDatatype.IsDatatype(TypedBool) {
-		return errors.New("non-boolean value in a while condition")
+		return errorAt(
+			w.Condition.GetSpan(), CodeNonBooleanCondition, "non-boolean value in a while condition",
+		)
 	}
 	err = w.Program.PerformAllChecks(identifiers)
 	if err != nil {
@@ -229,321 +323,1457 @@ func (w WhileStatementAST) PerformChecks(identifiers []IdentifierInformation) er
 	return nil
 }
 
+func (w WhileStatementAST) GetSpan() Span {
+	return w.Span
+}
+
 func (w WhileStatementAST) ThreeAddressCode(
 	identifiers []IdentifierInformation,
 	numberOfGotos *int,
-) ([]string, []IdentifierInformation, error) {
-	whileGoto := getNextGoto(numberOfGotos)
-	holdGoto := getNextGoto(numberOfGotos)
-	nextGoto := getNextGoto(numberOfGotos)
-	threeAddressCodes := []string{
-		fmt.Sprintf("%v:", whileGoto),
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	labels := loopLabels{
+		loop: getNextGoto(numberOfGotos),
+		body: getNextGoto(numberOfGotos),
+		end:  getNextGoto(numberOfGotos),
 	}
+	w.Loop.enter(labels)
 
-	relation, relationCodes, identifiers, err := w.Condition.ThreeAddressCode(identifiers)
+	relation, relationCodes, identifiers, err := w.Condition.ThreeAddressCode(identifiers, numberOfGotos)
 	if err != nil {
-		return []string{}, identifiers, err
+		return []ir.Instruction{}, identifiers, err
 	}
 
 	programCodes, identifiers, err := w.Program.ThreeAddressCode(identifiers, numberOfGotos)
 	if err != nil {
-		return []string{}, identifiers, err
+		return []ir.Instruction{}, identifiers, err
+	}
+
+	return labels.threeAddressCode(relation, relationCodes, programCodes, nil), identifiers, nil
+}
+
+// A while or for loop, which the break and continue statements inside it point to.
+type Loop struct {
+	// from label: while ..., empty when the loop has no label
+	Label string
+	// where break and continue jump to, set when the loop is lowered to three address code
+	labels loopLabels
+}
+
+func (l *Loop) enter(labels loopLabels) {
+	if l != nil {
+		l.labels = labels
 	}
+}
+
+// the labels a loop jumps between
+type loopLabels struct {
+	// before the condition
+	loop string
+	body string
+	// before the step, empty if the loop has none
+	step string
+	// after the loop
+	end string
+}
 
-	threeAddressCodes = append(threeAddressCodes, relationCodes...)
+// puts the codes of a loop together as
+//
+//	loop: condition codes; if condition goto body; goto end
+//	body: program codes
+//	step: step codes; goto loop
+//	end:
+func (l loopLabels) threeAddressCode(
+	condition ir.Operand, conditionCodes, programCodes, stepCodes []ir.Instruction,
+) []ir.Instruction {
+	threeAddressCodes := []ir.Instruction{
+		ir.Label{Name: l.loop},
+	}
+	threeAddressCodes = append(threeAddressCodes, conditionCodes...)
 	threeAddressCodes = append(
 		threeAddressCodes,
-		fmt.Sprintf("if %v goto %v", relation, holdGoto),
-		fmt.Sprintf("goto %v", nextGoto),
-		fmt.Sprintf("%v:", holdGoto),
+		ir.CondJump{Condition: condition, Label: l.body},
+		ir.Jump{Label: l.end},
+		ir.Label{Name: l.body},
 	)
 	threeAddressCodes = append(threeAddressCodes, programCodes...)
+	if l.step != "" {
+		threeAddressCodes = append(threeAddressCodes, ir.Label{Name: l.step})
+	}
+	threeAddressCodes = append(threeAddressCodes, stepCodes...)
 	threeAddressCodes = append(
 		threeAddressCodes,
-		fmt.Sprintf("goto %v", whileGoto),
-		fmt.Sprintf("%v:", nextGoto),
+		ir.Jump{Label: l.loop},
+		ir.Label{Name: l.end},
 	)
-	return threeAddressCodes, identifiers, nil
+	return threeAddressCodes
 }
 
-type OutputStatementAST struct {
-	Arguments []ExpressionAST
+// for v in start..end step n { }, or for v in array { }
+type ForStatementAST struct {
+	// the loop variable, declared in the body
+	Variable int
+	// for a range, nil when going over an array
+	Start ExpressionAST
+	End   ExpressionAST
+	// nil for a step of 1
+	Step ExpressionAST
+	// ..= rather than ..
+	Inclusive bool
+	// for the elements of an array, nil for a range
+	Array   ExpressionAST
+	Program ProgramAST
+	Loop    *Loop
+	Span    Span
 }
 
-func (o OutputStatementAST) PerformChecks(identifiers []IdentifierInformation) error {
-	if len(o.Arguments) == 0 {
-		return errors.New("output should always have the first argument")
+func (f ForStatementAST) PerformChecks(identifiers []IdentifierInformation) error {
+	var err error
+	if f.Array != nil {
+		err = f.checkArray(identifiers)
+	} else {
+		err = f.checkRange(identifiers)
 	}
-	datatype, err := o.Arguments[0].GetDatatype(identifiers)
 	if err != nil {
 		return err
 	}
-	if !datatype.IsDatatype(StringDatatype{}) {
-		return errors.New("output should always have the first argument as a string")
-	}
-	for _, output := range o.Arguments[1:] {
-		_, err = output.GetDatatype(identifiers)
+	return f.Program.PerformAllChecks(identifiers)
+}
+
+func (f ForStatementAST) checkRange(identifiers []IdentifierInformation) error {
+	for _, bound := range []ExpressionAST{f.Start, f.End} {
+		datatype, err := bound.GetDatatype(identifiers)
 		if err != nil {
 			return err
 		}
+		if !datatype.IsDatatype(TypedInt) {
+			return errorAt(
+				bound.GetSpan(), CodeInvalidLoop,
+				fmt.Sprintf("the bounds of a range should be int, not %v", DatatypeName(datatype)),
+			)
+		}
+	}
+	if f.Step != nil {
+		if step, ok := f.stepValue(); !ok || step == 0 {
+			return errorAt(
+				f.Step.GetSpan(), CodeInvalidLoop, "the step of a range should be a non-zero int literal",
+			)
+		}
 	}
+	identifiers[f.Variable].Datatype = TypedInt
 	return nil
 }
 
-func (o OutputStatementAST) ThreeAddressCode(
-	identifiers []IdentifierInformation,
-	numberOfGotos *int,
-) ([]string, []IdentifierInformation, error) {
-	threeAddressCodes := []string{}
-	parameters := []string{}
-
-	for _, argument := range o.Arguments {
-		param, codes, ids, err := argument.ThreeAddressCode(identifiers)
-		if err != nil {
-			return []string{}, identifiers, err
-		}
-		identifiers = ids
-		threeAddressCodes = append(threeAddressCodes, codes...)
-		parameters = append(parameters, fmt.Sprintf("param %v", param))
+func (f ForStatementAST) checkArray(identifiers []IdentifierInformation) error {
+	datatype, err := f.Array.GetDatatype(identifiers)
+	if err != nil {
+		return err
 	}
-
-	threeAddressCodes = append(threeAddressCodes, parameters...)
-	threeAddressCodes = append(threeAddressCodes, fmt.Sprintf(
-		"call printf %v",
-		len(parameters),
-	))
-	return threeAddressCodes, identifiers, nil
+	arrayDatatype, ok := datatype.(ArrayDatatype)
+	if !ok {
+		return errorAt(
+			f.Array.GetSpan(), CodeInvalidLoop,
+			fmt.Sprintf("for goes over a range or an array, not %v", DatatypeName(datatype)),
+		)
+	}
+	identifiers[f.Variable].Datatype = arrayDatatype.ElementType
+	return nil
 }
 
-type ExpressionAST interface {
-	GetDatatype(identifiers []IdentifierInformation) (Datatype, error)
-	ThreeAddressCode(
-		identifiers []IdentifierInformation,
-	) (string, []string, []IdentifierInformation, error)
+// the step as a number, known when compiling so that the direction of the loop is known
+func (f ForStatementAST) stepValue() (int64, bool) {
+	if f.Step == nil {
+		return 1, true
+	}
+	step := f.Step
+	sign := int64(1)
+	if unary, ok := step.(UnaryExpression); ok && unary.Operator == UnaryMinus {
+		step, sign = unary.Operand, -1
+	}
+	literal, ok := step.(Literal)
+	if !ok || literal.Datatype != TypedInt {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(literal.Value, 10, 64)
+	return sign * value, err == nil
 }
 
-type UnaryExpression struct {
-	Operator UnaryOperatorNode
-	Operand  ExpressionAST
+func (f ForStatementAST) GetSpan() Span {
+	return f.Span
 }
 
-type UnaryOperatorNode int
-
-const (
-	UnaryMinus UnaryOperatorNode = iota + 1
-	UnaryNot
-)
-
-var nameWithUnaryOperator = map[UnaryOperatorNode]string{
-	UnaryMinus: "-",
-	UnaryNot:   "!",
-}
+func (f ForStatementAST) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	labels := loopLabels{
+		loop: getNextGoto(numberOfGotos),
+		body: getNextGoto(numberOfGotos),
+		step: getNextGoto(numberOfGotos),
+		end:  getNextGoto(numberOfGotos),
+	}
+	f.Loop.enter(labels)
+	if f.Array != nil {
+		return f.arrayThreeAddressCode(labels, identifiers, numberOfGotos)
+	}
 
-func (u UnaryExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
-	operandDatatype, err := u.Operand.GetDatatype(identifiers)
+	step, ok := f.stepValue()
+	if !ok {
+		return []ir.Instruction{}, identifiers, errors.New("step of a range is not a literal")
+	}
+	start, codes, identifiers, err := f.Start.ThreeAddressCode(identifiers, numberOfGotos)
 	if err != nil {
-		return nil, err
+		return []ir.Instruction{}, identifiers, err
 	}
-	return operandDatatype.PerformUnaryOperation(u.Operator)
-}
-
-func (u UnaryExpression) ThreeAddressCode(
-	identifiers []IdentifierInformation,
-) (string, []string, []IdentifierInformation, error) {
-	var label string
-
-	datatype, err := u.GetDatatype(identifiers)
+	end, endCodes, identifiers, err := f.End.ThreeAddressCode(identifiers, numberOfGotos)
 	if err != nil {
-		return "", []string{}, identifiers, err
+		return []ir.Instruction{}, identifiers, err
 	}
-
-	label, identifiers = nextIdentifier(identifiers, datatype)
-	result, threeAddressCodes, identifiers, err := u.Operand.ThreeAddressCode(
-		identifiers,
+	// the end is evaluated once, before the loop
+	limit, identifiers := nextIdentifier(identifiers, TypedInt)
+	codes = append(codes, endCodes...)
+	codes = append(
+		codes,
+		ir.Assign{Destination: limit, Source: end},
+		ir.Assign{Destination: ir.Identifier(f.Variable), Source: start},
 	)
+
+	operator := ir.LesserThan
+	switch {
+	case step > 0 && f.Inclusive:
+		operator = ir.LesserThanOrEqual
+	case step < 0 && f.Inclusive:
+		operator = ir.GreaterThanOrEqual
+	case step < 0:
+		operator = ir.GreaterThan
+	}
+	condition, identifiers := nextIdentifier(identifiers, TypedBool)
+	conditionCodes := []ir.Instruction{ir.BinOp{
+		Destination: condition,
+		Operator:    operator,
+		First:       ir.Identifier(f.Variable),
+		Second:      limit,
+	}}
+
+	programCodes, identifiers, err := f.Program.ThreeAddressCode(identifiers, numberOfGotos)
 	if err != nil {
-		return "", []string{}, identifiers, err
+		return []ir.Instruction{}, identifiers, err
 	}
-	threeAddressCodes = append(threeAddressCodes, fmt.Sprintf(
-		"%v = %v %v",
-		label,
-		nameWithUnaryOperator[u.Operator],
-		result,
-	))
-
-	return label, threeAddressCodes, identifiers, nil
-}
-
-type BinaryExpression struct {
-	Operator      BinaryOperatorNode
-	FirstOperand  ExpressionAST
-	SecondOperand ExpressionAST
-}
-
-type BinaryOperatorNode int
-
-const (
-	BinaryPlus BinaryOperatorNode = iota + 1
-	BinaryMinus
-	BinaryMul
-	BinaryDiv
-	BinaryModulo
-
-	BinaryRelationalEquals
-	BinaryRelationalGreaterThan
-	BinaryRelationalGreaterThanOrEquals
-	BinaryRelationalNotEquals
-	BinaryRelationalLesserThan
-	BinaryRelationalLesserThanOrEquals
-
-	BinaryOr
-	BinaryAnd
-)
-
-var nameWithBinaryOperator = map[BinaryOperatorNode]string{
-	BinaryPlus:   "+",
-	BinaryMinus:  "-",
-	BinaryMul:    "*",
-	BinaryDiv:    "/",
-	BinaryModulo: "%",
-
-	BinaryRelationalEquals:              "==",
-	BinaryRelationalGreaterThan:         ">",
-	BinaryRelationalGreaterThanOrEquals: ">=",
-	BinaryRelationalNotEquals:           "!=",
-	BinaryRelationalLesserThan:          "<",
-	BinaryRelationalLesserThanOrEquals:  "<=",
+	stepCodes := []ir.Instruction{}
+	if f.Inclusive || step != 1 && step != -1 {
+		// the loop ends before a step past the int range, which would never be past the end,
+		// e.g., for i in 0..=9223372036854775807 { }
+		pastOperator, last := ir.GreaterThan, int64(math.MaxInt64)-step
+		if step < 0 {
+			pastOperator, last = ir.LesserThan, math.MinInt64-step
+		}
+		var past ir.Identifier
+		past, identifiers = nextIdentifier(identifiers, TypedBool)
+		stepCodes = append(
+			stepCodes,
+			ir.BinOp{
+				Destination: past,
+				Operator:    pastOperator,
+				First:       ir.Identifier(f.Variable),
+				Second:      ir.Int(int(last)),
+			},
+			ir.CondJump{Condition: past, Label: labels.end},
+		)
+	}
+	stepCodes = append(stepCodes, ir.BinOp{
+		Destination: ir.Identifier(f.Variable),
+		Operator:    ir.Add,
+		First:       ir.Identifier(f.Variable),
+		Second:      ir.Int(int(step)),
+	})
 
-	BinaryOr:  "||",
-	BinaryAnd: "&&",
+	codes = append(codes, labels.threeAddressCode(condition, conditionCodes, programCodes, stepCodes)...)
+	return codes, identifiers, nil
 }
 
-func (b BinaryExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
-	firstOperandDatatype, err := b.FirstOperand.GetDatatype(identifiers)
+// goes over the array by a hidden index, loading each element into the loop variable
+func (f ForStatementAST) arrayThreeAddressCode(
+	labels loopLabels,
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	datatype, err := f.Array.GetDatatype(identifiers)
 	if err != nil {
-		return nil, err
+		return []ir.Instruction{}, identifiers, err
 	}
-	secondOperandDatatype, err := b.SecondOperand.GetDatatype(identifiers)
+	arrayDatatype, ok := datatype.(ArrayDatatype)
+	if !ok {
+		return []ir.Instruction{}, identifiers, errors.New("for over a non-array")
+	}
+	// the elements are flattened, so an element that is an array takes up all of its elements
+	_, elementLength, err := arrayDatatype.ElementType.ToString()
 	if err != nil {
-		return nil, err
+		return []ir.Instruction{}, identifiers, err
 	}
-	return firstOperandDatatype.PerformBinaryOperation(b.Operator, secondOperandDatatype)
-}
 
-func (b BinaryExpression) ThreeAddressCode(
-	identifiers []IdentifierInformation,
-) (string, []string, []IdentifierInformation, error) {
-	datatype, err := b.GetDatatype(identifiers)
+	result, codes, identifiers, err := f.Array.ThreeAddressCode(identifiers, numberOfGotos)
 	if err != nil {
-		return "", []string{}, identifiers, err
+		return []ir.Instruction{}, identifiers, err
+	}
+	array, ok := result.(ir.Identifier)
+	if !ok {
+		return []ir.Instruction{}, identifiers, errors.New("array to go over is not an identifier")
+	}
+	if !identifiers[array].Temporary && identifiers[array].Mutable {
+		// the body may change the array, but the loop goes over it as it was
+		var arrayCopy ir.Identifier
+		arrayCopy, identifiers = nextIdentifier(identifiers, datatype)
+		codes = append(codes, ir.Assign{Destination: arrayCopy, Source: array})
+		array = arrayCopy
 	}
-	label, identifiers := nextIdentifier(identifiers, datatype)
-	threeAddressCodes := []string{}
 
-	firstResult, firstOperandCodes, identifiers, err := b.FirstOperand.ThreeAddressCode(
-		identifiers,
-	)
-	if err != nil {
-		return "", []string{}, identifiers, err
+	index, identifiers := nextIdentifier(identifiers, TypedInt)
+	codes = append(codes, ir.Assign{Destination: index, Source: ir.Int(0)})
+
+	condition, identifiers := nextIdentifier(identifiers, TypedBool)
+	conditionCodes := []ir.Instruction{ir.BinOp{
+		Destination: condition,
+		Operator:    ir.LesserThan,
+		First:       index,
+		Second:      ir.Int(arrayDatatype.NumberOfElements),
+	}}
+
+	offset, identifiers := nextIdentifier(identifiers, TypedInt)
+	loadCodes := []ir.Instruction{
+		ir.BinOp{
+			Destination: offset,
+			Operator:    ir.Multiply,
+			First:       index,
+			Second:      ir.Int(elementLength),
+		},
+		ir.IndexLoad{Destination: ir.Identifier(f.Variable), Array: array, Index: offset},
 	}
-	threeAddressCodes = append(threeAddressCodes, firstOperandCodes...)
 
-	secondResult, secondOperandCodes, identifiers, err := b.SecondOperand.ThreeAddressCode(
-		identifiers,
-	)
+	programCodes, identifiers, err := f.Program.ThreeAddressCode(identifiers, numberOfGotos)
 	if err != nil {
-		return "", []string{}, identifiers, err
+		return []ir.Instruction{}, identifiers, err
 	}
-	threeAddressCodes = append(threeAddressCodes, secondOperandCodes...)
-
-	threeAddressCodes = append(threeAddressCodes, fmt.Sprintf(
-		"%v = %v %v %v",
-		label,
-		firstResult,
-		nameWithBinaryOperator[b.Operator],
-		secondResult,
-	))
-
-	return label, threeAddressCodes, identifiers, nil
+	stepCodes := []ir.Instruction{ir.BinOp{
+		Destination: index,
+		Operator:    ir.Add,
+		First:       index,
+		Second:      ir.Int(1),
+	}}
+
+	codes = append(
+		codes,
+		labels.threeAddressCode(
+			condition, conditionCodes, append(loadCodes, programCodes...), stepCodes,
+		)...,
+	)
+	return codes, identifiers, nil
 }
 
-type InputExpression struct{}
-
-func (i InputExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
-	return TypedChar, nil
-}
+// the built-in that an output statement calls
+type OutputFunction int
 
-func (i InputExpression) ThreeAddressCode(
-	identifiers []IdentifierInformation,
-) (string, []string, []IdentifierInformation, error) {
-	label, identifiers := nextIdentifier(identifiers, TypedChar)
-	return label, []string{
-		fmt.Sprintf("%v = call getchar 0", label),
-	}, identifiers, nil
-}
+const (
+	// the first argument is the format string
+	OutputPrintf OutputFunction = iota
+	// the arguments are printed according to their datatypes, separated by spaces
+	OutputPrint
+	// print, followed by a new line
+	OutputPrintln
+)
 
-type ArrayExpression struct {
-	Elements []ExpressionAST
+type OutputStatementAST struct {
+	Function  OutputFunction
+	Arguments []ExpressionAST
+	Span      Span
 }
 
-func (a ArrayExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
-	if len(a.Elements) == 0 {
-		return ArrayDatatype{
-			ElementType:      TypedUnknown,
-			NumberOfElements: 0,
-		}, nil
+func (o OutputStatementAST) PerformChecks(identifiers []IdentifierInformation) error {
+	if o.Function != OutputPrintf {
+		for _, argument := range o.Arguments {
+			datatype, err := argument.GetDatatype(identifiers)
+			if err != nil {
+				return err
+			}
+			if datatype.IsDatatype(VoidDatatype{}) {
+				return errorAt(
+					argument.GetSpan(), CodeVoidValue, "a function without a return value cannot be an output",
+				)
+			}
+		}
+		return nil
 	}
-	baseDatatype, err := a.Elements[0].GetDatatype(identifiers)
+	if len(o.Arguments) == 0 {
+		return errorAt(o.Span, CodeInvalidOutput, "output should always have the first argument")
+	}
+	datatype, err := o.Arguments[0].GetDatatype(identifiers)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	for _, element := range a.Elements {
-		datatype, err := element.GetDatatype(identifiers)
+	if !datatype.IsDatatype(StringDatatype{}) {
+		return errorAt(
+			o.Span, CodeInvalidOutput, "output should always have the first argument as a string",
+		)
+	}
+	argumentDatatypes := []Datatype{}
+	for _, output := range o.Arguments[1:] {
+		datatype, err = output.GetDatatype(identifiers)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if !datatype.IsDatatype(baseDatatype) {
-			return nil, errors.New("unmatching datatypes in array")
+		if datatype.IsDatatype(VoidDatatype{}) {
+			return errorAt(o.Span, CodeVoidValue, "a function without a return value cannot be an output")
 		}
+		argumentDatatypes = append(argumentDatatypes, datatype)
 	}
-	return ArrayDatatype{
-		ElementType:      baseDatatype,
-		NumberOfElements: len(a.Elements),
-	}, nil
+	return o.checkFormat(argumentDatatypes)
 }
 
-func (a ArrayExpression) ThreeAddressCode(
-	identifiers []IdentifierInformation,
-) (string, []string, []IdentifierInformation, error) {
-	datatype, err := a.GetDatatype(identifiers)
-	if err != nil {
-		return "", []string{}, identifiers, err
+// matches every conversion in the format string with the datatype of its argument
+func (o OutputStatementAST) checkFormat(argumentDatatypes []Datatype) error {
+	format, ok := o.Arguments[0].(Literal)
+	if !ok {
+		// only known when the program runs
+		return nil
 	}
-
-	elements, err := a.flattenElements(identifiers)
+	// without the quotes
+	conversions, err := parseFormat(format.Value[1 : len(format.Value)-1])
 	if err != nil {
-		return "", []string{}, identifiers, err
+		return errorAt(format.Span, CodeInvalidFormat, err.Error())
 	}
 
-	label, identifiers := nextIdentifier(identifiers, datatype)
-	threeAddressCodes := []string{}
-	for index, element := range elements {
-		result, t, ids, err := element.ThreeAddressCode(identifiers)
-		if err != nil {
-			return "", []string{}, identifiers, err
+	for index, conversion := range conversions {
+		if !conversion.isSupported() {
+			return errorAt(
+				conversion.spanIn(format.Span), CodeInvalidFormat,
+				fmt.Sprintf("unsupported conversion %v", conversion.directive),
+			)
 		}
-		threeAddressCodes = append(threeAddressCodes, t...)
-		threeAddressCodes = append(
-			threeAddressCodes,
-			fmt.Sprintf("%v [] %v = %v", label, index, result),
+		if index >= len(argumentDatatypes) {
+			return errorAt(
+				conversion.spanIn(format.Span), CodeFormatArgumentCount,
+				fmt.Sprintf("%v has no argument", conversion.directive),
+			)
+		}
+		argument := o.Arguments[index+1]
+		datatype := argumentDatatypes[index]
+		if conversion.accepts(datatype) {
+			continue
+		}
+		err := errorAt(
+			argument.GetSpan(), CodeFormatMismatch,
+			fmt.Sprintf(
+				"%v does not print a value of type %v",
+				conversion.directive, DatatypeName(datatype),
+			),
 		)
-		identifiers = ids
+		err.Notes = append(err.Notes, Note{
+			Message: "the conversion is here",
+			Span:    conversion.spanIn(format.Span),
+		})
+		if suggestion := conversionsFor(datatype); suggestion != "" {
+			err.Notes = append(err.Notes, Note{
+				Message: fmt.Sprintf(
+					"%v values are printed with %v", DatatypeName(datatype), suggestion,
+				),
+			})
+		}
+		return err
 	}
-	return label, threeAddressCodes, identifiers, nil
+
+	if len(argumentDatatypes) > len(conversions) {
+		extra := o.Arguments[len(conversions)+1:]
+		return errorAt(
+			extra[0].GetSpan().Join(extra[len(extra)-1].GetSpan()), CodeFormatArgumentCount,
+			fmt.Sprintf(
+				"the format string has %v conversions, but %v arguments are given",
+				len(conversions), len(argumentDatatypes),
+			),
+		)
+	}
+	return nil
 }
 
-func (a ArrayExpression) flattenElements(
-	identifiers []IdentifierInformation,
+func (o OutputStatementAST) GetSpan() Span {
+	return o.Span
+}
+
+func (o OutputStatementAST) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	threeAddressCodes := []ir.Instruction{}
+	parameters := []ir.Param{}
+
+	for index, argument := range o.Arguments {
+		datatype, err := argument.GetDatatype(identifiers)
+		if err != nil {
+			return []ir.Instruction{}, identifiers, err
+		}
+		param, codes, ids, err := argument.ThreeAddressCode(identifiers, numberOfGotos)
+		if err != nil {
+			return []ir.Instruction{}, identifiers, err
+		}
+		identifiers = ids
+		threeAddressCodes = append(threeAddressCodes, codes...)
+
+		if o.Function == OutputPrintf {
+			parameters = append(parameters, ir.Param{Value: param})
+			continue
+		}
+		if index > 0 {
+			parameters = appendText(parameters, " ")
+		}
+		arrayDatatype, ok := datatype.(ArrayDatatype)
+		array, isIdentifier := param.(ir.Identifier)
+		if !ok || !isIdentifier {
+			parameters = append(parameters, ir.Param{Value: param})
+			continue
+		}
+		offset := 0
+		var loads []ir.Instruction
+		loads, parameters, identifiers = arrayParameters(
+			array, arrayDatatype, &offset, parameters, identifiers,
+		)
+		threeAddressCodes = append(threeAddressCodes, loads...)
+	}
+
+	function := ir.Printf
+	if o.Function != OutputPrintf {
+		function = ir.PrintValues
+	}
+	if o.Function == OutputPrintln {
+		parameters = appendText(parameters, "\\n")
+	}
+	for _, parameter := range parameters {
+		threeAddressCodes = append(threeAddressCodes, parameter)
+	}
+	threeAddressCodes = append(threeAddressCodes, ir.Call{
+		Result:    ir.NoIdentifier,
+		Function:  function,
+		Arguments: len(parameters),
+	})
+	return threeAddressCodes, identifiers, nil
+}
+
+// Loads every element of the array, and adds them to the parameters of print as [1, 2, 3].
+// The elements of nested arrays are stored one after the other, so offset counts all of them.
+func arrayParameters(
+	array ir.Identifier,
+	datatype ArrayDatatype,
+	offset *int,
+	parameters []ir.Param,
+	identifiers []IdentifierInformation,
+) ([]ir.Instruction, []ir.Param, []IdentifierInformation) {
+	loads := []ir.Instruction{}
+	parameters = appendText(parameters, "[")
+	for index := range datatype.NumberOfElements {
+		if index > 0 {
+			parameters = appendText(parameters, ", ")
+		}
+		if elementDatatype, ok := datatype.ElementType.(ArrayDatatype); ok {
+			var elementLoads []ir.Instruction
+			elementLoads, parameters, identifiers = arrayParameters(
+				array, elementDatatype, offset, parameters, identifiers,
+			)
+			loads = append(loads, elementLoads...)
+			continue
+		}
+		var element ir.Identifier
+		element, identifiers = nextIdentifier(identifiers, datatype.ElementType)
+		loads = append(loads, ir.IndexLoad{Destination: element, Array: array, Index: ir.Int(*offset)})
+		parameters = append(parameters, ir.Param{Value: element})
+		(*offset)++
+	}
+	parameters = appendText(parameters, "]")
+	return loads, parameters, identifiers
+}
+
+// adds the text, as written in a string literal, merging it into the string literal before it if there is one
+func appendText(parameters []ir.Param, text string) []ir.Param {
+	if len(parameters) > 0 {
+		last, ok := parameters[len(parameters)-1].Value.(ir.Literal)
+		if ok && last.Kind == ir.LiteralString {
+			parameters[len(parameters)-1].Value = ir.Literal{
+				Kind:  ir.LiteralString,
+				Value: last.Value[:len(last.Value)-1] + text + "\"",
+			}
+			return parameters
+		}
+	}
+	return append(parameters, ir.Param{Value: ir.Literal{Kind: ir.LiteralString, Value: "\"" + text + "\""}})
+}
+
+type FunctionAST struct {
+	Function   int
+	Parameters []int
+	// identifiers declared inside the function body
+	Locals  []int
+	Program ProgramAST
+	Span    Span
+}
+
+func (f FunctionAST) PerformChecks(identifiers []IdentifierInformation) error {
+	functionDatatype, ok := identifiers[f.Function].Datatype.(FunctionDatatype)
+	if !ok {
+		return errorAt(f.Span, CodeNotAFunction, "function declaration on a non-function")
+	}
+	err := f.Program.PerformAllChecks(identifiers)
+	if err != nil {
+		return err
+	}
+	if !functionDatatype.ReturnType.IsDatatype(VoidDatatype{}) && !f.Program.alwaysReturns() {
+		return errorAt(f.Span, CodeMissingReturn, fmt.Sprintf(
+			"function %v does not return a value on every path",
+			identifiers[f.Function].IdentifierName,
+		))
+	}
+	return nil
+}
+
+func (f FunctionAST) GetSpan() Span {
+	return f.Span
+}
+
+func (f FunctionAST) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	// every temporary created while lowering the body belongs to the function
+	temporariesFrom := len(identifiers)
+	programCodes, identifiers, err := f.Program.ThreeAddressCode(identifiers, numberOfGotos)
+	if err != nil {
+		return []ir.Instruction{}, identifiers, err
+	}
+
+	header := ir.FunctionStart{
+		Function:   ir.Identifier(f.Function),
+		Parameters: []ir.Identifier{},
+	}
+	for _, parameter := range f.Parameters {
+		header.Parameters = append(header.Parameters, ir.Identifier(parameter))
+	}
+	threeAddressCodes := []ir.Instruction{header}
+	for _, local := range f.Locals {
+		threeAddressCodes = append(
+			threeAddressCodes,
+			ir.Local{Identifier: ir.Identifier(local)},
+		)
+	}
+	for index := temporariesFrom; index < len(identifiers); index++ {
+		threeAddressCodes = append(
+			threeAddressCodes,
+			ir.Local{Identifier: ir.Identifier(index)},
+		)
+	}
+	threeAddressCodes = append(threeAddressCodes, programCodes...)
+	threeAddressCodes = append(threeAddressCodes, ir.FunctionEnd{})
+	return threeAddressCodes, identifiers, nil
+}
+
+type ReturnAST struct {
+	Function int
+	// nil when nothing is returned
+	Value ExpressionAST
+	Span  Span
+}
+
+func (r ReturnAST) PerformChecks(identifiers []IdentifierInformation) error {
+	functionDatatype, ok := identifiers[r.Function].Datatype.(FunctionDatatype)
+	if !ok {
+		return errorAt(r.Span, CodeReturnOutsideFunction, "return from a non-function")
+	}
+	if r.Value == nil {
+		if !functionDatatype.ReturnType.IsDatatype(VoidDatatype{}) {
+			return errorAt(
+				r.Span, CodeInvalidReturn, "return without a value in a function that returns a value",
+			)
+		}
+		return nil
+	}
+	if functionDatatype.ReturnType.IsDatatype(VoidDatatype{}) {
+		return errorAt(
+			r.Span, CodeInvalidReturn, "return with a value in a function that does not return a value",
+		)
+	}
+	datatype, err := datatypeFor(r.Value, functionDatatype.ReturnType, identifiers)
+	if err != nil {
+		return err
+	}
+	adapted, err := literalAdapts(r.Value, functionDatatype.ReturnType)
+	if err != nil {
+		return err
+	}
+	if !adapted && !functionDatatype.ReturnType.IsDatatype(datatype) {
+		return errorAt(
+			r.Value.GetSpan(),
+			CodeMismatchedTypes,
+			"returned datatype does not match the return type of the function",
+		)
+	}
+	return nil
+}
+
+func (r ReturnAST) GetSpan() Span {
+	return r.Span
+}
+
+func (r ReturnAST) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	if r.Value == nil {
+		return []ir.Instruction{ir.Return{}}, identifiers, nil
+	}
+	result, codes, identifiers, err := r.Value.ThreeAddressCode(identifiers, numberOfGotos)
+	if err != nil {
+		return []ir.Instruction{}, identifiers, err
+	}
+	codes = append(codes, ir.Return{Value: result})
+	return codes, identifiers, nil
+}
+
+// leaves the loop, which is the innermost one unless the break has a label
+type BreakAST struct {
+	Loop *Loop
+	Span Span
+}
+
+func (b BreakAST) PerformChecks(identifiers []IdentifierInformation) error {
+	return nil
+}
+
+func (b BreakAST) GetSpan() Span {
+	return b.Span
+}
+
+func (b BreakAST) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	if b.Loop == nil || b.Loop.labels.end == "" {
+		return []ir.Instruction{}, identifiers, errors.New("break outside of the loop it leaves")
+	}
+	return []ir.Instruction{ir.Jump{Label: b.Loop.labels.end}}, identifiers, nil
+}
+
+// An array declared without a value, e.g., let mut buf: [char; 64];
+// whose elements are set to 0, false or '\0' every time the declaration is run.
+type ArrayDeclarationAST struct {
+	Identifier int
+	Span       Span
+}
+
+func (a ArrayDeclarationAST) PerformChecks(identifiers []IdentifierInformation) error {
+	return nil
+}
+
+func (a ArrayDeclarationAST) GetSpan() Span {
+	return a.Span
+}
+
+// the arrays are held as one run of elements, so they are set one at a time as
+//
+//	position = 0
+//	body: array[position] = 0; position = position + 1; if position < length goto body
+func (a ArrayDeclarationAST) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	if _, ok := identifiers[a.Identifier].Datatype.(ArrayDatatype); !ok {
+		return []ir.Instruction{}, identifiers, errors.New("array declaration of a variable that is not an array")
+	}
+	var element Datatype = identifiers[a.Identifier].Datatype
+	length := 1
+	for {
+		arrayDatatype, ok := element.(ArrayDatatype)
+		if !ok {
+			break
+		}
+		length *= arrayDatatype.NumberOfElements
+		element = arrayDatatype.ElementType
+	}
+
+	var zero ir.Operand = ir.Int(0)
+	switch element {
+	case TypedFloat, TypedF32:
+		zero = ir.Literal{Kind: ir.LiteralFloat, Value: "0.0"}
+	case TypedChar:
+		zero = ir.Literal{Kind: ir.LiteralChar, Value: "'\\0'"}
+	case TypedBool:
+		zero = ir.Literal{Kind: ir.LiteralBool, Value: "false"}
+	}
+
+	position, identifiers := nextIdentifier(identifiers, TypedInt)
+	more, identifiers := nextIdentifier(identifiers, TypedBool)
+	body := getNextGoto(numberOfGotos)
+	return []ir.Instruction{
+		ir.Assign{Destination: position, Source: ir.Int(0)},
+		ir.Label{Name: body},
+		ir.IndexStore{Array: ir.Identifier(a.Identifier), Index: position, Value: zero},
+		ir.BinOp{Destination: position, Operator: ir.Add, First: position, Second: ir.Int(1)},
+		ir.BinOp{Destination: more, Operator: ir.LesserThan, First: position, Second: ir.Int(length)},
+		ir.CondJump{Condition: more, Label: body},
+	}, identifiers, nil
+}
+
+// goes on to the next iteration of the loop, after the step of a for loop
+type ContinueAST struct {
+	Loop *Loop
+	Span Span
+}
+
+func (c ContinueAST) PerformChecks(identifiers []IdentifierInformation) error {
+	return nil
+}
+
+func (c ContinueAST) GetSpan() Span {
+	return c.Span
+}
+
+func (c ContinueAST) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	if c.Loop == nil || c.Loop.labels.loop == "" {
+		return []ir.Instruction{}, identifiers, errors.New("continue outside of the loop it continues")
+	}
+	label := c.Loop.labels.step
+	if label == "" {
+		// a while loop goes straight back to its condition
+		label = c.Loop.labels.loop
+	}
+	return []ir.Instruction{ir.Jump{Label: label}}, identifiers, nil
+}
+
+// a function call whose returned value, if any, is discarded
+type CallStatementAST struct {
+	Call CallExpression
+	Span Span
+}
+
+func (c CallStatementAST) PerformChecks(identifiers []IdentifierInformation) error {
+	_, err := c.Call.GetDatatype(identifiers)
+	return err
+}
+
+func (c CallStatementAST) GetSpan() Span {
+	return c.Span
+}
+
+func (c CallStatementAST) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	threeAddressCodes, identifiers, err := c.Call.argumentCodes(identifiers, numberOfGotos)
+	if err != nil {
+		return []ir.Instruction{}, identifiers, err
+	}
+	threeAddressCodes = append(threeAddressCodes, ir.Call{
+		Result:    ir.NoIdentifier,
+		Function:  ir.Identifier(c.Call.Function),
+		Arguments: len(c.Call.Arguments),
+	})
+	return threeAddressCodes, identifiers, nil
+}
+
+// checks if every path through the program ends in a return
+func (p ProgramAST) alwaysReturns() bool {
+	for _, instruction := range p.Instructions {
+		switch statement := instruction.(type) {
+		case ReturnAST:
+			return true
+
+		case IfStatementAST:
+			if statement.alwaysReturns() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (i IfStatementAST) alwaysReturns() bool {
+	if len(i.IfExpressions) == 0 {
+		return false
+	}
+	for _, expression := range i.IfExpressions {
+		if !expression.Program.alwaysReturns() {
+			return false
+		}
+	}
+	// without an else, none of the branches might be taken
+	lastCondition, ok := i.IfExpressions[len(i.IfExpressions)-1].Condition.(Literal)
+	return ok && lastCondition.Value == "true"
+}
+
+type ExpressionAST interface {
+	GetDatatype(identifiers []IdentifierInformation) (Datatype, error)
+	GetSpan() Span
+	ThreeAddressCode(
+		identifiers []IdentifierInformation,
+		numberOfGotos *int,
+	) (ir.Operand, []ir.Instruction, []IdentifierInformation, error)
+}
+
+type UnaryExpression struct {
+	Operator UnaryOperatorNode
+	Operand  ExpressionAST
+	Span     Span
+}
+
+type UnaryOperatorNode int
+
+const (
+	UnaryMinus UnaryOperatorNode = iota + 1
+	UnaryNot
+)
+
+var nameWithUnaryOperator = map[UnaryOperatorNode]ir.Operator{
+	UnaryMinus: ir.Negate,
+	UnaryNot:   ir.Not,
+}
+
+func (u UnaryExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
+	var operandDatatype Datatype
+	var err error
+	if literal, ok := u.Operand.(Literal); ok && u.Operator == UnaryMinus {
+		// the range of a negative literal is checked with its sign, so that -128i8 fits
+		operandDatatype, err = literal.checkRange(true)
+	} else {
+		operandDatatype, err = u.Operand.GetDatatype(identifiers)
+	}
+	if err != nil {
+		return nil, err
+	}
+	datatype, err := operandDatatype.PerformUnaryOperation(u.Operator)
+	return datatype, withSpan(u.Span, err)
+}
+
+func (u UnaryExpression) GetSpan() Span {
+	return u.Span
+}
+
+func (u UnaryExpression) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	var label ir.Identifier
+
+	datatype, err := u.GetDatatype(identifiers)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+
+	label, identifiers = nextIdentifier(identifiers, datatype)
+	if literal, ok := u.Operand.(Literal); ok && u.Operator == UnaryMinus && datatype == TypedInt {
+		if value, err := strconv.ParseUint(literal.Value, 10, 64); err == nil && value == 1<<63 {
+			// C has no literal for the smallest int, only its negation
+			return label, []ir.Instruction{ir.BinOp{
+				Destination: label,
+				Operator:    ir.Subtract,
+				First:       ir.Literal{Kind: ir.LiteralInt, Value: strconv.FormatInt(math.MinInt64+1, 10)},
+				Second:      ir.Int(1),
+			}}, identifiers, nil
+		}
+	}
+	result, threeAddressCodes, identifiers, err := u.Operand.ThreeAddressCode(
+		identifiers,
+		numberOfGotos,
+	)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+	threeAddressCodes = append(threeAddressCodes, ir.UnOp{
+		Destination: label,
+		Operator:    nameWithUnaryOperator[u.Operator],
+		Operand:     result,
+	})
+
+	return label, threeAddressCodes, identifiers, nil
+}
+
+type BinaryExpression struct {
+	Operator      BinaryOperatorNode
+	FirstOperand  ExpressionAST
+	SecondOperand ExpressionAST
+	Span          Span
+}
+
+type BinaryOperatorNode int
+
+const (
+	BinaryPlus BinaryOperatorNode = iota + 1
+	BinaryMinus
+	BinaryMul
+	BinaryDiv
+	BinaryModulo
+
+	BinaryRelationalEquals
+	BinaryRelationalGreaterThan
+	BinaryRelationalGreaterThanOrEquals
+	BinaryRelationalNotEquals
+	BinaryRelationalLesserThan
+	BinaryRelationalLesserThanOrEquals
+
+	BinaryOr
+	BinaryAnd
+)
+
+// && and || have no operator of their own, since they are lowered to jumps
+var nameWithBinaryOperator = map[BinaryOperatorNode]ir.Operator{
+	BinaryPlus:   ir.Add,
+	BinaryMinus:  ir.Subtract,
+	BinaryMul:    ir.Multiply,
+	BinaryDiv:    ir.Divide,
+	BinaryModulo: ir.Modulo,
+
+	BinaryRelationalEquals:              ir.Equals,
+	BinaryRelationalGreaterThan:         ir.GreaterThan,
+	BinaryRelationalGreaterThanOrEquals: ir.GreaterThanOrEqual,
+	BinaryRelationalNotEquals:           ir.NotEquals,
+	BinaryRelationalLesserThan:          ir.LesserThan,
+	BinaryRelationalLesserThanOrEquals:  ir.LesserThanOrEqual,
+}
+
+func (b BinaryExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
+	firstOperandDatatype, firstErr := b.FirstOperand.GetDatatype(identifiers)
+	secondOperandDatatype, err := b.SecondOperand.GetDatatype(identifiers)
+	// e.g., x == 18446744073709551615 for a u64 x
+	if firstErr != nil && err == nil {
+		firstOperandDatatype, firstErr = datatypeFor(b.FirstOperand, secondOperandDatatype, identifiers)
+	} else if firstErr == nil && err != nil {
+		secondOperandDatatype, err = datatypeFor(b.SecondOperand, firstOperandDatatype, identifiers)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	datatype, err := firstOperandDatatype.PerformBinaryOperation(b.Operator, secondOperandDatatype)
+	if err != nil {
+		return nil, withSpan(b.Span, err)
+	}
+
+	// an int is only mixed with a sized number when it is a literal, e.g., x + 1 for a u8 x
+	first, firstOk := firstOperandDatatype.(PrimitiveDatatype)
+	second, secondOk := secondOperandDatatype.(PrimitiveDatatype)
+	if !firstOk || !secondOk || !isSizedNumber(first) && !isSizedNumber(second) {
+		return datatype, nil
+	}
+	domain, err := Promote(first, second)
+	if err != nil {
+		return datatype, nil
+	}
+	for _, operand := range []ExpressionAST{b.FirstOperand, b.SecondOperand} {
+		operandDatatype, err := operand.GetDatatype(identifiers)
+		if err != nil || !operandDatatype.IsDatatype(TypedInt) || domain == TypedFloat {
+			continue
+		}
+		adapted, err := literalAdapts(operand, domain)
+		if err != nil {
+			return nil, err
+		}
+		if !adapted {
+			return nil, errorAt(b.Span, CodeMismatchedTypes, fmt.Sprintf(
+				"int and %v cannot be mixed, convert one of them with as", DatatypeName(domain),
+			))
+		}
+	}
+	return datatype, nil
+}
+
+func (b BinaryExpression) GetSpan() Span {
+	return b.Span
+}
+
+func (b BinaryExpression) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	datatype, err := b.GetDatatype(identifiers)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+	label, identifiers := nextIdentifier(identifiers, datatype)
+	threeAddressCodes := []ir.Instruction{}
+
+	firstResult, firstOperandCodes, identifiers, err := b.FirstOperand.ThreeAddressCode(
+		identifiers,
+		numberOfGotos,
+	)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+	threeAddressCodes = append(threeAddressCodes, firstOperandCodes...)
+
+	if b.Operator == BinaryAnd || b.Operator == BinaryOr {
+		return b.shortCircuitCode(label, firstResult, threeAddressCodes, identifiers, numberOfGotos)
+	}
+
+	secondResult, secondOperandCodes, identifiers, err := b.SecondOperand.ThreeAddressCode(
+		identifiers,
+		numberOfGotos,
+	)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+	threeAddressCodes = append(threeAddressCodes, secondOperandCodes...)
+
+	threeAddressCodes = append(threeAddressCodes, ir.BinOp{
+		Destination: label,
+		Operator:    nameWithBinaryOperator[b.Operator],
+		First:       firstResult,
+		Second:      secondResult,
+	})
+
+	return label, threeAddressCodes, identifiers, nil
+}
+
+// The second operand of && and || is only evaluated when the first does not decide the result.
+// For &&:
+//
+//	t = a
+//	if t goto L1
+//	goto L2
+//	L1:
+//	t = b
+//	L2:
+//
+// For ||, the result is already known when a is true:
+//
+//	t = a
+//	if t goto L2
+//	t = b
+//	L2:
+func (b BinaryExpression) shortCircuitCode(
+	label ir.Identifier,
+	firstResult ir.Operand,
+	threeAddressCodes []ir.Instruction,
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	secondResult, secondOperandCodes, identifiers, err := b.SecondOperand.ThreeAddressCode(
+		identifiers,
+		numberOfGotos,
+	)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+
+	endGoto := getNextGoto(numberOfGotos)
+	threeAddressCodes = append(
+		threeAddressCodes,
+		ir.Assign{Destination: label, Source: firstResult},
+	)
+	if b.Operator == BinaryAnd {
+		secondGoto := getNextGoto(numberOfGotos)
+		threeAddressCodes = append(
+			threeAddressCodes,
+			ir.CondJump{Condition: label, Label: secondGoto},
+			ir.Jump{Label: endGoto},
+			ir.Label{Name: secondGoto},
+		)
+	} else {
+		threeAddressCodes = append(
+			threeAddressCodes,
+			ir.CondJump{Condition: label, Label: endGoto},
+		)
+	}
+	threeAddressCodes = append(threeAddressCodes, secondOperandCodes...)
+	threeAddressCodes = append(
+		threeAddressCodes,
+		ir.Assign{Destination: label, Source: secondResult},
+		ir.Label{Name: endGoto},
+	)
+	return label, threeAddressCodes, identifiers, nil
+}
+
+type InputExpression struct {
+	Span Span
+}
+
+func (i InputExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
+	return TypedChar, nil
+}
+
+func (i InputExpression) GetSpan() Span {
+	return i.Span
+}
+
+func (i InputExpression) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	label, identifiers := nextIdentifier(identifiers, TypedChar)
+	return label, []ir.Instruction{
+		ir.Call{Result: label, Function: ir.Getchar, Arguments: 0},
+	}, identifiers, nil
+}
+
+// len(s) of a string, or len(v) of an array, which is the number of its elements
+type LengthExpression struct {
+	Operand ExpressionAST
+	Span    Span
+}
+
+func (l LengthExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
+	datatype, err := l.Operand.GetDatatype(identifiers)
+	if err != nil {
+		return nil, err
+	}
+	_, isArray := datatype.(ArrayDatatype)
+	if !isArray && !datatype.IsDatatype(StringDatatype{}) {
+		return nil, errorAt(
+			l.Operand.GetSpan(), CodeInvalidOperand,
+			fmt.Sprintf("len expects a string or an array, not %v", DatatypeName(datatype)),
+		)
+	}
+	return TypedInt, nil
+}
+
+func (l LengthExpression) GetSpan() Span {
+	return l.Span
+}
+
+func (l LengthExpression) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	datatype, err := l.Operand.GetDatatype(identifiers)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+	operand, codes, identifiers, err := l.Operand.ThreeAddressCode(identifiers, numberOfGotos)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+	if arrayDatatype, ok := datatype.(ArrayDatatype); ok {
+		// known when compiling
+		return ir.Int(arrayDatatype.NumberOfElements), codes, identifiers, nil
+	}
+
+	label, identifiers := nextIdentifier(identifiers, TypedInt)
+	codes = append(
+		codes,
+		ir.Param{Value: operand},
+		ir.Call{Result: label, Function: ir.Length, Arguments: 1},
+	)
+	return label, codes, identifiers, nil
+}
+
+// x as int, converting the value of a primitive datatype to another
+type CastExpression struct {
+	Operand  ExpressionAST
+	Datatype Datatype
+	Span     Span
+}
+
+func (c CastExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
+	datatype, err := c.Operand.GetDatatype(identifiers)
+	if err != nil {
+		return nil, err
+	}
+	if ConversionBetween(datatype, c.Datatype) == ConversionInvalid {
+		return nil, errorAt(c.Span, CodeInvalidCast, fmt.Sprintf(
+			"%v cannot be converted to %v",
+			DatatypeName(datatype), DatatypeName(c.Datatype),
+		))
+	}
+	return c.Datatype, nil
+}
+
+func (c CastExpression) GetSpan() Span {
+	return c.Span
+}
+
+func (c CastExpression) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	datatype, err := c.Operand.GetDatatype(identifiers)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+	operand, codes, identifiers, err := c.Operand.ThreeAddressCode(identifiers, numberOfGotos)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+	if datatype.IsDatatype(c.Datatype) {
+		return operand, codes, identifiers, nil
+	}
+
+	// the value is converted when it is assigned to a temporary of the other datatype
+	label, identifiers := nextIdentifier(identifiers, c.Datatype)
+	codes = append(codes, ir.Assign{Destination: label, Source: operand})
+	return label, codes, identifiers, nil
+}
+
+type CallExpression struct {
+	Function  int
+	Arguments []ExpressionAST
+	Span      Span
+}
+
+func (c CallExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
+	functionDatatype, ok := identifiers[c.Function].Datatype.(FunctionDatatype)
+	if !ok {
+		return nil, errorAt(c.Span, CodeNotAFunction, "call on a non-function")
+	}
+	if len(c.Arguments) != len(functionDatatype.ParameterTypes) {
+		return nil, errorAt(c.Span, CodeArgumentCount, fmt.Sprintf(
+			"function %v expects %v arguments, but %v were given",
+			identifiers[c.Function].IdentifierName,
+			len(functionDatatype.ParameterTypes),
+			len(c.Arguments),
+		))
+	}
+	for index, argument := range c.Arguments {
+		datatype, err := datatypeFor(argument, functionDatatype.ParameterTypes[index], identifiers)
+		if err != nil {
+			return nil, err
+		}
+		adapted, err := literalAdapts(argument, functionDatatype.ParameterTypes[index])
+		if err != nil {
+			return nil, err
+		}
+		if !adapted && !functionDatatype.ParameterTypes[index].IsDatatype(datatype) {
+			return nil, errorAt(argument.GetSpan(), CodeMismatchedTypes, fmt.Sprintf(
+				"argument %v of function %v does not match the parameter datatype",
+				index+1,
+				identifiers[c.Function].IdentifierName,
+			))
+		}
+	}
+	return functionDatatype.ReturnType, nil
+}
+
+func (c CallExpression) GetSpan() Span {
+	return c.Span
+}
+
+func (c CallExpression) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	datatype, err := c.GetDatatype(identifiers)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+
+	threeAddressCodes, identifiers, err := c.argumentCodes(identifiers, numberOfGotos)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+
+	label, identifiers := nextIdentifier(identifiers, datatype)
+	threeAddressCodes = append(threeAddressCodes, ir.Call{
+		Result:    label,
+		Function:  ir.Identifier(c.Function),
+		Arguments: len(c.Arguments),
+	})
+	return label, threeAddressCodes, identifiers, nil
+}
+
+// evaluates the arguments and passes them on as parameters
+func (c CallExpression) argumentCodes(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) ([]ir.Instruction, []IdentifierInformation, error) {
+	threeAddressCodes := []ir.Instruction{}
+	parameters := []ir.Instruction{}
+
+	for _, argument := range c.Arguments {
+		param, codes, ids, err := argument.ThreeAddressCode(identifiers, numberOfGotos)
+		if err != nil {
+			return []ir.Instruction{}, identifiers, err
+		}
+		identifiers = ids
+		threeAddressCodes = append(threeAddressCodes, codes...)
+		parameters = append(parameters, ir.Param{Value: param})
+	}
+
+	threeAddressCodes = append(threeAddressCodes, parameters...)
+	return threeAddressCodes, identifiers, nil
+}
+
+type ArrayExpression struct {
+	Elements []ExpressionAST
+	Span     Span
+}
+
+func (a ArrayExpression) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
+	if len(a.Elements) == 0 {
+		return ArrayDatatype{
+			ElementType:      TypedUnknown,
+			NumberOfElements: 0,
+		}, nil
+	}
+	baseDatatype, err := a.Elements[0].GetDatatype(identifiers)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range a.Elements {
+		datatype, err := datatypeFor(element, baseDatatype, identifiers)
+		if err != nil {
+			return nil, err
+		}
+		// e.g., [1u8, 2, 3] is an array of u8
+		adapted, err := literalAdapts(element, baseDatatype)
+		if err != nil {
+			return nil, err
+		}
+		if !adapted && !datatype.IsDatatype(baseDatatype) {
+			return nil, errorAt(a.Span, CodeMismatchedTypes, "unmatching datatypes in array")
+		}
+	}
+	if baseDatatype.IsDatatype(VoidDatatype{}) {
+		return nil, errorAt(
+			a.Span, CodeVoidValue, "a function without a return value cannot be an array element",
+		)
+	}
+	return ArrayDatatype{
+		ElementType:      baseDatatype,
+		NumberOfElements: len(a.Elements),
+	}, nil
+}
+
+func (a ArrayExpression) GetSpan() Span {
+	return a.Span
+}
+
+func (a ArrayExpression) ThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	datatype, err := a.GetDatatype(identifiers)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+
+	elements, err := a.flattenElements(identifiers)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+
+	label, identifiers := nextIdentifier(identifiers, datatype)
+	threeAddressCodes := []ir.Instruction{}
+	for index, element := range elements {
+		result, t, ids, err := element.ThreeAddressCode(identifiers, numberOfGotos)
+		if err != nil {
+			return nil, []ir.Instruction{}, identifiers, err
+		}
+		threeAddressCodes = append(threeAddressCodes, t...)
+		threeAddressCodes = append(
+			threeAddressCodes,
+			ir.IndexStore{Array: label, Index: ir.Int(index), Value: result},
+		)
+		identifiers = ids
+	}
+	return label, threeAddressCodes, identifiers, nil
+}
+
+func (a ArrayExpression) flattenElements(
+	identifiers []IdentifierInformation,
 ) ([]ExpressionAST, error) {
 	if len(a.Elements) == 0 {
 		return []ExpressionAST{}, nil
@@ -581,42 +1811,91 @@ func (a ArrayExpression) unwrap(elements []ExpressionAST) ([]ExpressionAST, erro
 type Identifier struct {
 	Id          int
 	ArrayValues []ExpressionAST
+	Span        Span
 }
 
 func (i Identifier) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
 	// e.g., let v = {{1, 2}, {3, 4}, {5, 6}};
 	// v[1] has {1} as ArrayValues and array{int, 2} as Datatype
 	if i.Id < 0 || i.Id >= len(identifiers) {
-		return nil, errors.New("identifer out-of-bounds")
+		return nil, errorAt(i.Span, CodeInternal, "identifer out-of-bounds")
 	}
 	baseDatatype := identifiers[i.Id].Datatype
+	if baseDatatype == nil || baseDatatype.IsDatatype(TypedUnknown) {
+		return nil, errorAt(
+			i.Span, CodeUnassignedIdentifier, "identifier used before being assigned a value",
+		)
+	}
 
 	if len(i.ArrayValues) == 0 {
 		return baseDatatype, nil
 	}
 
-	arrayDatatype, ok := baseDatatype.(ArrayDatatype)
-	if !ok {
-		return nil, errors.New("array accesses on a non-array datatype")
-	}
-	for range i.ArrayValues {
-		if arrayDatatype, ok = baseDatatype.(ArrayDatatype); !ok {
-			return nil, errors.New("array accesses greater than number of nested arrays")
+	for index, access := range i.ArrayValues {
+		if err := checkIndex(access, identifiers); err != nil {
+			return nil, err
+		}
+		if _, ok := baseDatatype.(StringDatatype); ok && index == len(i.ArrayValues)-1 {
+			// s[i] is a character of the string
+			return TypedChar, nil
+		}
+		arrayDatatype, ok := baseDatatype.(ArrayDatatype)
+		if !ok && index == 0 {
+			return nil, errorAt(i.Span, CodeInvalidArrayAccess, "array accesses on a non-array datatype")
+		}
+		if !ok {
+			return nil, errorAt(
+				i.Span, CodeInvalidArrayAccess, "array accesses greater than number of nested arrays",
+			)
 		}
 		baseDatatype = arrayDatatype.ElementType
 	}
 	return baseDatatype, nil
 }
 
+// an index must be an integer, such as an int, a char or a u8, e.g., not 1.5
+func checkIndex(access ExpressionAST, identifiers []IdentifierInformation) error {
+	datatype, err := access.GetDatatype(identifiers)
+	if err != nil {
+		return err
+	}
+	if primitive, ok := datatype.(PrimitiveDatatype); ok {
+		if _, _, integer := primitive.integerRange(); integer {
+			return nil
+		}
+	}
+	return errorAt(access.GetSpan(), CodeInvalidArrayAccess, fmt.Sprintf(
+		"an index should be an integer, not %v", DatatypeName(datatype),
+	))
+}
+
+// whether the last access is into a string, e.g., s[0] or names[1][0]
+func (i Identifier) indexesString(identifiers []IdentifierInformation) bool {
+	if len(i.ArrayValues) == 0 {
+		return false
+	}
+	prefix := Identifier{Id: i.Id, ArrayValues: i.ArrayValues[:len(i.ArrayValues)-1], Span: i.Span}
+	datatype, err := prefix.GetDatatype(identifiers)
+	return err == nil && datatype.IsDatatype(StringDatatype{})
+}
+
+func (i Identifier) GetSpan() Span {
+	return i.Span
+}
+
 func (i Identifier) ThreeAddressCode(
 	identifiers []IdentifierInformation,
-) (string, []string, []IdentifierInformation, error) {
-	codes := []string{}
-	label := identifierFromIndex(i.Id)
-	offset := "0"
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	codes := []ir.Instruction{}
+	label := ir.Identifier(i.Id)
+	var offset ir.Operand = ir.Int(0)
 
 	if len(i.ArrayValues) == 0 {
-		return label, []string{}, identifiers, nil
+		return label, []ir.Instruction{}, identifiers, nil
+	}
+	if i.indexesString(identifiers) {
+		return i.characterThreeAddressCode(identifiers, numberOfGotos)
 	}
 
 	datatype := identifiers[i.Id].Datatype
@@ -624,50 +1903,269 @@ func (i Identifier) ThreeAddressCode(
 
 	for _, access := range i.ArrayValues {
 		if !arrayOk {
-			return "", []string{}, identifiers, errors.New("mismatching types")
+			return nil, []ir.Instruction{}, identifiers, errors.New("mismatching types")
 		}
-		result, threeAddressCode, identifiersCopy, err := access.ThreeAddressCode(identifiers)
+		result, threeAddressCode, identifiersCopy, err := access.ThreeAddressCode(identifiers, numberOfGotos)
 		if err != nil {
-			return "", []string{}, identifiers, err
+			return nil, []ir.Instruction{}, identifiers, err
 		}
 		codes = append(
 			codes,
 			threeAddressCode...,
 		)
 		next, identifiersCopy := nextIdentifier(identifiersCopy, TypedInt)
-		codes = append(codes, fmt.Sprintf(
-			"%v = %v * %v",
-			next,
-			offset,
-			arrayDatatype.NumberOfElements,
-		))
-
-		offset, identifiers = nextIdentifier(identifiersCopy, TypedInt)
-		codes = append(codes, fmt.Sprintf("%v = %v + %v", offset, next, result))
+		codes = append(codes, ir.BinOp{
+			Destination: next,
+			Operator:    ir.Multiply,
+			First:       offset,
+			Second:      ir.Int(arrayDatatype.NumberOfElements),
+		})
+
+		sum, identifiersCopy := nextIdentifier(identifiersCopy, TypedInt)
+		codes = append(codes, ir.BinOp{
+			Destination: sum,
+			Operator:    ir.Add,
+			First:       next,
+			Second:      result,
+		})
+		offset, identifiers = sum, identifiersCopy
 
 		datatype = arrayDatatype.ElementType
 		arrayDatatype, arrayOk = datatype.(ArrayDatatype)
 	}
 
+	if arrayOk {
+		// a partial access, e.g., v[1] of the above, starts after every element before it
+		_, length, err := datatype.ToString()
+		if err != nil {
+			return nil, []ir.Instruction{}, identifiers, err
+		}
+		next, identifiersCopy := nextIdentifier(identifiers, TypedInt)
+		codes = append(codes, ir.BinOp{
+			Destination: next,
+			Operator:    ir.Multiply,
+			First:       offset,
+			Second:      ir.Int(length),
+		})
+		offset, identifiers = next, identifiersCopy
+	}
+
 	result, identifiers := nextIdentifier(identifiers, datatype)
-	codes = append(codes, fmt.Sprintf("%v = %v [] %v", result, label, offset))
+	codes = append(codes, ir.IndexLoad{Destination: result, Array: label, Index: offset})
+
+	return result, codes, identifiers, nil
+}
+
+// loads a character of a string, which is indexed directly rather than through an offset
+func (i Identifier) characterThreeAddressCode(
+	identifiers []IdentifierInformation,
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	prefix := Identifier{Id: i.Id, ArrayValues: i.ArrayValues[:len(i.ArrayValues)-1], Span: i.Span}
+	stringResult, codes, identifiers, err := prefix.ThreeAddressCode(identifiers, numberOfGotos)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+	stringIdentifier, ok := stringResult.(ir.Identifier)
+	if !ok {
+		return nil, []ir.Instruction{}, identifiers, errors.New("string to index is not an identifier")
+	}
+	index, indexCodes, identifiers, err := i.ArrayValues[len(i.ArrayValues)-1].ThreeAddressCode(
+		identifiers, numberOfGotos,
+	)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, err
+	}
+	codes = append(codes, indexCodes...)
 
+	result, identifiers := nextIdentifier(identifiers, TypedChar)
+	codes = append(codes, ir.IndexLoad{Destination: result, Array: stringIdentifier, Index: index})
 	return result, codes, identifiers, nil
 }
 
 type Literal struct {
 	Value    string
 	Datatype Datatype
+	Span     Span
 }
 
 func (l Literal) GetDatatype(identifiers []IdentifierInformation) (Datatype, error) {
-	return l.Datatype, nil
+	return l.checkRange(false)
+}
+
+// a literal must fit in its datatype, e.g., 255u8, or 9223372036854775807 for an int
+func (l Literal) checkRange(negative bool) (Datatype, error) {
+	primitive, ok := l.Datatype.(PrimitiveDatatype)
+	if !ok || !isSizedNumber(primitive) && primitive != TypedInt ||
+		LiteralFits(l.Value, negative, primitive) {
+		return l.Datatype, nil
+	}
+	return nil, literalOutOfRange(l.Value, negative, primitive, l.Span)
+}
+
+func (l Literal) GetSpan() Span {
+	return l.Span
 }
 
 func (l Literal) ThreeAddressCode(
 	identifiers []IdentifierInformation,
-) (string, []string, []IdentifierInformation, error) {
-	return l.Value, []string{}, identifiers, nil
+	numberOfGotos *int,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	literal := ir.Literal{Value: l.Value}
+	if primitive, ok := l.Datatype.(PrimitiveDatatype); ok && primitive.IsSized() {
+		return l.sizedCode(primitive, identifiers)
+	}
+	if l.Datatype == TypedInt && !LiteralFits(l.Value, false, TypedInt) {
+		// only a u64 takes an int literal this large
+		return l.sizedCode(TypedU64, identifiers)
+	}
+	switch l.Datatype {
+	case TypedInt:
+		literal.Kind = ir.LiteralInt
+	case TypedFloat:
+		literal.Kind = ir.LiteralFloat
+	case TypedF32:
+		// written as the value it has as an f32, so that C does not work it out with more precision
+		value, err := strconv.ParseFloat(l.Value, 32)
+		if err != nil {
+			return nil, []ir.Instruction{}, identifiers, errors.New("f32 literal that is not a number")
+		}
+		literal.Kind = ir.LiteralFloat
+		literal.Value = strconv.FormatFloat(value, 'g', -1, 64)
+		if !strings.ContainsAny(literal.Value, ".e") {
+			literal.Value += ".0"
+		}
+	case TypedChar:
+		literal.Kind = ir.LiteralChar
+	case TypedBool:
+		literal.Kind = ir.LiteralBool
+	default:
+		if _, ok := l.Datatype.(StringDatatype); !ok {
+			return nil, []ir.Instruction{}, identifiers, errors.New("literal of an unknown datatype")
+		}
+		literal.Kind = ir.LiteralString
+	}
+	return literal, []ir.Instruction{}, identifiers, nil
+}
+
+// The literal of a sized integer is an int literal, as long as it fits in one.
+// Otherwise it is written by its bits, e.g., 18446744073709551615u64 is -1 put into a u64.
+func (l Literal) sizedCode(
+	datatype PrimitiveDatatype,
+	identifiers []IdentifierInformation,
+) (ir.Operand, []ir.Instruction, []IdentifierInformation, error) {
+	value, err := strconv.ParseUint(l.Value, 10, 64)
+	if err != nil {
+		return nil, []ir.Instruction{}, identifiers, errors.New("sized literal that is not an integer")
+	}
+	if value <= math.MaxInt64 {
+		return ir.Literal{Kind: ir.LiteralInt, Value: l.Value}, []ir.Instruction{}, identifiers, nil
+	}
+	label, identifiers := nextIdentifier(identifiers, datatype)
+	if value == 1<<63 {
+		// C has no literal for the smallest integer, only its negation
+		return label, []ir.Instruction{ir.BinOp{
+			Destination: label,
+			Operator:    ir.Subtract,
+			First:       ir.Literal{Kind: ir.LiteralInt, Value: strconv.FormatInt(math.MinInt64+1, 10)},
+			Second:      ir.Int(1),
+		}}, identifiers, nil
+	}
+	bits := ir.Literal{Kind: ir.LiteralInt, Value: strconv.FormatInt(int64(value), 10)}
+	return label, []ir.Instruction{ir.Assign{Destination: label, Source: bits}}, identifiers, nil
+}
+
+// The datatype of a value given where one of the expected datatype is needed.
+// An int literal too large for an int is not an error if it fits in the expected datatype,
+// e.g., 18446744073709551615 for a u64.
+func datatypeFor(
+	value ExpressionAST,
+	expected Datatype,
+	identifiers []IdentifierInformation,
+) (Datatype, error) {
+	datatype, err := value.GetDatatype(identifiers)
+	if err == nil {
+		return datatype, nil
+	}
+	adapted, adaptErr := literalAdapts(value, expected)
+	if adaptErr != nil {
+		return nil, adaptErr
+	}
+	if !adapted {
+		return nil, err
+	}
+	return expected, nil
+}
+
+// An int literal, such as 1 or -1, can be used where a sized number is expected,
+// and a float literal where an f32 is, e.g., let x: u8 = 255; as long as the value fits.
+func literalAdapts(value ExpressionAST, expected Datatype) (bool, error) {
+	primitive, ok := expected.(PrimitiveDatatype)
+	if !ok || !isSizedNumber(primitive) {
+		return false, nil
+	}
+	literal, ok := value.(Literal)
+	negative := false
+	if unary, isUnary := value.(UnaryExpression); isUnary && unary.Operator == UnaryMinus {
+		literal, ok = unary.Operand.(Literal)
+		negative = true
+	}
+	if !ok {
+		return false, nil
+	}
+	if !literal.Datatype.IsDatatype(TypedInt) &&
+		!(literal.Datatype.IsDatatype(TypedFloat) && primitive == TypedF32) {
+		return false, nil
+	}
+	if !LiteralFits(literal.Value, negative, primitive) {
+		return false, literalOutOfRange(literal.Value, negative, primitive, value.GetSpan())
+	}
+	return true, nil
+}
+
+func literalOutOfRange(value string, negative bool, datatype PrimitiveDatatype, span Span) error {
+	if negative {
+		value = "-" + value
+	}
+	return errorAt(span, CodeLiteralOutOfRange, fmt.Sprintf(
+		"%v does not fit in %v", value, DatatypeName(datatype),
+	))
+}
+
+func errorAt(span Span, code string, message string) *SpanError {
+	return &SpanError{Span: span, Code: code, Message: message}
+}
+
+// adds a note pointing at where the identifier was declared, and with which datatype
+func withDeclaration(err *SpanError, information IdentifierInformation) *SpanError {
+	if information.Declaration.IsZero() {
+		return err
+	}
+	message := fmt.Sprintf("%v declared here", information.IdentifierName)
+	if information.Datatype != nil && !information.Datatype.IsDatatype(TypedUnknown) {
+		message += " as " + DatatypeName(information.Datatype)
+	}
+	err.Notes = append(err.Notes, Note{Message: message, Span: information.Declaration})
+	return err
+}
+
+// gives an error the span of the code it came from, unless it already has one
+func withSpan(span Span, err error) error {
+	if err == nil {
+		return nil
+	}
+	var spanError *SpanError
+	var internal *InternalError
+	if errors.As(err, &spanError) || errors.As(err, &internal) {
+		return err
+	}
+	spanned := &SpanError{Span: span, Message: err.Error()}
+	var compilationError *CompilationError
+	if errors.As(err, &compilationError) {
+		spanned.Code = compilationError.Code
+		spanned.Message = compilationError.Message
+	}
+	return spanned
 }
 
 func getNextGoto(numberOfGotos *int) string {
@@ -677,16 +2175,13 @@ func getNextGoto(numberOfGotos *int) string {
 
 func nextIdentifier(
 	identifiers []IdentifierInformation, datatype Datatype,
-) (string, []IdentifierInformation) {
-	name := identifierFromIndex(len(identifiers))
+) (ir.Identifier, []IdentifierInformation) {
+	identifier := ir.Identifier(len(identifiers))
 	identifiers = append(identifiers, IdentifierInformation{
-		IdentifierName: name,
+		// since name is the same as identifier code
+		IdentifierName: identifier.String(),
 		Datatype:       datatype,
+		Temporary:      true,
 	})
-	// since name is the same as identifier code
-	return name, identifiers
-}
-
-func identifierFromIndex(i int) string {
-	return fmt.Sprintf("_t%d", i)
+	return identifier, identifiers
 }
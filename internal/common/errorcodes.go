@@ -0,0 +1,44 @@
+package common
+
+// Every error reported to the user has a code, so that it can be looked up and filtered.
+// E00xx are from the compiler itself, E01xx from the lexer and the parser,
+// E02xx from the semantic analyzer, and E03xx from the type checker.
+const (
+	CodeInternal          = "E0001"
+	CodeUnderConstruction = "E0002"
+
+	CodeInvalidToken        = "E0101"
+	CodeUnexpectedToken     = "E0102"
+	CodeUnexpectedEndOfFile = "E0103"
+
+	CodeUndeclaredIdentifier      = "E0201"
+	CodeIdentifierOutOfScope      = "E0202"
+	CodeIdentifierOutsideFunction = "E0203"
+	CodeAlreadyDeclared           = "E0204"
+	CodeImmutableAssignment       = "E0205"
+	CodeFunctionAsValue           = "E0206"
+	CodeNotAFunction              = "E0207"
+	CodeNestedFunction            = "E0208"
+	CodeDuplicateParameter        = "E0209"
+	CodeReturnOutsideFunction     = "E0210"
+	CodeJumpOutsideLoop           = "E0211"
+	CodeUndeclaredLabel           = "E0212"
+	CodeInvalidArrayLength        = "E0213"
+
+	CodeMismatchedTypes      = "E0301"
+	CodeInvalidOperand       = "E0302"
+	CodeNonBooleanCondition  = "E0303"
+	CodeVoidValue            = "E0304"
+	CodeArgumentCount        = "E0305"
+	CodeMissingReturn        = "E0306"
+	CodeInvalidArrayAccess   = "E0307"
+	CodeUnassignedIdentifier = "E0308"
+	CodeInvalidOutput        = "E0309"
+	CodeInvalidReturn        = "E0310"
+	CodeInvalidFormat        = "E0311"
+	CodeFormatArgumentCount  = "E0312"
+	CodeFormatMismatch       = "E0313"
+	CodeInvalidLoop          = "E0314"
+	CodeInvalidCast          = "E0315"
+	CodeLiteralOutOfRange    = "E0316"
+)
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// a % directive in a printf format string, such as %5.2f
+type formatConversion struct {
+	// the whole directive, e.g., %08.3f
+	directive string
+	// the length modifier, e.g., ll
+	length     string
+	conversion byte
+	// the byte offset of the % in the format string
+	offset int
+}
+
+// Splits a format string into its conversions, leaving out %%.
+// The flags, width, precision and length modifiers of C are accepted.
+func parseFormat(format string) ([]formatConversion, error) {
+	conversions := []formatConversion{}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+
+		end := i + 1
+		for end < len(format) && strings.IndexByte("-+ #0", format[end]) >= 0 {
+			end++
+		}
+		for end < len(format) && format[end] >= '0' && format[end] <= '9' {
+			end++
+		}
+		if end < len(format) && format[end] == '.' {
+			end++
+			for end < len(format) && format[end] >= '0' && format[end] <= '9' {
+				end++
+			}
+		}
+		lengthStart := end
+		for end < len(format) && strings.IndexByte("hlLjzt", format[end]) >= 0 {
+			end++
+		}
+		if end >= len(format) {
+			return nil, fmt.Errorf("the conversion %v is not complete", format[i:])
+		}
+
+		conversion := formatConversion{
+			directive:  format[i : end+1],
+			length:     format[lengthStart:end],
+			conversion: format[end],
+			offset:     i,
+		}
+		i = end
+		if conversion.conversion == '%' {
+			continue
+		}
+		conversions = append(conversions, conversion)
+	}
+	return conversions, nil
+}
+
+// Whether the conversion prints a value of the datatype, matching the C types the datatypes are compiled to.
+// int is a long long, float a double, and char and bool are promoted to int.
+// The sized integers are passed as a long long, and an f32 is promoted to a double.
+func (c formatConversion) accepts(datatype Datatype) bool {
+	switch c.conversion {
+	case 'd', 'i', 'u', 'x', 'X', 'o':
+		switch c.length {
+		case "ll":
+			primitive, ok := datatype.(PrimitiveDatatype)
+			return ok && (primitive == TypedInt || primitive.IsSized())
+		case "":
+			return datatype.IsDatatype(TypedBool) || datatype.IsDatatype(TypedChar)
+		case "hh":
+			return datatype.IsDatatype(TypedChar)
+		}
+
+	case 'c':
+		return c.length == "" && datatype.IsDatatype(TypedChar)
+
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		return (c.length == "" || c.length == "l") &&
+			(datatype.IsDatatype(TypedFloat) || datatype.IsDatatype(TypedF32))
+
+	case 's':
+		return c.length == "" && datatype.IsDatatype(StringDatatype{})
+	}
+	return false
+}
+
+// the conversions that print a value of the datatype, for suggesting one
+func conversionsFor(datatype Datatype) string {
+	primitive, _ := datatype.(PrimitiveDatatype)
+	switch {
+	case primitive.IsUnsigned():
+		return "%llu"
+	case datatype.IsDatatype(TypedInt), primitive.IsSized():
+		return "%lld"
+	case datatype.IsDatatype(TypedFloat), datatype.IsDatatype(TypedF32):
+		return "%f or %lf"
+	case datatype.IsDatatype(TypedChar):
+		return "%c"
+	case datatype.IsDatatype(TypedBool):
+		return "%d"
+	case datatype.IsDatatype(StringDatatype{}):
+		return "%s"
+	}
+	return ""
+}
+
+func (c formatConversion) isSupported() bool {
+	return strings.IndexByte("diuxXocfFeEgGs", c.conversion) >= 0
+}
+
+// the span of the conversion, inside the span of the string literal that has it
+func (c formatConversion) spanIn(literal Span) Span {
+	if literal.IsZero() || literal.StartLine != literal.EndLine {
+		return literal
+	}
+	// + 1 for the opening "
+	start := literal.StartCol + 1 + c.offset
+	return Span{
+		File:      literal.File,
+		StartLine: literal.StartLine,
+		StartCol:  start,
+		EndLine:   literal.StartLine,
+		EndCol:    start + len(c.directive) - 1,
+	}
+}
@@ -17,3 +17,12 @@ func (n ParseTreeNode) Display(start, increase string) {
 		t.Display(start+increase, increase)
 	}
 }
+
+// the span of every token under the node, blocks having none of their own
+func (n ParseTreeNode) Span() Span {
+	span := n.InnerToken.Span
+	for _, child := range n.ChildNodes {
+		span = span.Join(child.Span())
+	}
+	return span
+}
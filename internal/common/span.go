@@ -0,0 +1,56 @@
+package common
+
+import "fmt"
+
+// A range of the source code, from its first character to its last, both included.
+// Lines and columns start at 1, and columns count bytes.
+type Span struct {
+	File      string
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+}
+
+// the zero Span is used where the position is not known
+func (s Span) IsZero() bool {
+	return s.StartLine == 0
+}
+
+// the smallest span covering both spans
+func (s Span) Join(other Span) Span {
+	if s.IsZero() {
+		return other
+	}
+	if other.IsZero() {
+		return s
+	}
+	result := s
+	if other.StartLine < s.StartLine || other.StartLine == s.StartLine && other.StartCol < s.StartCol {
+		result.StartLine, result.StartCol = other.StartLine, other.StartCol
+	}
+	if other.EndLine > s.EndLine || other.EndLine == s.EndLine && other.EndCol > s.EndCol {
+		result.EndLine, result.EndCol = other.EndLine, other.EndCol
+	}
+	return result
+}
+
+// file:line:column of the start of the span
+func (s Span) String() string {
+	if s.File == "" {
+		return fmt.Sprintf("%v:%v", s.StartLine, s.StartCol)
+	}
+	return fmt.Sprintf("%v:%v:%v", s.File, s.StartLine, s.StartCol)
+}
+
+// An error found while checking the code, along with the part of the code it is about.
+type SpanError struct {
+	Span    Span
+	Code    string
+	Message string
+	Notes   []Note
+}
+
+func (e *SpanError) Error() string {
+	return e.Message
+}
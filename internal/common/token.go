@@ -1,9 +1,9 @@
 package common
 
 type Token struct {
-	LineNumber int
-	TokenKind  TokenKind
-	Token      string
+	TokenKind TokenKind
+	Token     string
+	Span      Span
 }
 
 type TokenKind int
@@ -74,6 +74,17 @@ const (
 	TokenLet
 	TokenMutable
 
+	// fn, used to declare a function
+	TokenFunction
+	// return from the current function
+	TokenReturn
+	// -> showing the return type of a function
+	TokenArrow
+	// : separating a parameter from its type
+	TokenColon
+	// name of a datatype, such as int or float
+	TokenDatatype
+
 	TokenOpenCurly
 	TokenCloseCurly
 
@@ -95,10 +106,31 @@ const (
 	// Token showing the end of the file
 	TokenEOF
 
+	// // and the rest of the line, kept for the formatter and skipped by the parser
+	TokenComment
+
+	// len, the length of a string or an array
+	TokenLength
+
+	// for loop, over a range or the elements of an array
+	TokenFor
+	// in, between the loop variable and what it goes over
+	TokenIn
+	// .. or ..=, the range of a for loop
+	TokenRange
+	// step, how much the loop variable of a range changes by
+	TokenStep
+	// break out of a loop
+	TokenBreak
+	// continue with the next iteration of a loop
+	TokenContinue
+	// as, converting a value to another primitive type
+	TokenAs
+
 	// used by the lexer
 
 	// return nothing in the Lexer
-	// if, for example, the rest of the line is a comment
+	// if, for example, the rest of the line is whitespace
 	TokenEmpty
 	// in case something goes wrong
 	// e.g. $ outside quotes
@@ -148,6 +180,12 @@ var NameMapWithTokenKind = map[TokenKind]string{
 	TokenLet:     "let",
 	TokenMutable: "mut",
 
+	TokenFunction: "fn",
+	TokenReturn:   "return",
+	TokenArrow:    "Arrow ->",
+	TokenColon:    "Colon :",
+	TokenDatatype: "Datatype",
+
 	TokenOpenCurly:  "Open Curly Braces",
 	TokenCloseCurly: "Close Curly Braces",
 
@@ -163,6 +201,20 @@ var NameMapWithTokenKind = map[TokenKind]string{
 
 	TokenEOF: "End of File",
 
+	TokenComment: "Comment",
+
+	TokenLength: "len",
+
+	TokenFor:   "for",
+	TokenIn:    "in",
+	TokenRange: "Range ..",
+	TokenStep:  "step",
+
+	TokenBreak:    "break",
+	TokenContinue: "continue",
+
+	TokenAs: "as",
+
 	TokenEmpty: "Empty Token",
 	TokenError: "Error Token",
 
@@ -172,7 +224,17 @@ var NameMapWithTokenKind = map[TokenKind]string{
 type IdentifierInformation struct {
 	IdentifierName string
 	Datatype       Datatype
-	Mutable        bool
+	// the datatype written in the declaration, e.g., float in let x: float = 1; nil if there is none
+	Annotation Datatype
+	Mutable    bool
+	// made by the compiler to hold the result of a subexpression
+	Temporary bool
+	// where the identifier was declared, zero for temporaries
+	Declaration Span
+	// the block the identifier is visible in, zero for the whole program
+	Scope Span
+	// everywhere the identifier is used after its declaration
+	Uses []Span
 }
 
 type UnderConstructionError struct {
@@ -207,8 +269,22 @@ func (e *InternalError) Error() string {
 type CompilationError struct {
 	PointOfFailure string
 	Message        string
+	// where in the source the mistake is, if known
+	Span Span
+	// one of the codes in errorcodes.go
+	Code string
+	// other parts of the source that help explain the mistake
+	Notes []Note
+}
+
+type Note struct {
+	Message string
+	Span    Span
 }
 
 func (e *CompilationError) Error() string {
-	return e.PointOfFailure + ": " + e.Message
+	if e.Span.IsZero() {
+		return e.PointOfFailure + ": " + e.Message
+	}
+	return e.Span.String() + ": " + e.PointOfFailure + ": " + e.Message
 }
@@ -1,5 +1,11 @@
 package common
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // so that more datatypes may be added without worry
 
 type Datatype interface {
@@ -36,7 +42,7 @@ func (v VoidDatatype) ToRepresentation() string {
 	return "void"
 }
 
-// the basic datatypes: int, bool, char, float, and unknown
+// the basic datatypes: int, bool, char, float, the sized numbers, and unknown
 type PrimitiveDatatype int
 
 const (
@@ -47,8 +53,81 @@ const (
 	TypedBool
 	TypedChar
 	TypedFloat
+
+	// the sized integers, which wrap around at their size
+	TypedI8
+	TypedI16
+	TypedI32
+	TypedI64
+	TypedU8
+	TypedU16
+	TypedU32
+	TypedU64
+	// a float of 32 bits, where float has 64
+	TypedF32
 )
 
+// the number of bits of a sized integer, 0 for every other datatype
+func (p PrimitiveDatatype) Bits() int {
+	switch p {
+	case TypedI8, TypedU8:
+		return 8
+	case TypedI16, TypedU16:
+		return 16
+	case TypedI32, TypedU32:
+		return 32
+	case TypedI64, TypedU64:
+		return 64
+	}
+	return 0
+}
+
+// whether the datatype is one of i8 to i64 or u8 to u64
+func (p PrimitiveDatatype) IsSized() bool {
+	return p.Bits() > 0
+}
+
+// whether the datatype is one of u8 to u64
+func (p PrimitiveDatatype) IsUnsigned() bool {
+	return p >= TypedU8 && p <= TypedU64
+}
+
+func (p PrimitiveDatatype) isFloat() bool {
+	return p == TypedFloat || p == TypedF32
+}
+
+// whether the datatype takes part in arithmetic
+func (p PrimitiveDatatype) isNumber() bool {
+	return p == TypedInt || p == TypedChar || p.isFloat() || p.IsSized()
+}
+
+// the bits and the signedness of the values of an integer datatype, which includes int and char
+func (p PrimitiveDatatype) integerRange() (int, bool, bool) {
+	switch p {
+	case TypedInt:
+		return 64, false, true
+	case TypedChar:
+		return 8, false, true
+	}
+	return p.Bits(), p.IsUnsigned(), p.IsSized()
+}
+
+// whether every value of one integer datatype is a value of the other, e.g., u8 of i16
+func fitsIn(from PrimitiveDatatype, to PrimitiveDatatype) bool {
+	fromBits, fromUnsigned, fromOk := from.integerRange()
+	toBits, toUnsigned, toOk := to.integerRange()
+	switch {
+	case !fromOk || !toOk:
+		return false
+	case fromUnsigned == toUnsigned:
+		return fromBits <= toBits
+	case fromUnsigned:
+		// the sign takes up a bit
+		return fromBits < toBits
+	}
+	return false
+}
+
 func (p PrimitiveDatatype) IsDatatype(datatype Datatype) bool {
 	// this check is to prevent another enum type in the future from matching
 	primitiveDatatype, ok := datatype.(PrimitiveDatatype)
@@ -59,11 +138,11 @@ func (p PrimitiveDatatype) IsDatatype(datatype Datatype) bool {
 func (p PrimitiveDatatype) PerformUnaryOperation(operator UnaryOperatorNode) (Datatype, error) {
 	switch operator {
 	case UnaryMinus:
-		switch p {
-		case TypedInt:
-			return TypedInt, nil
-		case TypedFloat:
-			return TypedFloat, nil
+		switch {
+		case p == TypedInt, p.isFloat(), p.IsSized() && !p.IsUnsigned():
+			return p, nil
+		case p.IsUnsigned():
+			return nil, compilationError("an unsigned integer cannot be negated")
 		default:
 			return nil, compilationError("unknown type in unary minus")
 		}
@@ -95,6 +174,9 @@ func (p PrimitiveDatatype) PerformBinaryOperation(
 	case StringDatatype:
 		return nil, compilationError("string cannot be an operand with a non-string")
 
+	case FunctionDatatype:
+		return nil, compilationError("functions cannot be an operand")
+
 	default:
 		return nil, internalError("unknown operand datatype")
 	}
@@ -118,9 +200,18 @@ func (p PrimitiveDatatype) ToString() (string, int, error) {
 	case TypedFloat:
 		return "double", 1, nil
 
-	default:
-		return "", 0, internalError("unknown type")
+	case TypedF32:
+		return "float", 1, nil
+	}
+	if p.IsSized() {
+		// from <stdint.h>, e.g., uint8_t for u8
+		name := fmt.Sprintf("int%v_t", p.Bits())
+		if p.IsUnsigned() {
+			name = "u" + name
+		}
+		return name, 1, nil
 	}
+	return "", 0, internalError("unknown type")
 }
 
 func (p PrimitiveDatatype) ToRepresentation() string {
@@ -141,7 +232,8 @@ func (p PrimitiveDatatype) ToRepresentation() string {
 		return "d"
 
 	default:
-		return ""
+		// the sized numbers are named the same as in the source
+		return DatatypeName(p)
 	}
 }
 
@@ -158,19 +250,7 @@ func operationOnPrimitives(
 	case BinaryDiv:
 		fallthrough
 	case BinaryModulo:
-		if firstPrimitive != TypedInt &&
-			firstPrimitive != TypedChar &&
-			firstPrimitive != TypedFloat {
-			return nil, compilationError("unexpected type in mathematical expression")
-		} else if secondPrimitive != TypedInt &&
-			secondPrimitive != TypedChar &&
-			secondPrimitive != TypedFloat {
-			return nil, compilationError("unexpected type in mathematical expression")
-		}
-		if firstPrimitive == TypedFloat || secondPrimitive == TypedFloat {
-			return TypedFloat, nil
-		}
-		return TypedInt, nil
+		return Promote(firstPrimitive, secondPrimitive)
 
 	case BinaryRelationalEquals:
 		fallthrough
@@ -183,6 +263,12 @@ func operationOnPrimitives(
 	case BinaryRelationalLesserThan:
 		fallthrough
 	case BinaryRelationalLesserThanOrEquals:
+		// the sized numbers are compared once they are of the same datatype
+		if isSizedNumber(firstPrimitive) || isSizedNumber(secondPrimitive) {
+			if _, err := Promote(firstPrimitive, secondPrimitive); err != nil {
+				return nil, err
+			}
+		}
 		return TypedBool, nil
 
 	case BinaryAnd:
@@ -198,6 +284,69 @@ func operationOnPrimitives(
 	}
 }
 
+// The datatype the operands of an arithmetic operation or a comparison are converted to.
+// An int and a char are worked out as a float when the other operand is one, and otherwise as an int.
+// An int takes on the datatype of a sized number it meets, e.g., x + 1 is a u8 for a u8 x,
+// which the type checker only allows for literals, and an f32 becomes a float when it meets one.
+// Otherwise the operands become the wider of them, as long as it holds every value of both,
+// so i32 and u32 cannot be mixed without converting one of them with as.
+func Promote(first PrimitiveDatatype, second PrimitiveDatatype) (PrimitiveDatatype, error) {
+	if !first.isNumber() || !second.isNumber() {
+		return TypedUnknown, compilationError("unexpected type in mathematical expression")
+	}
+	switch {
+	case first == TypedFloat || second == TypedFloat:
+		return TypedFloat, nil
+	case first == TypedF32 || second == TypedF32:
+		return TypedF32, nil
+	case !first.IsSized() && !second.IsSized():
+		return TypedInt, nil
+	case first == TypedInt:
+		return second, nil
+	case second == TypedInt:
+		return first, nil
+	case fitsIn(first, second):
+		return second, nil
+	case fitsIn(second, first):
+		return first, nil
+	}
+	return TypedUnknown, &CompilationError{
+		PointOfFailure: "types",
+		Message: fmt.Sprintf(
+			"%v and %v cannot be mixed, convert one of them with as",
+			DatatypeName(first), DatatypeName(second),
+		),
+		Code: CodeMismatchedTypes,
+	}
+}
+
+func isSizedNumber(datatype PrimitiveDatatype) bool {
+	return datatype.IsSized() || datatype == TypedF32
+}
+
+// Whether the literal fits in the datatype, given its digits without the sign.
+// An int literal may be given to any of the numbers, and a float literal to a float or an f32.
+func LiteralFits(value string, negative bool, datatype PrimitiveDatatype) bool {
+	if datatype == TypedF32 {
+		// as long as it does not round to infinity
+		_, err := strconv.ParseFloat(value, 32)
+		return err == nil
+	}
+	bits, unsigned, ok := datatype.integerRange()
+	if !ok {
+		return false
+	}
+	magnitude, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return false
+	}
+	if unsigned {
+		return (!negative || magnitude == 0) && (bits == 64 || magnitude < 1<<bits)
+	}
+	limit := uint64(1) << (bits - 1)
+	return magnitude < limit || negative && magnitude == limit
+}
+
 type ArrayDatatype struct {
 	ElementType      Datatype
 	NumberOfElements int
@@ -258,6 +407,9 @@ func (s StringDatatype) PerformBinaryOperation(
 	if !ok {
 		return nil, compilationError("unsupported operation of string with another type")
 	}
+	if operator == BinaryRelationalEquals || operator == BinaryRelationalNotEquals {
+		return TypedBool, nil
+	}
 	if operator != BinaryPlus {
 		return nil, compilationError("unsupported operator with string")
 	}
@@ -284,10 +436,149 @@ func (s StringDatatype) ToRepresentation() string {
 	return "str"
 }
 
+// the type of a declared function
+// a function is never stored in a variable, it can only be called
+type FunctionDatatype struct {
+	ParameterTypes []Datatype
+	ReturnType     Datatype
+}
+
+func (f FunctionDatatype) IsDatatype(datatype Datatype) bool {
+	functionDatatype, ok := datatype.(FunctionDatatype)
+	if !ok || len(f.ParameterTypes) != len(functionDatatype.ParameterTypes) {
+		return false
+	}
+	for index, parameterType := range f.ParameterTypes {
+		if !parameterType.IsDatatype(functionDatatype.ParameterTypes[index]) {
+			return false
+		}
+	}
+	return f.ReturnType.IsDatatype(functionDatatype.ReturnType)
+}
+
+func (f FunctionDatatype) PerformUnaryOperation(operator UnaryOperatorNode) (Datatype, error) {
+	return nil, compilationError("functions cannot be an operand")
+}
+
+func (f FunctionDatatype) PerformBinaryOperation(
+	operator BinaryOperatorNode, with Datatype,
+) (Datatype, error) {
+	return nil, compilationError("functions cannot be an operand")
+}
+
+func (f FunctionDatatype) ToString() (string, int, error) {
+	return "", 0, internalError("functions cannot be assigned as a datatype")
+}
+
+func (f FunctionDatatype) ToRepresentation() string {
+	return "fn"
+}
+
+// Whether a value of the datatype can be the first value of a variable declared as the annotation.
+// An int is turned into a float, as in C, and otherwise the datatypes must be the same.
+func initializes(datatype Datatype, annotation Datatype) bool {
+	if annotation.IsDatatype(TypedFloat) && datatype.IsDatatype(TypedInt) {
+		return true
+	}
+	return annotation.IsDatatype(datatype)
+}
+
+// how a value of one primitive datatype is turned into another with as
+type Conversion int
+
+const (
+	// the value cannot be converted, e.g., an array as int
+	ConversionInvalid Conversion = iota
+	// every value is kept, e.g., char as int
+	ConversionExact
+	// some values are changed, e.g., float as int drops the fraction
+	ConversionLossy
+)
+
+// How a value of the datatype is converted by as, which is only between the numbers and bool.
+// A conversion is exact when every value of one is a value of the other, or when an integer becomes a float,
+// as it does in arithmetic. Otherwise a float is truncated towards 0, an integer keeps its lowest bits,
+// a float becomes the nearest f32, and anything other than 0 becomes true for a bool.
+func ConversionBetween(from Datatype, to Datatype) Conversion {
+	fromPrimitive, ok := from.(PrimitiveDatatype)
+	if !ok || !fromPrimitive.isNumber() && fromPrimitive != TypedBool {
+		return ConversionInvalid
+	}
+	toPrimitive, ok := to.(PrimitiveDatatype)
+	if !ok || !toPrimitive.isNumber() && toPrimitive != TypedBool {
+		return ConversionInvalid
+	}
+	switch {
+	case fromPrimitive == toPrimitive, fromPrimitive == TypedBool:
+		return ConversionExact
+	case toPrimitive == TypedBool:
+		return ConversionLossy
+	case toPrimitive.isFloat():
+		if fromPrimitive == TypedFloat {
+			return ConversionLossy
+		}
+		return ConversionExact
+	case !fromPrimitive.isFloat() && fitsIn(fromPrimitive, toPrimitive):
+		return ConversionExact
+	}
+	return ConversionLossy
+}
+
+// the datatype as it would be written in the source, for error messages
+func DatatypeName(datatype Datatype) string {
+	switch datatype := datatype.(type) {
+	case nil:
+		return "unknown"
+
+	case VoidDatatype:
+		return "void"
+
+	case PrimitiveDatatype:
+		switch datatype {
+		case TypedInt:
+			return "int"
+		case TypedFloat:
+			return "float"
+		case TypedChar:
+			return "char"
+		case TypedBool:
+			return "bool"
+		case TypedF32:
+			return "f32"
+		}
+		if datatype.IsSized() {
+			if datatype.IsUnsigned() {
+				return fmt.Sprintf("u%v", datatype.Bits())
+			}
+			return fmt.Sprintf("i%v", datatype.Bits())
+		}
+		return "unknown"
+
+	case ArrayDatatype:
+		return fmt.Sprintf("[%v; %v]", DatatypeName(datatype.ElementType), datatype.NumberOfElements)
+
+	case StringDatatype:
+		return "string"
+
+	case FunctionDatatype:
+		parameters := []string{}
+		for _, parameter := range datatype.ParameterTypes {
+			parameters = append(parameters, DatatypeName(parameter))
+		}
+		name := "fn(" + strings.Join(parameters, ", ") + ")"
+		if !datatype.ReturnType.IsDatatype(VoidDatatype{}) {
+			name += " -> " + DatatypeName(datatype.ReturnType)
+		}
+		return name
+	}
+	return "unknown"
+}
+
 func compilationError(message string) *CompilationError {
 	return &CompilationError{
 		PointOfFailure: "types",
 		Message:        message,
+		Code:           CodeInvalidOperand,
 	}
 }
 
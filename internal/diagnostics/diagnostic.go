@@ -0,0 +1,107 @@
+package diagnostics
+
+import (
+	"errors"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota + 1
+	SeverityWarning
+	SeverityNote
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityNote:
+		return "note"
+	}
+	return "unknown"
+}
+
+// Anything the compiler has to tell the user about the source.
+type Diagnostic struct {
+	Severity Severity
+	// one of the codes in common/errorcodes.go, empty if there is none
+	Code string
+	// the pass that found it, e.g., Parser
+	Phase   string
+	Message string
+	// zero if the position is not known
+	Span  common.Span
+	Notes []common.Note
+}
+
+// how diagnostics are shown, either a Renderer or a JSONWriter
+type Emitter interface {
+	Render(diagnostic Diagnostic)
+	RenderError(err error)
+}
+
+// one diagnostic for every error joined in the error
+func FromErrors(err error) []Diagnostic {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		diagnostics := []Diagnostic{}
+		for _, inner := range joined.Unwrap() {
+			diagnostics = append(diagnostics, FromErrors(inner)...)
+		}
+		return diagnostics
+	}
+	return []Diagnostic{FromError(err)}
+}
+
+// Turns any error from the compiler into a diagnostic.
+// Errors that are not from the compiler, e.g., a missing file, keep only their message.
+func FromError(err error) Diagnostic {
+	var compilationError *common.CompilationError
+	if errors.As(err, &compilationError) {
+		return Diagnostic{
+			Severity: SeverityError,
+			Code:     compilationError.Code,
+			Phase:    compilationError.PointOfFailure,
+			Message:  compilationError.Message,
+			Span:     compilationError.Span,
+			Notes:    compilationError.Notes,
+		}
+	}
+
+	var internalError *common.InternalError
+	if errors.As(err, &internalError) {
+		return Diagnostic{
+			Severity: SeverityError,
+			Code:     common.CodeInternal,
+			Phase:    internalError.PointOfFailure,
+			Message:  "internal compiler error: " + internalError.Message,
+			Notes: []common.Note{
+				{Message: "this is a mistake in the compiler, not in the program"},
+				{Message: "it is recommended to contact someone in the language creation team regarding this issue"},
+			},
+		}
+	}
+
+	var underConstructionError *common.UnderConstructionError
+	if errors.As(err, &underConstructionError) {
+		message := underConstructionError.PointOfFailure + " is still under construction"
+		if underConstructionError.Message != "" {
+			message += ": " + underConstructionError.Message
+		}
+		return Diagnostic{
+			Severity: SeverityError,
+			Code:     common.CodeUnderConstruction,
+			Phase:    underConstructionError.PointOfFailure,
+			Message:  message,
+		}
+	}
+
+	return Diagnostic{
+		Severity: SeverityError,
+		Message:  err.Error(),
+	}
+}
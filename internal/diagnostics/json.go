@@ -0,0 +1,82 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+)
+
+// Writes every diagnostic as a JSON object on a line of its own, e.g.,
+//
+//	{"file":"main.sl","line":2,"column":5,"end_line":2,"end_column":8,"severity":"error",
+//	"phase":"Type Checker","code":"E0301","message":"...","notes":[]}
+//
+// (on a single line). The positions are 0 when they are not known.
+type JSONWriter struct {
+	encoder *json.Encoder
+}
+
+func NewJSONWriter(output io.Writer) *JSONWriter {
+	encoder := json.NewEncoder(output)
+	// so that messages such as "&& expected" are kept as they are
+	encoder.SetEscapeHTML(false)
+	return &JSONWriter{encoder: encoder}
+}
+
+type jsonDiagnostic struct {
+	jsonSpan
+	Severity string     `json:"severity"`
+	Phase    string     `json:"phase"`
+	Code     string     `json:"code"`
+	Message  string     `json:"message"`
+	Notes    []jsonNote `json:"notes"`
+}
+
+type jsonNote struct {
+	jsonSpan
+	Message string `json:"message"`
+}
+
+type jsonSpan struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"end_line"`
+	EndColumn int    `json:"end_column"`
+}
+
+func (w *JSONWriter) RenderError(err error) {
+	for _, diagnostic := range FromErrors(err) {
+		w.Render(diagnostic)
+	}
+}
+
+func (w *JSONWriter) Render(diagnostic Diagnostic) {
+	output := jsonDiagnostic{
+		jsonSpan: spanToJSON(diagnostic.Span),
+		Severity: diagnostic.Severity.String(),
+		Phase:    diagnostic.Phase,
+		Code:     diagnostic.Code,
+		Message:  diagnostic.Message,
+		Notes:    []jsonNote{},
+	}
+	for _, note := range diagnostic.Notes {
+		output.Notes = append(output.Notes, jsonNote{
+			jsonSpan: spanToJSON(note.Span),
+			Message:  note.Message,
+		})
+	}
+	// the values always encode
+	_ = w.encoder.Encode(output)
+}
+
+func spanToJSON(span common.Span) jsonSpan {
+	return jsonSpan{
+		File:      span.File,
+		Line:      span.StartLine,
+		Column:    span.StartCol,
+		EndLine:   span.EndLine,
+		EndColumn: span.EndCol,
+	}
+}
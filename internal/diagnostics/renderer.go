@@ -0,0 +1,168 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[1;31m"
+	colorYellow = "\x1b[1;33m"
+	colorCyan   = "\x1b[1;36m"
+	colorBlue   = "\x1b[1;34m"
+)
+
+// Writes diagnostics for people to read, e.g.,
+//
+//	error[E0301]: identifier datatype and operand datatype do not match
+//	 --> main.sl:2:9
+//	  |
+//	2 | x = 'c';
+//	  |     ^~~
+//	note: x declared here as int
+//	 --> main.sl:1:9
+//	  |
+//	1 | let mut x = 5;
+//	  |         ^
+type Renderer struct {
+	output io.Writer
+	color  bool
+	// the lines of every source file read so far
+	sources map[string][]string
+}
+
+// Colors are used only when the output is a terminal, and NO_COLOR is not set.
+func NewRenderer(output io.Writer) *Renderer {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return &Renderer{
+		output:  output,
+		color:   !noColor && isTerminal(output),
+		sources: map[string][]string{},
+	}
+}
+
+// errors joined together are rendered one after the other
+func (r *Renderer) RenderError(err error) {
+	for _, diagnostic := range FromErrors(err) {
+		r.Render(diagnostic)
+	}
+}
+
+func (r *Renderer) Render(diagnostic Diagnostic) {
+	header := diagnostic.Severity.String()
+	if diagnostic.Code != "" {
+		header += "[" + diagnostic.Code + "]"
+	}
+	fmt.Fprintf(
+		r.output, "%v%v\n",
+		r.paint(severityColor(diagnostic.Severity), header),
+		r.paint(colorBold, ": "+diagnostic.Message),
+	)
+	r.renderSnippet(diagnostic.Span, severityColor(diagnostic.Severity))
+
+	for _, note := range diagnostic.Notes {
+		if note.Span.IsZero() {
+			fmt.Fprintf(
+				r.output, "  %v %v%v\n",
+				r.paint(colorBlue, "="), r.paint(colorBold, "note: "), note.Message,
+			)
+			continue
+		}
+		fmt.Fprintf(
+			r.output, "%v%v\n",
+			r.paint(colorCyan, "note"), r.paint(colorBold, ": "+note.Message),
+		)
+		r.renderSnippet(note.Span, colorCyan)
+	}
+}
+
+// the location, followed by the first line of the span underlined
+func (r *Renderer) renderSnippet(span common.Span, color string) {
+	if span.IsZero() {
+		return
+	}
+	gutter := strings.Repeat(" ", len(fmt.Sprint(span.StartLine)))
+	fmt.Fprintf(r.output, "%v%v %v\n", gutter, r.paint(colorBlue, "-->"), span)
+
+	line, ok := r.sourceLine(span.File, span.StartLine)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(r.output, "%v %v\n", gutter, r.paint(colorBlue, "|"))
+	fmt.Fprintf(
+		r.output, "%v %v %v\n",
+		r.paint(colorBlue, fmt.Sprint(span.StartLine)), r.paint(colorBlue, "|"), line,
+	)
+
+	start := min(max(span.StartCol-1, 0), len(line))
+	end := len(line)
+	if span.EndLine == span.StartLine {
+		end = min(span.EndCol, len(line))
+	}
+	// tabs are kept so that the underline lines up however wide they are shown
+	padding := []byte{}
+	for _, character := range []byte(line[:start]) {
+		if character == '\t' {
+			padding = append(padding, '\t')
+		} else {
+			padding = append(padding, ' ')
+		}
+	}
+	underline := "^" + strings.Repeat("~", max(end-start-1, 0))
+	fmt.Fprintf(
+		r.output, "%v %v %v%v\n",
+		gutter, r.paint(colorBlue, "|"), string(padding), r.paint(color, underline),
+	)
+}
+
+func (r *Renderer) sourceLine(file string, lineNumber int) (string, bool) {
+	if file == "" {
+		return "", false
+	}
+	lines, ok := r.sources[file]
+	if !ok {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			lines = []string{}
+		} else {
+			lines = strings.Split(string(content), "\n")
+		}
+		r.sources[file] = lines
+	}
+	if lineNumber < 1 || lineNumber > len(lines) {
+		return "", false
+	}
+	return strings.TrimSuffix(lines[lineNumber-1], "\r"), true
+}
+
+func (r *Renderer) paint(color string, text string) string {
+	if !r.color {
+		return text
+	}
+	return color + text + colorReset
+}
+
+func severityColor(severity Severity) string {
+	switch severity {
+	case SeverityWarning:
+		return colorYellow
+	case SeverityNote:
+		return colorCyan
+	}
+	return colorRed
+}
+
+func isTerminal(output io.Writer) bool {
+	file, ok := output.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,349 @@
+package frontend
+
+import (
+	"io"
+	"strings"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+)
+
+const indentation = "    "
+
+// Reprints the program in the canonical style, e.g.,
+//
+//	fn add(a: int, b: int) -> int {
+//	    return a + b;
+//	};
+//
+//	if x > 0 && !(y == 1) {
+//	    x = add(x, -1); // comments are kept
+//	} else if x == 0 {
+//	    printf("zero\n");
+//	} else {};
+//
+// Runs of blank lines are kept as a single blank line.
+// A program with syntax errors is not formatted, since the instructions with errors would be lost.
+func Formatter(reader io.Reader, fileName string) (string, error) {
+	lex := make(chan common.Token)
+	tokens := make(chan common.Token)
+	go Lexer(reader, fileName, lex)
+
+	// the parser skips the comments, so they are picked out on the way
+	comments := []common.Token{}
+	go func() {
+		defer close(tokens)
+		for token := range lex {
+			if token.TokenKind == common.TokenComment {
+				comments = append(comments, token)
+			}
+			tokens <- token
+		}
+	}()
+
+	root, err := Parser(tokens, 0)
+	if err != nil {
+		return "", err
+	}
+
+	f := &formatter{comments: comments}
+	f.program(root, 0)
+	// the comments after the last instruction
+	f.commentsBefore(-1, 0)
+	return f.output.String(), nil
+}
+
+type formatter struct {
+	output strings.Builder
+	// the comments not written yet, in the order they appear
+	comments []common.Token
+	// the source line of whatever was written last in the current block, 0 if nothing was
+	lastLine int
+}
+
+func (f *formatter) program(input common.ParseTreeNode, depth int) {
+	for len(input.ChildNodes) == 2 {
+		// I -> I1;I
+		instruction := input.ChildNodes[0]
+		// the ';' may be on a line after the end of the instruction
+		lineEnd := input.InnerToken.Span.EndLine
+		input = input.ChildNodes[1]
+
+		span := instruction.Span()
+		if hasBlock(instruction) {
+			f.commentsBefore(span.StartLine, depth)
+		} else {
+			// comments in the middle of an instruction are moved above it
+			f.commentsBefore(span.EndLine, depth)
+		}
+		f.blankLineBefore(span.StartLine)
+		f.output.WriteString(strings.Repeat(indentation, depth))
+		f.instruction(instruction, depth)
+		f.output.WriteString(";")
+		if !startsOn(input, span.EndLine) {
+			f.trailingComment(span.EndLine)
+		}
+		f.output.WriteString("\n")
+		f.lastLine = lineEnd
+	}
+}
+
+func (f *formatter) instruction(input common.ParseTreeNode, depth int) {
+	children := input.ChildNodes
+	switch input.InnerToken.Token {
+	case "I1>let I6":
+		f.output.WriteString("let " + formatDeclaration(children[1]))
+
+	case "I1>v=R":
+		f.output.WriteString(
+			children[0].InnerToken.Token + formatExpression(children[1]) +
+				" = " + formatExpression(children[3]),
+		)
+
+	case "I1>v(K)":
+		f.output.WriteString(
+			children[0].InnerToken.Token + "(" + formatExpression(children[2]) + ")",
+		)
+
+	case "I1>if R {I} I4":
+		f.ifStatement(input, depth)
+
+	case "I1>while R {I}":
+		f.output.WriteString("while " + formatExpression(children[1]) + " ")
+		f.block(children[2], children[3], children[4], depth)
+
+	case "I1>for v in R..R S {I}":
+		f.output.WriteString(
+			"for " + children[1].InnerToken.Token + " in " + formatExpression(children[3]) +
+				children[4].InnerToken.Token + formatExpression(children[5]),
+		)
+		if len(children[6].ChildNodes) == 2 {
+			f.output.WriteString(" step " + formatExpression(children[6].ChildNodes[1]))
+		}
+		f.output.WriteString(" ")
+		f.block(children[7], children[8], children[9], depth)
+
+	case "I1>for v in R {I}":
+		f.output.WriteString(
+			"for " + children[1].InnerToken.Token + " in " + formatExpression(children[3]) + " ",
+		)
+		f.block(children[4], children[5], children[6], depth)
+
+	case "I1>output (str C)":
+		f.output.WriteString(
+			"printf(" + children[1].InnerToken.Token + formatExpression(children[2]) + ")",
+		)
+
+	case "I1>output (K)":
+		f.output.WriteString(
+			children[0].InnerToken.Token + "(" + formatExpression(children[1]) + ")",
+		)
+
+	case "I1>fn v(P) Q {I}":
+		f.output.WriteString(
+			"fn " + children[1].InnerToken.Token +
+				"(" + formatParameters(children[2]) + ")" + formatReturnType(children[3]) + " ",
+		)
+		f.block(children[4], children[5], children[6], depth)
+
+	case "I1>return R":
+		f.output.WriteString("return " + formatExpression(children[1]))
+
+	case "I1>return":
+		f.output.WriteString("return")
+
+	case "I1>break", "I1>continue":
+		f.output.WriteString(children[0].InnerToken.Token)
+
+	case "I1>break v", "I1>continue v":
+		f.output.WriteString(children[0].InnerToken.Token + " " + children[1].InnerToken.Token)
+
+	case "I1>v: I9":
+		f.output.WriteString(children[0].InnerToken.Token + ": ")
+		f.instruction(children[2], depth)
+	}
+}
+
+// if R {I} I4, where I4 may be else if R {I} I4 or else {I}
+func (f *formatter) ifStatement(input common.ParseTreeNode, depth int) {
+	children := input.ChildNodes
+	f.output.WriteString("if " + formatExpression(children[1]) + " ")
+	f.block(children[2], children[3], children[4], depth)
+
+	elseNode := children[5]
+	if len(elseNode.ChildNodes) == 0 {
+		return
+	}
+	f.output.WriteString(" else ")
+	next := elseNode.ChildNodes[1]
+	if next.InnerToken.Token == "I7>{I}" {
+		f.block(next.ChildNodes[0], next.ChildNodes[1], next.ChildNodes[2], depth)
+		return
+	}
+	f.ifStatement(next, depth)
+}
+
+// writes { I } with the instructions indented once more
+func (f *formatter) block(open, program, close common.ParseTreeNode, depth int) {
+	openLine := open.InnerToken.Span.StartLine
+	closeLine := close.InnerToken.Span.StartLine
+	if len(program.ChildNodes) == 0 && !f.hasCommentBefore(closeLine) {
+		f.output.WriteString("{}")
+		return
+	}
+
+	f.output.WriteString("{")
+	if !startsOn(program, openLine) {
+		f.trailingComment(openLine)
+	}
+	f.output.WriteString("\n")
+
+	f.lastLine = 0
+	f.program(program, depth+1)
+	f.commentsBefore(closeLine, depth+1)
+	f.output.WriteString(strings.Repeat(indentation, depth) + "}")
+	f.lastLine = closeLine
+}
+
+// writes the comments that come before the line on lines of their own, -1 for all of them
+func (f *formatter) commentsBefore(line int, depth int) {
+	for f.hasCommentBefore(line) || line == -1 && len(f.comments) > 0 {
+		comment := f.comments[0]
+		f.comments = f.comments[1:]
+		f.blankLineBefore(comment.Span.StartLine)
+		f.output.WriteString(strings.Repeat(indentation, depth) + comment.Token + "\n")
+		f.lastLine = comment.Span.StartLine
+	}
+}
+
+func (f *formatter) hasCommentBefore(line int) bool {
+	return len(f.comments) > 0 && f.comments[0].Span.StartLine < line
+}
+
+// writes the comment at the end of the line, if there is one
+func (f *formatter) trailingComment(line int) {
+	if len(f.comments) > 0 && f.comments[0].Span.StartLine == line {
+		f.output.WriteString(" " + f.comments[0].Token)
+		f.comments = f.comments[1:]
+	}
+}
+
+// keeps a blank line if there was at least one in the source
+func (f *formatter) blankLineBefore(line int) {
+	if f.lastLine > 0 && line > f.lastLine+1 {
+		f.output.WriteString("\n")
+	}
+}
+
+func hasBlock(instruction common.ParseTreeNode) bool {
+	switch instruction.InnerToken.Token {
+	case "I1>if R {I} I4", "I1>while R {I}", "I1>for v in R..R S {I}", "I1>for v in R {I}",
+		"I1>v: I9", "I1>fn v(P) Q {I}":
+		return true
+	}
+	return false
+}
+
+// whether the first instruction of the program starts on the line
+func startsOn(program common.ParseTreeNode, line int) bool {
+	return len(program.ChildNodes) == 2 && program.ChildNodes[0].Span().StartLine == line
+}
+
+// mut v = R, mut v, or v = R, each of which may have a type after the v
+func formatDeclaration(input common.ParseTreeNode) string {
+	children := input.ChildNodes
+	switch input.InnerToken.Token {
+	case "I6>mut v I8", "I6>mut v:T I8":
+		declaration := "mut " + children[1].InnerToken.Token
+		if len(children) == 4 {
+			declaration += ": " + formatType(children[2])
+		}
+		assignment := children[len(children)-1]
+		if len(assignment.ChildNodes) == 0 {
+			return declaration
+		}
+		return declaration + " = " + formatExpression(assignment.ChildNodes[1])
+
+	case "I6>v:T=R":
+		return children[0].InnerToken.Token + ": " + formatType(children[1]) +
+			" = " + formatExpression(children[3])
+
+	case "I6>v:T":
+		return children[0].InnerToken.Token + ": " + formatType(children[1])
+	}
+	return children[0].InnerToken.Token + " = " + formatExpression(children[2])
+}
+
+// a datatype, or [T; int]
+func formatType(input common.ParseTreeNode) string {
+	if input.InnerToken.Token == "T>[T;int]" {
+		return "[" + formatType(input.ChildNodes[1]) + "; " + input.ChildNodes[2].InnerToken.Token + "]"
+	}
+	return input.InnerToken.Token
+}
+
+func formatParameters(input common.ParseTreeNode) string {
+	parameters := []string{}
+	// P -> v : type P1
+	for len(input.ChildNodes) == 3 {
+		parameters = append(
+			parameters,
+			input.ChildNodes[0].InnerToken.Token+": "+input.ChildNodes[1].InnerToken.Token,
+		)
+		input = input.ChildNodes[2]
+	}
+	return strings.Join(parameters, ", ")
+}
+
+func formatReturnType(input common.ParseTreeNode) string {
+	if len(input.ChildNodes) == 0 {
+		return ""
+	}
+	return " -> " + input.ChildNodes[0].InnerToken.Token
+}
+
+// The parse tree leaves out some of the tokens, such as ( ) and commas,
+// so they are put back from the production.
+func formatExpression(input common.ParseTreeNode) string {
+	children := input.ChildNodes
+	switch input.InnerToken.Token {
+	case "Rz>|| Ra Rz", "Ry>&& Rb Ry", "R1>opE", "E1>opTE1", "T1>opFT1":
+		return " " + children[0].InnerToken.Token + " " + formatExpressions(children[1:])
+
+	case "Rb>!(R)":
+		return "!(" + formatExpression(children[1]) + ")"
+
+	case "F>(R)":
+		return "(" + formatExpression(children[0]) + ")"
+
+	case "F>getchar()":
+		return "getchar()"
+
+	case "F>F as type":
+		return formatExpression(children[0]) + " as " + children[2].InnerToken.Token
+
+	case "F>len(R)":
+		return "len(" + formatExpression(children[1]) + ")"
+
+	case "F>v(K)":
+		return children[0].InnerToken.Token + "(" + formatExpression(children[2]) + ")"
+
+	case "K1", "L1", "C>,R C":
+		if len(children) == 0 {
+			return ""
+		}
+		return ", " + formatExpressions(children)
+	}
+
+	if input.InnerToken.TokenKind == common.TokenBlock {
+		return formatExpressions(children)
+	}
+	return input.InnerToken.Token
+}
+
+func formatExpressions(inputs []common.ParseTreeNode) string {
+	formatted := ""
+	for _, input := range inputs {
+		formatted += formatExpression(input)
+	}
+	return formatted
+}
@@ -0,0 +1,72 @@
+package frontend
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatter(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		output string
+	}{
+		{
+			name:   "spacing and indentation",
+			source: "fn add(a:int,b:int)->int{return a+b;};\nif x>0&&!(y==1){x=add(x,-1);}else{};\n",
+			output: "fn add(a: int, b: int) -> int {\n    return a + b;\n};\nif x > 0 && !(y == 1) {\n    x = add(x, -1);\n} else {};\n",
+		},
+		{
+			name:   "blank lines",
+			source: "let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;\n",
+			output: "let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			name:   "comments",
+			source: "// first\nlet a = 1; // one\n\n// last\n",
+			output: "// first\nlet a = 1; // one\n\n// last\n",
+		},
+		{
+			name:   "';' on the line after a block",
+			source: "if true {\n    println(1);\n}\n;\nprintf(\"x\\n\");\n",
+			output: "if true {\n    println(1);\n};\nprintf(\"x\\n\");\n",
+		},
+		{
+			name:   "';' on the line after an instruction",
+			source: "let a = 1\n;\nlet b = 2;\n",
+			output: "let a = 1;\nlet b = 2;\n",
+		},
+		{
+			name:   "blank line after a ';' on a line of its own",
+			source: "while false {}\n;\n\nlet b = 2;\n",
+			output: "while false {};\n\nlet b = 2;\n",
+		},
+		{
+			name:   "arrays declared without a value",
+			source: "let grid:[[int;3];3];\nlet mut buf:[char;64];\n",
+			output: "let grid: [[int; 3]; 3];\nlet mut buf: [char; 64];\n",
+		},
+	}
+
+	for _, test := range tests {
+		output, err := Formatter(strings.NewReader(test.source), "test.sl")
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if output != test.output {
+			t.Errorf("%v: formatted as\n%v\nwant\n%v", test.name, output, test.output)
+		}
+		// formatting again changes nothing
+		again, err := Formatter(strings.NewReader(output), "test.sl")
+		if err != nil || again != output {
+			t.Errorf("%v: formatting again gave\n%v\n%v", test.name, again, err)
+		}
+	}
+}
+
+func TestFormatterSyntaxError(t *testing.T) {
+	if _, err := Formatter(strings.NewReader("let = 1;\n"), "test.sl"); err == nil {
+		t.Error("a program with a syntax error was formatted")
+	}
+}
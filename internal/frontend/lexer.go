@@ -10,15 +10,18 @@ import (
 
 // The basic idea of this function's first parameter as io.Reader
 // is to accept both stdin and file input as parameters.
-func Lexer(reader io.Reader, output chan<- common.Token) {
+// The file name is only used for the spans of the tokens.
+func Lexer(reader io.Reader, fileName string, output chan<- common.Token) {
 	defer close(output)
 	scanner := bufio.NewScanner(reader)
 	lineNumber := 0
+	lineLength := 0
 
 	for scanner.Scan() {
 		lineNumber += 1
 		line := scanner.Text()
-		lexLine(line, lineNumber, output)
+		lineLength = len(line)
+		lexLine(line, fileName, lineNumber, output)
 	}
 	if err := scanner.Err(); err != nil {
 		output <- common.Token{
@@ -28,13 +31,23 @@ func Lexer(reader io.Reader, output chan<- common.Token) {
 	}
 
 	// To denote the end of scanner
+	// it is placed just after the last character of the file
 	output <- common.Token{
 		TokenKind: common.TokenEOF,
 		Token:     "Lexer: end of file",
+		Span: common.Span{
+			File:      fileName,
+			StartLine: max(lineNumber, 1),
+			StartCol:  lineLength + 1,
+			EndLine:   max(lineNumber, 1),
+			EndCol:    lineLength + 1,
+		},
 	}
 }
 
-func lexLine(line string, lineNumber int, output chan<- common.Token) {
+func lexLine(line, fileName string, lineNumber int, output chan<- common.Token) {
+	// the number of characters of the line before the remaining line
+	column := 0
 	for len(line) > 0 {
 		op, remainingLine := lexSegment(line)
 		// TokenEmpty is sent in case the remaining string has no meaningful components
@@ -44,25 +57,39 @@ func lexLine(line string, lineNumber int, output chan<- common.Token) {
 			continue
 		}
 
-		// set LineNumber here
-		op.LineNumber = lineNumber
+		// lexSegment trims the segment on both sides before lexing it,
+		// so the token starts after the leading whitespace and ends where the remaining line starts
+		segment := trimSegment(line)
+		leading := strings.Index(line, segment)
+		end := column + leading + len(segment) - len(remainingLine)
+		op.Span = common.Span{
+			File:      fileName,
+			StartLine: lineNumber,
+			StartCol:  column + leading + 1,
+			EndLine:   lineNumber,
+			EndCol:    end,
+		}
 		output <- op
+		column = end
 		line = remainingLine
 	}
 }
 
 func lexSegment(segment string) (common.Token, string) {
-	// Remove all leading and ending \t
-	segment = strings.Trim(segment, "\t")
-	// Remove all leading and ending ' '
-	segment = strings.Trim(segment, " ")
+	segment = trimSegment(segment)
 
-	if len(segment) == 0 || len(segment) >= 2 && segment[:2] == "//" {
+	if len(segment) == 0 {
 		return common.Token{
 			TokenKind: common.TokenEmpty,
 			Token:     "",
 		}, ""
 	}
+	if strings.HasPrefix(segment, "//") {
+		return common.Token{
+			TokenKind: common.TokenComment,
+			Token:     segment,
+		}, ""
+	}
 
 	switch segment[0] {
 	case ';':
@@ -120,9 +147,40 @@ func lexSegment(segment string) (common.Token, string) {
 		}, segment[1:]
 
 	case '-':
+		if len(segment) < 2 || segment[1] != '>' {
+			return common.Token{
+				TokenKind: common.TokenExpressionSub,
+				Token:     "-",
+			}, segment[1:]
+		}
+		return common.Token{
+			TokenKind: common.TokenArrow,
+			Token:     "->",
+		}, segment[2:]
+
+	case '.':
+		if len(segment) < 2 || segment[1] != '.' {
+			// a float must start with a digit
+			return common.Token{
+				TokenKind: common.TokenError,
+				Token:     segment,
+			}, ""
+		}
+		if len(segment) > 2 && segment[2] == '=' {
+			return common.Token{
+				TokenKind: common.TokenRange,
+				Token:     "..=",
+			}, segment[3:]
+		}
+		return common.Token{
+			TokenKind: common.TokenRange,
+			Token:     "..",
+		}, segment[2:]
+
+	case ':':
 		return common.Token{
-			TokenKind: common.TokenExpressionSub,
-			Token:     "-",
+			TokenKind: common.TokenColon,
+			Token:     ":",
 		}, segment[1:]
 
 	case '*':
@@ -217,6 +275,14 @@ func lexSegment(segment string) (common.Token, string) {
 			Token:     "||",
 		}, segment[2:]
 
+	case 'a':
+		if isWordToken(segment, "as") {
+			return common.Token{
+				TokenKind: common.TokenAs,
+				Token:     "as",
+			}, segment[2:]
+		}
+
 	case 'g':
 		if isWordToken(segment, "getchar") {
 			return common.Token{
@@ -224,13 +290,64 @@ func lexSegment(segment string) (common.Token, string) {
 				Token:     "getchar",
 			}, segment[7:]
 		}
-	case 'i':
+	case 'i', 'u':
+		if token, rest, ok := lexSizedDatatype(segment); ok {
+			return token, rest
+		}
 		if isWordToken(segment, "if") {
 			return common.Token{
 				TokenKind: common.TokenIf,
 				Token:     "if",
 			}, segment[2:]
 		}
+		if isWordToken(segment, "int") {
+			return common.Token{
+				TokenKind: common.TokenDatatype,
+				Token:     "int",
+			}, segment[3:]
+		}
+		if isWordToken(segment, "in") {
+			return common.Token{
+				TokenKind: common.TokenIn,
+				Token:     "in",
+			}, segment[2:]
+		}
+
+	case 'c':
+		if isWordToken(segment, "char") {
+			return common.Token{
+				TokenKind: common.TokenDatatype,
+				Token:     "char",
+			}, segment[4:]
+		}
+		if isWordToken(segment, "continue") {
+			return common.Token{
+				TokenKind: common.TokenContinue,
+				Token:     "continue",
+			}, segment[8:]
+		}
+
+	case 'b':
+		if isWordToken(segment, "bool") {
+			return common.Token{
+				TokenKind: common.TokenDatatype,
+				Token:     "bool",
+			}, segment[4:]
+		}
+		if isWordToken(segment, "break") {
+			return common.Token{
+				TokenKind: common.TokenBreak,
+				Token:     "break",
+			}, segment[5:]
+		}
+
+	case 'r':
+		if isWordToken(segment, "return") {
+			return common.Token{
+				TokenKind: common.TokenReturn,
+				Token:     "return",
+			}, segment[6:]
+		}
 
 	case 'e':
 		if isWordToken(segment, "else") {
@@ -255,6 +372,12 @@ func lexSegment(segment string) (common.Token, string) {
 				Token:     "let",
 			}, segment[3:]
 		}
+		if isWordToken(segment, "len") {
+			return common.Token{
+				TokenKind: common.TokenLength,
+				Token:     "len",
+			}, segment[3:]
+		}
 
 	case 'm':
 		if isWordToken(segment, "mut") {
@@ -271,6 +394,18 @@ func lexSegment(segment string) (common.Token, string) {
 				Token:     "printf",
 			}, segment[6:]
 		}
+		if isWordToken(segment, "println") {
+			return common.Token{
+				TokenKind: common.TokenOutput,
+				Token:     "println",
+			}, segment[7:]
+		}
+		if isWordToken(segment, "print") {
+			return common.Token{
+				TokenKind: common.TokenOutput,
+				Token:     "print",
+			}, segment[5:]
+		}
 
 	case 't':
 		if isWordToken(segment, "true") {
@@ -287,6 +422,35 @@ func lexSegment(segment string) (common.Token, string) {
 				Token:     "false",
 			}, segment[5:]
 		}
+		if isWordToken(segment, "fn") {
+			return common.Token{
+				TokenKind: common.TokenFunction,
+				Token:     "fn",
+			}, segment[2:]
+		}
+		if isWordToken(segment, "float") {
+			return common.Token{
+				TokenKind: common.TokenDatatype,
+				Token:     "float",
+			}, segment[5:]
+		}
+		if token, rest, ok := lexSizedDatatype(segment); ok {
+			return token, rest
+		}
+		if isWordToken(segment, "for") {
+			return common.Token{
+				TokenKind: common.TokenFor,
+				Token:     "for",
+			}, segment[3:]
+		}
+
+	case 's':
+		if isWordToken(segment, "step") {
+			return common.Token{
+				TokenKind: common.TokenStep,
+				Token:     "step",
+			}, segment[4:]
+		}
 	}
 
 	// variable check
@@ -393,9 +557,40 @@ func lexChar(segment string) (common.Token, string) {
 	}
 }
 
+// the sized numbers, which are also the suffixes of literals, e.g., 255u8
+var sizedDatatypes = []string{"i8", "i16", "i32", "i64", "u8", "u16", "u32", "u64", "f32"}
+
+func lexSizedDatatype(segment string) (common.Token, string, bool) {
+	for _, datatype := range sizedDatatypes {
+		if isWordToken(segment, datatype) {
+			return common.Token{
+				TokenKind: common.TokenDatatype,
+				Token:     datatype,
+			}, segment[len(datatype):], true
+		}
+	}
+	return common.Token{}, segment, false
+}
+
+// A number, with the suffix of a sized datatype if it has one, e.g., 255u8 or 1.5f32.
+// A float may only have the f32 suffix.
 func lexNumber(segment string) (common.Token, string) {
 	index := isNumberUntil(segment)
-	if index == len(segment) || segment[index] != '.' {
+	for _, suffix := range sizedDatatypes {
+		if !isWordToken(segment[index:], suffix) {
+			continue
+		}
+		kind := common.TokenLiteralInt
+		if suffix == "f32" {
+			kind = common.TokenLiteralFloat
+		}
+		return common.Token{
+			TokenKind: kind,
+			Token:     segment[:index+len(suffix)],
+		}, segment[index+len(suffix):]
+	}
+	// 0..n is a range, rather than a float
+	if index == len(segment) || segment[index] != '.' || strings.HasPrefix(segment[index:], "..") {
 		return common.Token{
 			TokenKind: common.TokenLiteralInt,
 			Token:     segment[:index],
@@ -409,10 +604,21 @@ func lexNumber(segment string) (common.Token, string) {
 			Token:     segment,
 		}, ""
 	}
+	end := floatingPointIndex + index + 1
+	if isWordToken(segment[end:], "f32") {
+		end += len("f32")
+	}
 	return common.Token{
 		TokenKind: common.TokenLiteralFloat,
-		Token:     segment[:floatingPointIndex+index+1],
-	}, segment[floatingPointIndex+index+1:]
+		Token:     segment[:end],
+	}, segment[end:]
+}
+
+func trimSegment(segment string) string {
+	// Remove all leading and ending \t
+	segment = strings.Trim(segment, "\t")
+	// Remove all leading and ending ' '
+	return strings.Trim(segment, " ")
 }
 
 // Intended for multiple character tokens like if, mut, etc.
@@ -1,23 +1,56 @@
 package frontend
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/SamJohn04/simple-lang-compiler/internal/common"
 )
 
 // Parsing is done using LL(1) method.
-func Parser(input <-chan common.Token) (common.ParseTreeNode, error) {
+// An instruction with a syntax error is left out of the tree, and parsing goes on after it,
+// so that every syntax error is reported at once; up to errorLimit of them, if it is above 0.
+// The tree of the instructions without errors is returned along with the errors.
+func Parser(input <-chan common.Token, errorLimit int) (common.ParseTreeNode, error) {
 	currentPointer := movePointerToNextToken(input)
+	recovery := &syntaxRecovery{limit: errorLimit}
+
+	output, err := parseProgram(input, &currentPointer, recovery)
+	// the program only ends at the end of the file, not at a '}' without a matching '{'
+	for err == nil && currentPointer.TokenKind == common.TokenCloseCurly {
+		if !recovery.record(parserError("'}' without a matching '{'", &currentPointer)) {
+			err = recovery.errors[len(recovery.errors)-1]
+			break
+		}
+		currentPointer = movePointerToNextToken(input)
+		var rest common.ParseTreeNode
+		rest, err = parseProgram(input, &currentPointer, recovery)
+		output = appendProgram(output, rest)
+	}
 
-	output, err := parseProgram(input, &currentPointer)
-	if err != nil && currentPointer.TokenKind == common.TokenError {
-		return common.ParseTreeNode{}, &common.CompilationError{
-			PointOfFailure: "Parser",
-			Message:        fmt.Sprintf("Error token causing havoc: \n\t%v", err),
+	if err != nil {
+		// so that the lexer is not left waiting
+		for range input {
 		}
-	} else if err != nil {
-		return common.ParseTreeNode{}, err
+		// nothing after the last error was parsed, so the program is left empty
+		output = common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "I",
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+		// the limit is told as a note on the last error, which is where parsing stopped
+		var last *common.CompilationError
+		if len(recovery.errors) > 0 && errors.As(recovery.errors[len(recovery.errors)-1], &last) {
+			last.Notes = append(last.Notes, common.Note{
+				Message: fmt.Sprintf("stopped after %v syntax errors", len(recovery.errors)),
+			})
+		}
+		return output, errors.Join(recovery.errors...)
+	}
+	if len(recovery.errors) > 0 {
+		return output, errors.Join(recovery.errors...)
 	}
 	return output, nil
 }
@@ -25,6 +58,7 @@ func Parser(input <-chan common.Token) (common.ParseTreeNode, error) {
 func parseProgram(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+	recovery *syntaxRecovery,
 ) (common.ParseTreeNode, error) {
 	switch currentPointer.TokenKind {
 	case common.TokenIdent:
@@ -35,26 +69,48 @@ func parseProgram(
 		fallthrough
 	case common.TokenWhile:
 		fallthrough
+	case common.TokenFor:
+		fallthrough
+	case common.TokenFunction:
+		fallthrough
+	case common.TokenReturn:
+		fallthrough
+	case common.TokenBreak:
+		fallthrough
+	case common.TokenContinue:
+		fallthrough
 	case common.TokenOutput:
 		// I -> I1;I
-		childI1, err := parseNextInstruction(input, currentPointer)
-		if err != nil {
-			return common.ParseTreeNode{}, err
-		}
-
-		if currentPointer.TokenKind != common.TokenLineEnd {
-			return common.ParseTreeNode{}, parserError(
+		// the names declared by the instruction, kept apart from those of the instruction around it
+		outerDeclared := recovery.declared
+		recovery.declared = nil
+		childI1, err := parseNextInstruction(input, currentPointer, recovery)
+		declared := recovery.declared
+		recovery.declared = outerDeclared
+		if err == nil && currentPointer.TokenKind != common.TokenLineEnd {
+			err = parserError(
 				"end of line (;) expected",
 				currentPointer,
 			)
 		}
+		if err != nil {
+			// the instruction is left out
+			if !recovery.recover(err, input, currentPointer) {
+				return common.ParseTreeNode{}, err
+			}
+			childI, err := parseProgram(input, currentPointer, recovery)
+			return skippedInstruction(declared, childI), err
+		}
 
+		// the ';' is not a node of its own, so where it is, which the formatter needs, is kept here
+		lineEnd := currentPointer.Span
 		*currentPointer = movePointerToNextToken(input)
-		childI, err := parseProgram(input, currentPointer)
+		childI, err := parseProgram(input, currentPointer, recovery)
 		return common.ParseTreeNode{
 			InnerToken: common.Token{
 				TokenKind: common.TokenBlock,
 				Token:     "I>I1;I",
+				Span:      lineEnd,
 			},
 			ChildNodes: []common.ParseTreeNode{
 				childI1,
@@ -75,38 +131,61 @@ func parseProgram(
 		}, nil
 
 	default:
-		return common.ParseTreeNode{}, parserError(
+		err := parserError(
 			"unexpected token",
 			currentPointer,
 		)
+		if !recovery.recover(err, input, currentPointer) {
+			return common.ParseTreeNode{}, err
+		}
+		return parseProgram(input, currentPointer, recovery)
 	}
 }
 
 func parseNextInstruction(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+	recovery *syntaxRecovery,
 ) (common.ParseTreeNode, error) {
 	switch currentPointer.TokenKind {
 	case common.TokenIdent:
-		// I1 -> v=R
-		return parseReassignment(input, currentPointer)
+		// I1 -> v=R | v(K) | v: I9
+		return parseReassignment(input, currentPointer, recovery)
 
 	case common.TokenLet:
 		// I1 -> let I6
-		return parseAssignment(input, currentPointer)
+		return parseAssignment(input, currentPointer, recovery)
 
 	case common.TokenIf:
 		// I1 -> if R { I } I4
-		return parseIf(input, currentPointer)
+		return parseIf(input, currentPointer, recovery)
 
 	case common.TokenWhile:
 		// I1 -> while R { I }
-		return parseWhile(input, currentPointer)
+		return parseWhile(input, currentPointer, recovery)
+
+	case common.TokenFor:
+		// I1 -> for v in R..R S { I } | for v in R { I }
+		return parseFor(input, currentPointer, recovery)
 
 	case common.TokenOutput:
-		// I1 -> printf(str C)
+		// I1 -> printf(str C) or print(K)
 		return parsePrintf(input, currentPointer)
 
+	case common.TokenFunction:
+		// I1 -> fn v(P) Q { I }
+		return parseFunction(input, currentPointer, recovery)
+
+	case common.TokenReturn:
+		// I1 -> return Z
+		return parseReturn(input, currentPointer)
+
+	case common.TokenBreak:
+		fallthrough
+	case common.TokenContinue:
+		// I1 -> break L | continue L
+		return parseLoopJump(input, currentPointer)
+
 	default:
 		return common.ParseTreeNode{}, parserError(
 			"unexpected parse token in I1",
@@ -118,17 +197,30 @@ func parseNextInstruction(
 func parseReassignment(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+	recovery *syntaxRecovery,
 ) (common.ParseTreeNode, error) {
 	// I1 -> vA=R
 	childIdent := common.ParseTreeNode{
 		InnerToken: common.Token{
 			TokenKind: currentPointer.TokenKind,
 			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
 
 	*currentPointer = movePointerToNextToken(input)
+	if currentPointer.TokenKind == common.TokenOpenParanthesis {
+		// I1 -> v(K)
+		childCall, err := parseCall(input, currentPointer, childIdent)
+		childCall.InnerToken.Token = "I1>v(K)"
+		return childCall, err
+	}
+	if currentPointer.TokenKind == common.TokenColon {
+		// I1 -> v: I9, a loop with a label
+		return parseLabelledLoop(input, currentPointer, childIdent, recovery)
+	}
+
 	childArrayUsage, err := parseArrayUsage(input, currentPointer)
 	if err != nil {
 		return common.ParseTreeNode{}, err
@@ -144,6 +236,7 @@ func parseReassignment(
 		InnerToken: common.Token{
 			TokenKind: currentPointer.TokenKind,
 			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
@@ -173,9 +266,9 @@ func parseArrayUsage(
 		// A -> [E]A
 		childOpenSquareBraces := common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  currentPointer.TokenKind,
-				Token:      currentPointer.Token,
-				LineNumber: currentPointer.LineNumber,
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -193,9 +286,9 @@ func parseArrayUsage(
 		}
 		childCloseSquareBraces := common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  currentPointer.TokenKind,
-				Token:      currentPointer.Token,
-				LineNumber: currentPointer.LineNumber,
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -236,6 +329,10 @@ func parseArrayUsage(
 		fallthrough
 	case common.TokenOpenCurly:
 		fallthrough
+	case common.TokenRange:
+		fallthrough
+	case common.TokenStep:
+		fallthrough
 	case common.TokenCloseSquareBraces:
 		fallthrough
 	case common.TokenComma:
@@ -252,6 +349,8 @@ func parseArrayUsage(
 		fallthrough
 	case common.TokenExpressionModulo:
 		fallthrough
+	case common.TokenAs:
+		fallthrough
 	case common.TokenAssignment:
 		// A -> epsilon
 		return common.ParseTreeNode{
@@ -273,18 +372,20 @@ func parseArrayUsage(
 func parseAssignment(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+	recovery *syntaxRecovery,
 ) (common.ParseTreeNode, error) {
 	// I1 -> let I6
 	childLet := common.ParseTreeNode{
 		InnerToken: common.Token{
 			TokenKind: currentPointer.TokenKind,
 			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
 
 	*currentPointer = movePointerToNextToken(input)
-	childI6, err := parseAssignmentAfterLet(input, currentPointer)
+	childI6, err := parseAssignmentAfterLet(input, currentPointer, recovery)
 	return common.ParseTreeNode{
 		InnerToken: common.Token{
 			TokenKind: common.TokenBlock,
@@ -300,19 +401,40 @@ func parseAssignment(
 func parseAssignmentAfterLet(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+	recovery *syntaxRecovery,
 ) (common.ParseTreeNode, error) {
 	switch currentPointer.TokenKind {
 	case common.TokenIdent:
-		// I6 -> v=R
+		// I6 -> v=R | v:T=R | v:T
 		childIdent := common.ParseTreeNode{
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
+		recovery.declare(childIdent)
 
 		*currentPointer = movePointerToNextToken(input)
+		var childT *common.ParseTreeNode
+		if currentPointer.TokenKind == common.TokenColon {
+			annotation, err := parseTypeAnnotation(input, currentPointer)
+			if err != nil {
+				return common.ParseTreeNode{}, err
+			}
+			childT = &annotation
+		}
+		if childT != nil && currentPointer.TokenKind == common.TokenLineEnd {
+			// an array declared without a value
+			return common.ParseTreeNode{
+				InnerToken: common.Token{
+					TokenKind: common.TokenBlock,
+					Token:     "I6>v:T",
+				},
+				ChildNodes: []common.ParseTreeNode{childIdent, *childT},
+			}, nil
+		}
 		if currentPointer.TokenKind != common.TokenAssignment {
 			return common.ParseTreeNode{}, parserError(
 				"'=' expected",
@@ -323,12 +445,27 @@ func parseAssignmentAfterLet(
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
 
 		*currentPointer = movePointerToNextToken(input)
 		childR, err := parseR(input, currentPointer)
+		if childT != nil {
+			return common.ParseTreeNode{
+				InnerToken: common.Token{
+					TokenKind: common.TokenBlock,
+					Token:     "I6>v:T=R",
+				},
+				ChildNodes: []common.ParseTreeNode{
+					childIdent,
+					*childT,
+					childEquals,
+					childR,
+				},
+			}, err
+		}
 		return common.ParseTreeNode{
 			InnerToken: common.Token{
 				TokenKind: common.TokenBlock,
@@ -347,6 +484,7 @@ func parseAssignmentAfterLet(
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -363,11 +501,33 @@ func parseAssignmentAfterLet(
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
+		recovery.declare(childIdent)
 
 		*currentPointer = movePointerToNextToken(input)
+		if currentPointer.TokenKind == common.TokenColon {
+			// I6 -> mut v:T I8
+			childT, err := parseTypeAnnotation(input, currentPointer)
+			if err != nil {
+				return common.ParseTreeNode{}, err
+			}
+			childI8, err := parseMutableAssignment(input, currentPointer)
+			return common.ParseTreeNode{
+				InnerToken: common.Token{
+					TokenKind: common.TokenBlock,
+					Token:     "I6>mut v:T I8",
+				},
+				ChildNodes: []common.ParseTreeNode{
+					childMut,
+					childIdent,
+					childT,
+					childI8,
+				},
+			}, err
+		}
 		childI8, err := parseMutableAssignment(input, currentPointer)
 
 		return common.ParseTreeNode{
@@ -390,6 +550,110 @@ func parseAssignmentAfterLet(
 	}
 }
 
+// parses : T, with the current pointer at the :
+func parseTypeAnnotation(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	*currentPointer = movePointerToNextToken(input)
+	return parseType(input, currentPointer)
+}
+
+func parseType(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	switch currentPointer.TokenKind {
+	case common.TokenDatatype:
+		// T -> type
+		childDatatype := common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+		*currentPointer = movePointerToNextToken(input)
+		return childDatatype, nil
+
+	case common.TokenOpenSquareBraces:
+		// T -> [T; int]
+		childOpenSquare := common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		childT, err := parseType(input, currentPointer)
+		if err != nil {
+			return common.ParseTreeNode{}, err
+		}
+		if currentPointer.TokenKind != common.TokenLineEnd {
+			return common.ParseTreeNode{}, parserError(
+				"';' expected between the element type and the length",
+				currentPointer,
+			)
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		if currentPointer.TokenKind != common.TokenLiteralInt {
+			return common.ParseTreeNode{}, parserError(
+				"the length of an array type should be an int literal",
+				currentPointer,
+			)
+		}
+		childLength := common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		if currentPointer.TokenKind != common.TokenCloseSquareBraces {
+			return common.ParseTreeNode{}, parserError(
+				"']' expected after the length",
+				currentPointer,
+			)
+		}
+		childCloseSquare := common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "T>[T;int]",
+			},
+			ChildNodes: []common.ParseTreeNode{
+				childOpenSquare,
+				childT,
+				childLength,
+				childCloseSquare,
+			},
+		}, nil
+
+	default:
+		return common.ParseTreeNode{}, parserError(
+			"datatype expected",
+			currentPointer,
+		)
+	}
+}
+
 func parseMutableAssignment(
 	input <-chan common.Token,
 	currentPointer *common.Token,
@@ -411,6 +675,7 @@ func parseMutableAssignment(
 			InnerToken: common.Token{
 				TokenKind: common.TokenAssignment,
 				Token:     "=",
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -440,12 +705,14 @@ func parseMutableAssignment(
 func parseIf(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+	recovery *syntaxRecovery,
 ) (common.ParseTreeNode, error) {
 	// I1 -> if R { I } I4
 	childIf := common.ParseTreeNode{
 		InnerToken: common.Token{
 			TokenKind: common.TokenIf,
 			Token:     "if",
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
@@ -464,15 +731,15 @@ func parseIf(
 	}
 	childOpenCurly := common.ParseTreeNode{
 		InnerToken: common.Token{
-			TokenKind:  currentPointer.TokenKind,
-			Token:      currentPointer.Token,
-			LineNumber: currentPointer.LineNumber,
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
 
 	*currentPointer = movePointerToNextToken(input)
-	childI, err := parseProgram(input, currentPointer)
+	childI, err := parseProgram(input, currentPointer, recovery)
 	if err != nil {
 		return common.ParseTreeNode{}, err
 	}
@@ -485,15 +752,15 @@ func parseIf(
 	}
 	childCloseCurly := common.ParseTreeNode{
 		InnerToken: common.Token{
-			TokenKind:  currentPointer.TokenKind,
-			Token:      currentPointer.Token,
-			LineNumber: currentPointer.LineNumber,
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
 
 	*currentPointer = movePointerToNextToken(input)
-	childI4, err := parseElseCondition(input, currentPointer)
+	childI4, err := parseElseCondition(input, currentPointer, recovery)
 	if err != nil {
 		return common.ParseTreeNode{}, err
 	}
@@ -517,6 +784,7 @@ func parseIf(
 func parseElseCondition(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+	recovery *syntaxRecovery,
 ) (common.ParseTreeNode, error) {
 	switch currentPointer.TokenKind {
 	case common.TokenElse:
@@ -525,12 +793,13 @@ func parseElseCondition(
 			InnerToken: common.Token{
 				TokenKind: common.TokenElse,
 				Token:     "else",
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
 
 		*currentPointer = movePointerToNextToken(input)
-		childI7, err := parseElseIf(input, currentPointer)
+		childI7, err := parseElseIf(input, currentPointer, recovery)
 		if err != nil {
 			return common.ParseTreeNode{}, err
 		}
@@ -567,6 +836,7 @@ func parseElseCondition(
 func parseElseIf(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+	recovery *syntaxRecovery,
 ) (common.ParseTreeNode, error) {
 	switch currentPointer.TokenKind {
 	case common.TokenIf:
@@ -575,6 +845,7 @@ func parseElseIf(
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -592,15 +863,15 @@ func parseElseIf(
 		}
 		childOpenCurly := common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  currentPointer.TokenKind,
-				Token:      currentPointer.Token,
-				LineNumber: currentPointer.LineNumber,
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
 
 		*currentPointer = movePointerToNextToken(input)
-		childI, err := parseProgram(input, currentPointer)
+		childI, err := parseProgram(input, currentPointer, recovery)
 		if err != nil {
 			return common.ParseTreeNode{}, err
 		}
@@ -612,15 +883,15 @@ func parseElseIf(
 		}
 		childCloseCurly := common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  currentPointer.TokenKind,
-				Token:      currentPointer.Token,
-				LineNumber: currentPointer.LineNumber,
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
 
 		*currentPointer = movePointerToNextToken(input)
-		childI4, err := parseElseCondition(input, currentPointer)
+		childI4, err := parseElseCondition(input, currentPointer, recovery)
 
 		return common.ParseTreeNode{
 			InnerToken: common.Token{
@@ -641,15 +912,15 @@ func parseElseIf(
 		// I7 -> { I }
 		childOpenCurly := common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  currentPointer.TokenKind,
-				Token:      currentPointer.Token,
-				LineNumber: currentPointer.LineNumber,
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
 
 		*currentPointer = movePointerToNextToken(input)
-		childI, err := parseProgram(input, currentPointer)
+		childI, err := parseProgram(input, currentPointer, recovery)
 		if err != nil {
 			return common.ParseTreeNode{}, err
 		}
@@ -662,9 +933,9 @@ func parseElseIf(
 		}
 		childCloseCurly := common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  currentPointer.TokenKind,
-				Token:      currentPointer.Token,
-				LineNumber: currentPointer.LineNumber,
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -693,12 +964,14 @@ func parseElseIf(
 func parseWhile(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+	recovery *syntaxRecovery,
 ) (common.ParseTreeNode, error) {
 	// I1 -> while R { I }
 	childWhile := common.ParseTreeNode{
 		InnerToken: common.Token{
 			TokenKind: common.TokenWhile,
 			Token:     "while",
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
@@ -716,15 +989,15 @@ func parseWhile(
 	}
 	childOpenCurly := common.ParseTreeNode{
 		InnerToken: common.Token{
-			TokenKind:  currentPointer.TokenKind,
-			Token:      currentPointer.Token,
-			LineNumber: currentPointer.LineNumber,
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
 
 	*currentPointer = movePointerToNextToken(input)
-	childI, err := parseProgram(input, currentPointer)
+	childI, err := parseProgram(input, currentPointer, recovery)
 	if err != nil {
 		return common.ParseTreeNode{}, err
 	}
@@ -737,9 +1010,9 @@ func parseWhile(
 	}
 	childCloseCurly := common.ParseTreeNode{
 		InnerToken: common.Token{
-			TokenKind:  currentPointer.TokenKind,
-			Token:      currentPointer.Token,
-			LineNumber: currentPointer.LineNumber,
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
@@ -761,132 +1034,393 @@ func parseWhile(
 	}, nil
 }
 
-func parsePrintf(
+func parseFor(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+	recovery *syntaxRecovery,
 ) (common.ParseTreeNode, error) {
-	// I1 -> printf(str C)
-	childOutput := common.ParseTreeNode{
+	// I1 -> for v in R..R S { I } | for v in R { I }
+	childFor := common.ParseTreeNode{
 		InnerToken: common.Token{
-			TokenKind: common.TokenOutput,
-			Token:     "printf",
+			TokenKind: common.TokenFor,
+			Token:     "for",
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
 
 	*currentPointer = movePointerToNextToken(input)
-	if currentPointer.TokenKind != common.TokenOpenParanthesis {
+	if currentPointer.TokenKind != common.TokenIdent {
 		return common.ParseTreeNode{}, parserError(
-			"Open paranthesis expected after printf.\n"+
-				"\tprintf is a function call now",
+			"identifier expected after for",
 			currentPointer,
 		)
 	}
+	childIdentifier := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
 
 	*currentPointer = movePointerToNextToken(input)
-	if currentPointer.TokenKind != common.TokenLiteralString {
+	if currentPointer.TokenKind != common.TokenIn {
 		return common.ParseTreeNode{}, parserError(
-			"string literal expected after printf",
+			"'in' expected after the loop variable",
 			currentPointer,
 		)
 	}
-	childStr := common.ParseTreeNode{
+	childIn := common.ParseTreeNode{
 		InnerToken: common.Token{
-			TokenKind: common.TokenLiteralString,
+			TokenKind: currentPointer.TokenKind,
 			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
 		},
 		ChildNodes: []common.ParseTreeNode{},
 	}
 
 	*currentPointer = movePointerToNextToken(input)
-	childC, err := parsePrintfContinuation(input, currentPointer)
+	childR, err := parseR(input, currentPointer)
 	if err != nil {
 		return common.ParseTreeNode{}, err
 	}
 
-	if currentPointer.TokenKind != common.TokenCloseParanthesis {
+	label := "I1>for v in R {I}"
+	childNodes := []common.ParseTreeNode{childFor, childIdentifier, childIn, childR}
+	if currentPointer.TokenKind == common.TokenRange {
+		// R..R S or R..=R S
+		label = "I1>for v in R..R S {I}"
+		childRange := common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		childEnd, err := parseR(input, currentPointer)
+		if err != nil {
+			return common.ParseTreeNode{}, err
+		}
+		childS, err := parseStep(input, currentPointer)
+		if err != nil {
+			return common.ParseTreeNode{}, err
+		}
+		childNodes = append(childNodes, childRange, childEnd, childS)
+	}
+
+	if currentPointer.TokenKind != common.TokenOpenCurly {
 		return common.ParseTreeNode{}, parserError(
-			"Closing paranthesis expected after printf",
+			"'{' expected",
 			currentPointer,
 		)
 	}
+	childOpenCurly := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
 
 	*currentPointer = movePointerToNextToken(input)
-	outputBlock := common.ParseTreeNode{
+	childI, err := parseProgram(input, currentPointer, recovery)
+	if err != nil {
+		return common.ParseTreeNode{}, err
+	}
+
+	if currentPointer.TokenKind != common.TokenCloseCurly {
+		return common.ParseTreeNode{}, parserError(
+			"'}' expected",
+			currentPointer,
+		)
+	}
+	childCloseCurly := common.ParseTreeNode{
 		InnerToken: common.Token{
-			TokenKind: common.TokenBlock,
-			Token:     "I1>output (str C)",
-		},
-		ChildNodes: []common.ParseTreeNode{
-			childOutput,
-			childStr,
-			childC,
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
 		},
+		ChildNodes: []common.ParseTreeNode{},
 	}
-	return outputBlock, nil
+
+	*currentPointer = movePointerToNextToken(input)
+
+	return common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     label,
+		},
+		ChildNodes: append(childNodes, childOpenCurly, childI, childCloseCurly),
+	}, nil
 }
 
-func parsePrintfContinuation(
+func parseStep(
 	input <-chan common.Token,
 	currentPointer *common.Token,
 ) (common.ParseTreeNode, error) {
 	switch currentPointer.TokenKind {
-	case common.TokenCloseParanthesis:
-		return common.ParseTreeNode{
+	case common.TokenStep:
+		// S -> step R
+		childStep := common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind: common.TokenBlock,
-				Token:     "C",
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
-		}, nil
+		}
 
-	case common.TokenComma:
 		*currentPointer = movePointerToNextToken(input)
 		childR, err := parseR(input, currentPointer)
 		if err != nil {
 			return common.ParseTreeNode{}, err
 		}
-		childC, err := parsePrintfContinuation(input, currentPointer)
 		return common.ParseTreeNode{
 			InnerToken: common.Token{
 				TokenKind: common.TokenBlock,
-				Token:     "C>,R C",
-			},
-			ChildNodes: []common.ParseTreeNode{
-				childR,
-				childC,
+				Token:     "S>step R",
 			},
-		}, err
+			ChildNodes: []common.ParseTreeNode{childStep, childR},
+		}, nil
 
-	default:
-		return common.ParseTreeNode{}, parserError(
-			"',' or ')' expected",
-			currentPointer,
-		)
+	case common.TokenOpenCurly:
+		// S -> epsilon
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "S",
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}, nil
 	}
+	return common.ParseTreeNode{}, parserError(
+		"'step' or '{' expected after the range",
+		currentPointer,
+	)
 }
 
-func parseR(
+func parsePrintf(
 	input <-chan common.Token,
 	currentPointer *common.Token,
 ) (common.ParseTreeNode, error) {
-	switch currentPointer.TokenKind {
-	case common.TokenOpenSquareBraces:
-		fallthrough
-	case common.TokenIdent:
-		fallthrough
-	case common.TokenLiteralInt:
-		fallthrough
-	case common.TokenLiteralBool:
+	// I1 -> printf(str C) or print(K) or println(K)
+	childOutput := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenOutput,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	if currentPointer.TokenKind != common.TokenOpenParanthesis && childOutput.InnerToken.Token != "printf" {
+		return common.ParseTreeNode{}, parserError(
+			"Open paranthesis expected after "+childOutput.InnerToken.Token,
+			currentPointer,
+		)
+	}
+	if currentPointer.TokenKind != common.TokenOpenParanthesis {
+		return common.ParseTreeNode{}, parserError(
+			"Open paranthesis expected after printf.\n"+
+				"\tprintf is a function call now",
+			currentPointer,
+		)
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	if childOutput.InnerToken.Token != "printf" {
+		return parsePrint(input, currentPointer, childOutput)
+	}
+	if currentPointer.TokenKind != common.TokenLiteralString {
+		return common.ParseTreeNode{}, parserError(
+			"string literal expected after printf",
+			currentPointer,
+		)
+	}
+	childStr := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenLiteralString,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	childC, err := parsePrintfContinuation(input, currentPointer)
+	if err != nil {
+		return common.ParseTreeNode{}, err
+	}
+
+	if currentPointer.TokenKind != common.TokenCloseParanthesis {
+		return common.ParseTreeNode{}, parserError(
+			"Closing paranthesis expected after printf",
+			currentPointer,
+		)
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	outputBlock := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     "I1>output (str C)",
+		},
+		ChildNodes: []common.ParseTreeNode{
+			childOutput,
+			childStr,
+			childC,
+		},
+	}
+	return outputBlock, nil
+}
+
+func parsePrint(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+	childOutput common.ParseTreeNode,
+) (common.ParseTreeNode, error) {
+	// I1 -> print(K)
+	childK, err := parsePrintArguments(input, currentPointer, "K")
+	if err != nil {
+		return common.ParseTreeNode{}, err
+	}
+
+	if currentPointer.TokenKind != common.TokenCloseParanthesis {
+		return common.ParseTreeNode{}, parserError(
+			"Closing paranthesis expected after "+childOutput.InnerToken.Token,
+			currentPointer,
+		)
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	return common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     "I1>output (K)",
+		},
+		ChildNodes: []common.ParseTreeNode{
+			childOutput,
+			childK,
+		},
+	}, nil
+}
+
+// K -> R K1, and K1 -> , R K1
+func parsePrintArguments(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+	label string,
+) (common.ParseTreeNode, error) {
+	if currentPointer.TokenKind == common.TokenCloseParanthesis {
+		// K -> epsilon
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     label,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}, nil
+	}
+	if label == "K1" {
+		if currentPointer.TokenKind != common.TokenComma {
+			return common.ParseTreeNode{}, parserError(
+				"',' or ')' expected",
+				currentPointer,
+			)
+		}
+		*currentPointer = movePointerToNextToken(input)
+	}
+
+	childR, err := parseR(input, currentPointer)
+	if err != nil {
+		return common.ParseTreeNode{}, err
+	}
+
+	childK1, err := parsePrintArguments(input, currentPointer, "K1")
+	return common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     label,
+		},
+		ChildNodes: []common.ParseTreeNode{
+			childR,
+			childK1,
+		},
+	}, err
+}
+
+func parsePrintfContinuation(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	switch currentPointer.TokenKind {
+	case common.TokenCloseParanthesis:
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "C",
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}, nil
+
+	case common.TokenComma:
+		*currentPointer = movePointerToNextToken(input)
+		childR, err := parseR(input, currentPointer)
+		if err != nil {
+			return common.ParseTreeNode{}, err
+		}
+		childC, err := parsePrintfContinuation(input, currentPointer)
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "C>,R C",
+			},
+			ChildNodes: []common.ParseTreeNode{
+				childR,
+				childC,
+			},
+		}, err
+
+	default:
+		return common.ParseTreeNode{}, parserError(
+			"',' or ')' expected",
+			currentPointer,
+		)
+	}
+}
+
+func parseR(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	switch currentPointer.TokenKind {
+	case common.TokenOpenSquareBraces:
+		fallthrough
+	case common.TokenIdent:
+		fallthrough
+	case common.TokenLiteralInt:
+		fallthrough
+	case common.TokenLiteralBool:
 		fallthrough
 	case common.TokenLiteralChar:
 		fallthrough
 	case common.TokenLiteralFloat:
 		fallthrough
+	case common.TokenLiteralString:
+		fallthrough
 	case common.TokenOpenParanthesis:
 		fallthrough
 	case common.TokenInput:
 		fallthrough
+	case common.TokenLength:
+		fallthrough
 	case common.TokenNot:
 		fallthrough
 	case common.TokenExpressionSub:
@@ -929,6 +1463,7 @@ func parseRz(
 			InnerToken: common.Token{
 				TokenKind: common.TokenOr,
 				Token:     "||",
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -958,6 +1493,10 @@ func parseRz(
 
 	case common.TokenOpenCurly:
 		fallthrough
+	case common.TokenRange:
+		fallthrough
+	case common.TokenStep:
+		fallthrough
 	case common.TokenCloseParanthesis:
 		fallthrough
 	case common.TokenCloseSquareBraces:
@@ -998,10 +1537,14 @@ func parseRa(
 		fallthrough
 	case common.TokenLiteralFloat:
 		fallthrough
+	case common.TokenLiteralString:
+		fallthrough
 	case common.TokenOpenParanthesis:
 		fallthrough
 	case common.TokenInput:
 		fallthrough
+	case common.TokenLength:
+		fallthrough
 	case common.TokenNot:
 		fallthrough
 	case common.TokenExpressionSub:
@@ -1044,6 +1587,7 @@ func parseRy(
 			InnerToken: common.Token{
 				TokenKind: common.TokenAnd,
 				Token:     "&&",
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -1073,6 +1617,10 @@ func parseRy(
 
 	case common.TokenOpenCurly:
 		fallthrough
+	case common.TokenRange:
+		fallthrough
+	case common.TokenStep:
+		fallthrough
 	case common.TokenCloseParanthesis:
 		fallthrough
 	case common.TokenCloseSquareBraces:
@@ -1108,6 +1656,7 @@ func parseRb(
 			InnerToken: common.Token{
 				TokenKind: common.TokenNot,
 				Token:     "!",
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -1157,8 +1706,12 @@ func parseRb(
 		fallthrough
 	case common.TokenLiteralFloat:
 		fallthrough
+	case common.TokenLiteralString:
+		fallthrough
 	case common.TokenInput:
 		fallthrough
+	case common.TokenLength:
+		fallthrough
 	case common.TokenExpressionSub:
 		fallthrough
 	case common.TokenOpenParanthesis:
@@ -1209,6 +1762,7 @@ func parseR1(
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -1234,6 +1788,10 @@ func parseR1(
 		fallthrough
 	case common.TokenOpenCurly:
 		fallthrough
+	case common.TokenRange:
+		fallthrough
+	case common.TokenStep:
+		fallthrough
 	case common.TokenCloseSquareBraces:
 		fallthrough
 	case common.TokenComma:
@@ -1301,6 +1859,10 @@ func parseE1(
 		fallthrough
 	case common.TokenOpenCurly:
 		fallthrough
+	case common.TokenRange:
+		fallthrough
+	case common.TokenStep:
+		fallthrough
 	case common.TokenCloseSquareBraces:
 		fallthrough
 	case common.TokenComma:
@@ -1323,6 +1885,7 @@ func parseE1(
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -1401,6 +1964,10 @@ func parseT1(
 		fallthrough
 	case common.TokenOpenCurly:
 		fallthrough
+	case common.TokenRange:
+		fallthrough
+	case common.TokenStep:
+		fallthrough
 	case common.TokenCloseSquareBraces:
 		fallthrough
 	case common.TokenComma:
@@ -1429,6 +1996,7 @@ func parseT1(
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -1463,14 +2031,67 @@ func parseT1(
 func parseF(
 	input <-chan common.Token,
 	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	childF, err := parseFactor(input, currentPointer)
+	if err != nil {
+		return common.ParseTreeNode{}, err
+	}
+
+	// F -> F as type, which may be repeated, e.g., x as int as char
+	for currentPointer.TokenKind == common.TokenAs {
+		childAs := common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		if currentPointer.TokenKind != common.TokenDatatype {
+			return common.ParseTreeNode{}, parserError(
+				"as should be followed by a datatype",
+				currentPointer,
+			)
+		}
+		childDatatype := common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		childF = common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "F>F as type",
+			},
+			ChildNodes: []common.ParseTreeNode{
+				childF,
+				childAs,
+				childDatatype,
+			},
+		}
+	}
+	return childF, nil
+}
+
+// F without the casts after it
+func parseFactor(
+	input <-chan common.Token,
+	currentPointer *common.Token,
 ) (common.ParseTreeNode, error) {
 	switch currentPointer.TokenKind {
 	case common.TokenOpenSquareBraces:
 		childOpenSquareBraces := common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  currentPointer.TokenKind,
-				Token:      currentPointer.Token,
-				LineNumber: currentPointer.LineNumber,
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -1489,9 +2110,9 @@ func parseF(
 		}
 		childCloseSquareBraces := common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  currentPointer.TokenKind,
-				Token:      currentPointer.Token,
-				LineNumber: currentPointer.LineNumber,
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -1499,9 +2120,8 @@ func parseF(
 
 		return common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  common.TokenBlock,
-				Token:      "F>[L]",
-				LineNumber: childOpenSquareBraces.InnerToken.LineNumber,
+				TokenKind: common.TokenBlock,
+				Token:     "F>[L]",
 			},
 			ChildNodes: []common.ParseTreeNode{
 				childOpenSquareBraces,
@@ -1513,22 +2133,26 @@ func parseF(
 	case common.TokenIdent:
 		childIdentifier := common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  currentPointer.TokenKind,
-				Token:      currentPointer.Token,
-				LineNumber: currentPointer.LineNumber,
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
 		*currentPointer = movePointerToNextToken(input)
+		if currentPointer.TokenKind == common.TokenOpenParanthesis {
+			// F -> v(K)
+			return parseCall(input, currentPointer, childIdentifier)
+		}
+
 		childArrayUsage, err := parseArrayUsage(input, currentPointer)
 		if err != nil {
 			return common.ParseTreeNode{}, err
 		}
 		return common.ParseTreeNode{
 			InnerToken: common.Token{
-				TokenKind:  common.TokenBlock,
-				Token:      "F",
-				LineNumber: childIdentifier.InnerToken.LineNumber,
+				TokenKind: common.TokenBlock,
+				Token:     "F",
 			},
 			ChildNodes: []common.ParseTreeNode{
 				childIdentifier,
@@ -1543,10 +2167,13 @@ func parseF(
 	case common.TokenLiteralChar:
 		fallthrough
 	case common.TokenLiteralFloat:
+		fallthrough
+	case common.TokenLiteralString:
 		child := common.ParseTreeNode{
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -1567,6 +2194,7 @@ func parseF(
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
@@ -1598,7 +2226,24 @@ func parseF(
 			},
 		}, nil
 
-	case common.TokenOpenParanthesis:
+	case common.TokenLength:
+		childLength := common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		if currentPointer.TokenKind != common.TokenOpenParanthesis {
+			return common.ParseTreeNode{}, parserError(
+				"len is a function and must be followed by an open paranthesis",
+				currentPointer,
+			)
+		}
+
 		*currentPointer = movePointerToNextToken(input)
 		childR, err := parseR(input, currentPointer)
 		if err != nil {
@@ -1606,13 +2251,47 @@ func parseF(
 		}
 		if currentPointer.TokenKind != common.TokenCloseParanthesis {
 			return common.ParseTreeNode{}, parserError(
-				"')' expected",
+				"len expects a single argument, followed by ')'",
 				currentPointer,
 			)
 		}
-
-		*currentPointer = movePointerToNextToken(input)
-		return common.ParseTreeNode{
+		childCloseParanthesis := common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "F>len(R)",
+			},
+			ChildNodes: []common.ParseTreeNode{
+				childLength,
+				childR,
+				childCloseParanthesis,
+			},
+		}, nil
+
+	case common.TokenOpenParanthesis:
+		*currentPointer = movePointerToNextToken(input)
+		childR, err := parseR(input, currentPointer)
+		if err != nil {
+			return common.ParseTreeNode{}, err
+		}
+		if currentPointer.TokenKind != common.TokenCloseParanthesis {
+			return common.ParseTreeNode{}, parserError(
+				"')' expected",
+				currentPointer,
+			)
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		return common.ParseTreeNode{
 			InnerToken: common.Token{
 				TokenKind: common.TokenBlock,
 				Token:     "F>(R)",
@@ -1627,12 +2306,14 @@ func parseF(
 			InnerToken: common.Token{
 				TokenKind: currentPointer.TokenKind,
 				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
 			},
 			ChildNodes: []common.ParseTreeNode{},
 		}
 
 		*currentPointer = movePointerToNextToken(input)
-		childF, err := parseF(input, currentPointer)
+		// -x as int casts -x, rather than negating x as int
+		childF, err := parseFactor(input, currentPointer)
 		return common.ParseTreeNode{
 			InnerToken: common.Token{
 				TokenKind: common.TokenBlock,
@@ -1679,10 +2360,14 @@ func parseArrayExpression(
 		fallthrough
 	case common.TokenLiteralFloat:
 		fallthrough
+	case common.TokenLiteralString:
+		fallthrough
 	case common.TokenOpenParanthesis:
 		fallthrough
 	case common.TokenInput:
 		fallthrough
+	case common.TokenLength:
+		fallthrough
 	case common.TokenNot:
 		fallthrough
 	case common.TokenExpressionSub:
@@ -1757,28 +2442,711 @@ func parseArrayContinuation(
 	}
 }
 
-func movePointerToNextToken(input <-chan common.Token) common.Token {
-	currentPointer, ok := <-input
-	if !ok {
-		return common.Token{
-			TokenKind: common.TokenError,
-			Token:     "",
+func parseFunction(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+	recovery *syntaxRecovery,
+) (common.ParseTreeNode, error) {
+	// I1 -> fn v(P) Q { I }
+	childFunction := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenFunction,
+			Token:     "fn",
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	if currentPointer.TokenKind != common.TokenIdent {
+		return common.ParseTreeNode{}, parserError(
+			"function name expected after fn",
+			currentPointer,
+		)
+	}
+	childIdent := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+	recovery.declare(childIdent)
+
+	*currentPointer = movePointerToNextToken(input)
+	if currentPointer.TokenKind != common.TokenOpenParanthesis {
+		return common.ParseTreeNode{}, parserError(
+			"'(' expected after function name",
+			currentPointer,
+		)
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	childP, err := parseParameters(input, currentPointer)
+	if err != nil {
+		return common.ParseTreeNode{}, err
+	}
+	if currentPointer.TokenKind != common.TokenCloseParanthesis {
+		return common.ParseTreeNode{}, parserError(
+			"')' expected after function parameters",
+			currentPointer,
+		)
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	childQ, err := parseReturnType(input, currentPointer)
+	if err != nil {
+		return common.ParseTreeNode{}, err
+	}
+
+	if currentPointer.TokenKind != common.TokenOpenCurly {
+		return common.ParseTreeNode{}, parserError(
+			"'{' expected",
+			currentPointer,
+		)
+	}
+	childOpenCurly := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	childI, err := parseProgram(input, currentPointer, recovery)
+	if err != nil {
+		return common.ParseTreeNode{}, err
+	}
+
+	if currentPointer.TokenKind != common.TokenCloseCurly {
+		return common.ParseTreeNode{}, parserError(
+			"'}' expected",
+			currentPointer,
+		)
+	}
+	childCloseCurly := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+
+	return common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     "I1>fn v(P) Q {I}",
+		},
+		ChildNodes: []common.ParseTreeNode{
+			childFunction,
+			childIdent,
+			childP,
+			childQ,
+			childOpenCurly,
+			childI,
+			childCloseCurly,
+		},
+	}, nil
+}
+
+func parseParameters(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	switch currentPointer.TokenKind {
+	case common.TokenCloseParanthesis:
+		// P -> epsilon
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "P",
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}, nil
+
+	case common.TokenIdent:
+		// P -> v : type P1
+		return parseParameter(input, currentPointer)
+
+	default:
+		return common.ParseTreeNode{}, parserError(
+			"parameter or ')' expected",
+			currentPointer,
+		)
+	}
+}
+
+func parseParametersContinuation(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	switch currentPointer.TokenKind {
+	case common.TokenCloseParanthesis:
+		// P1 -> epsilon
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "P1",
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}, nil
+
+	case common.TokenComma:
+		// P1 -> , v : type P1
+		*currentPointer = movePointerToNextToken(input)
+		if currentPointer.TokenKind != common.TokenIdent {
+			return common.ParseTreeNode{}, parserError(
+				"parameter expected after ','",
+				currentPointer,
+			)
 		}
+		return parseParameter(input, currentPointer)
+
+	default:
+		return common.ParseTreeNode{}, parserError(
+			"',' or ')' expected",
+			currentPointer,
+		)
 	}
-	return currentPointer
 }
 
-func parserError(
-	message string,
+// parses v : type P1, with the current pointer at v
+func parseParameter(
+	input <-chan common.Token,
 	currentPointer *common.Token,
-) *common.CompilationError {
+) (common.ParseTreeNode, error) {
+	childIdent := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	if currentPointer.TokenKind != common.TokenColon {
+		return common.ParseTreeNode{}, parserError(
+			"':' expected after parameter name",
+			currentPointer,
+		)
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	if currentPointer.TokenKind != common.TokenDatatype {
+		return common.ParseTreeNode{}, parserError(
+			"datatype expected after ':'",
+			currentPointer,
+		)
+	}
+	childDatatype := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	childP1, err := parseParametersContinuation(input, currentPointer)
+	return common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     "P>v:type P1",
+		},
+		ChildNodes: []common.ParseTreeNode{
+			childIdent,
+			childDatatype,
+			childP1,
+		},
+	}, err
+}
+
+func parseReturnType(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	switch currentPointer.TokenKind {
+	case common.TokenOpenCurly:
+		// Q -> epsilon
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "Q",
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}, nil
+
+	case common.TokenArrow:
+		// Q -> -> type
+		*currentPointer = movePointerToNextToken(input)
+		if currentPointer.TokenKind != common.TokenDatatype {
+			return common.ParseTreeNode{}, parserError(
+				"datatype expected after '->'",
+				currentPointer,
+			)
+		}
+		childDatatype := common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: currentPointer.TokenKind,
+				Token:     currentPointer.Token,
+				Span:      currentPointer.Span,
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}
+
+		*currentPointer = movePointerToNextToken(input)
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "Q>->type",
+			},
+			ChildNodes: []common.ParseTreeNode{
+				childDatatype,
+			},
+		}, nil
+
+	default:
+		return common.ParseTreeNode{}, parserError(
+			"'->' or '{' expected",
+			currentPointer,
+		)
+	}
+}
+
+func parseLoopJump(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	// I1 -> break | break v | continue | continue v
+	childJump := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	if currentPointer.TokenKind != common.TokenIdent {
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "I1>" + childJump.InnerToken.Token,
+			},
+			ChildNodes: []common.ParseTreeNode{
+				childJump,
+			},
+		}, nil
+	}
+
+	childLabel := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	return common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     "I1>" + childJump.InnerToken.Token + " v",
+		},
+		ChildNodes: []common.ParseTreeNode{
+			childJump,
+			childLabel,
+		},
+	}, nil
+}
+
+// parses the loop after v:, with the current pointer at the :
+func parseLabelledLoop(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+	childIdent common.ParseTreeNode,
+	recovery *syntaxRecovery,
+) (common.ParseTreeNode, error) {
+	// I1 -> v: while R { I } | v: for ...
+	childColon := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	var childLoop common.ParseTreeNode
+	var err error
+	switch currentPointer.TokenKind {
+	case common.TokenWhile:
+		childLoop, err = parseWhile(input, currentPointer, recovery)
+	case common.TokenFor:
+		childLoop, err = parseFor(input, currentPointer, recovery)
+	default:
+		return common.ParseTreeNode{}, parserError(
+			"loop expected after the label",
+			currentPointer,
+		)
+	}
+	if err != nil {
+		return common.ParseTreeNode{}, err
+	}
+
+	return common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     "I1>v: I9",
+		},
+		ChildNodes: []common.ParseTreeNode{
+			childIdent,
+			childColon,
+			childLoop,
+		},
+	}, nil
+}
+
+func parseReturn(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	// I1 -> return Z
+	childReturn := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenReturn,
+			Token:     "return",
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	if currentPointer.TokenKind == common.TokenLineEnd {
+		// Z -> epsilon
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "I1>return",
+			},
+			ChildNodes: []common.ParseTreeNode{
+				childReturn,
+			},
+		}, nil
+	}
+
+	// Z -> R
+	childR, err := parseR(input, currentPointer)
+	return common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     "I1>return R",
+		},
+		ChildNodes: []common.ParseTreeNode{
+			childReturn,
+			childR,
+		},
+	}, err
+}
+
+// parses (K) for a function call, with the current pointer at (
+func parseCall(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+	childIdent common.ParseTreeNode,
+) (common.ParseTreeNode, error) {
+	childOpenParanthesis := common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: currentPointer.TokenKind,
+			Token:     currentPointer.Token,
+			Span:      currentPointer.Span,
+		},
+		ChildNodes: []common.ParseTreeNode{},
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	childK, err := parseArguments(input, currentPointer)
+	if err != nil {
+		return common.ParseTreeNode{}, err
+	}
+
+	if currentPointer.TokenKind != common.TokenCloseParanthesis {
+		return common.ParseTreeNode{}, parserError(
+			"')' expected after function arguments",
+			currentPointer,
+		)
+	}
+
+	*currentPointer = movePointerToNextToken(input)
+	return common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     "F>v(K)",
+		},
+		ChildNodes: []common.ParseTreeNode{
+			childIdent,
+			childOpenParanthesis,
+			childK,
+		},
+	}, nil
+}
+
+func parseArguments(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	switch currentPointer.TokenKind {
+	case common.TokenCloseParanthesis:
+		// K -> epsilon
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "K",
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}, nil
+
+	case common.TokenOpenSquareBraces:
+		fallthrough
+	case common.TokenIdent:
+		fallthrough
+	case common.TokenLiteralInt:
+		fallthrough
+	case common.TokenLiteralBool:
+		fallthrough
+	case common.TokenLiteralChar:
+		fallthrough
+	case common.TokenLiteralFloat:
+		fallthrough
+	case common.TokenLiteralString:
+		fallthrough
+	case common.TokenOpenParanthesis:
+		fallthrough
+	case common.TokenInput:
+		fallthrough
+	case common.TokenLength:
+		fallthrough
+	case common.TokenNot:
+		fallthrough
+	case common.TokenExpressionSub:
+		// K -> R K1
+		childR, err := parseR(input, currentPointer)
+		if err != nil {
+			return common.ParseTreeNode{}, err
+		}
+		childArgumentsContinuation, err := parseArgumentsContinuation(input, currentPointer)
+		if err != nil {
+			return common.ParseTreeNode{}, err
+		}
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "K",
+			},
+			ChildNodes: []common.ParseTreeNode{
+				childR,
+				childArgumentsContinuation,
+			},
+		}, nil
+
+	default:
+		return common.ParseTreeNode{}, parserError(
+			"unexpected token in function arguments",
+			currentPointer,
+		)
+	}
+}
+
+func parseArgumentsContinuation(
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) (common.ParseTreeNode, error) {
+	switch currentPointer.TokenKind {
+	case common.TokenComma:
+		// K1 -> , R K1
+		*currentPointer = movePointerToNextToken(input)
+		childR, err := parseR(input, currentPointer)
+		if err != nil {
+			return common.ParseTreeNode{}, err
+		}
+		childArgumentsContinuation, err := parseArgumentsContinuation(input, currentPointer)
+		if err != nil {
+			return common.ParseTreeNode{}, err
+		}
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "K1",
+			},
+			ChildNodes: []common.ParseTreeNode{
+				childR,
+				childArgumentsContinuation,
+			},
+		}, nil
+
+	case common.TokenCloseParanthesis:
+		// K1 -> epsilon
+		return common.ParseTreeNode{
+			InnerToken: common.Token{
+				TokenKind: common.TokenBlock,
+				Token:     "K1",
+			},
+			ChildNodes: []common.ParseTreeNode{},
+		}, nil
+
+	default:
+		return common.ParseTreeNode{}, parserError(
+			"',' or ')' expected",
+			currentPointer,
+		)
+	}
+}
+
+// comments are skipped, since they are only of use to the formatter
+func movePointerToNextToken(input <-chan common.Token) common.Token {
+	for {
+		currentPointer, ok := <-input
+		if !ok {
+			// the lexer closes the channel after the end of file
+			return common.Token{
+				TokenKind: common.TokenEOF,
+				Token:     "Lexer: end of file",
+			}
+		}
+		if currentPointer.TokenKind != common.TokenComment {
+			return currentPointer
+		}
+	}
+}
+
+// the syntax errors found so far
+type syntaxRecovery struct {
+	errors []error
+	// 0 for no limit
+	limit int
+	// the names declared so far by the instruction being parsed
+	declared []common.ParseTreeNode
+}
+
+func (r *syntaxRecovery) declare(identifier common.ParseTreeNode) {
+	r.declared = append(r.declared, identifier)
+}
+
+// returns false once there are too many errors to go on
+func (r *syntaxRecovery) record(err error) bool {
+	if r.limit > 0 && len(r.errors) >= r.limit {
+		return false
+	}
+	r.errors = append(r.errors, err)
+	return r.limit <= 0 || len(r.errors) < r.limit
+}
+
+// Records the error, and skips to the end of the instruction it was found in,
+// that is, past its ';' or up to the '}' closing the block it is in.
+// The braces of blocks inside the instruction are skipped over as a whole.
+func (r *syntaxRecovery) recover(
+	err error,
+	input <-chan common.Token,
+	currentPointer *common.Token,
+) bool {
+	if currentPointer.TokenKind == common.TokenError {
+		err = lexerError(currentPointer)
+	}
+	if !r.record(err) {
+		return false
+	}
+
+	depth := 0
+	for currentPointer.TokenKind != common.TokenEOF {
+		switch currentPointer.TokenKind {
+		case common.TokenLineEnd:
+			if depth == 0 {
+				*currentPointer = movePointerToNextToken(input)
+				return true
+			}
+
+		case common.TokenOpenCurly:
+			depth++
+
+		case common.TokenCloseCurly:
+			if depth == 0 {
+				return true
+			}
+			depth--
+		}
+		*currentPointer = movePointerToNextToken(input)
+	}
+	return true
+}
+
+// An instruction left out for a syntax error is kept as the names it declares, if any,
+// so that their uses later on are not reported as undeclared as well.
+func skippedInstruction(declared []common.ParseTreeNode, program common.ParseTreeNode) common.ParseTreeNode {
+	if len(declared) == 0 {
+		return program
+	}
+	return common.ParseTreeNode{
+		InnerToken: common.Token{
+			TokenKind: common.TokenBlock,
+			Token:     "I>I1;I",
+		},
+		ChildNodes: []common.ParseTreeNode{
+			{
+				InnerToken: common.Token{
+					TokenKind: common.TokenBlock,
+					Token:     "I1>skipped",
+				},
+				ChildNodes: declared,
+			},
+			program,
+		},
+	}
+}
+
+// the second program is put after the last instruction of the first
+func appendProgram(first, second common.ParseTreeNode) common.ParseTreeNode {
+	if len(first.ChildNodes) == 0 {
+		return second
+	}
+	first.ChildNodes = []common.ParseTreeNode{
+		first.ChildNodes[0],
+		appendProgram(first.ChildNodes[1], second),
+	}
+	return first
+}
+
+func lexerError(currentPointer *common.Token) *common.CompilationError {
+	return &common.CompilationError{
+		PointOfFailure: "Lexer",
+		Message:        fmt.Sprintf("invalid token: %v", currentPointer.Token),
+		Span:           currentPointer.Span,
+		Code:           common.CodeInvalidToken,
+	}
+}
+
+func parserError(
+	message string,
+	currentPointer *common.Token,
+) *common.CompilationError {
+	code := common.CodeUnexpectedToken
+	if currentPointer.TokenKind == common.TokenEOF {
+		code = common.CodeUnexpectedEndOfFile
+	}
 	return &common.CompilationError{
 		PointOfFailure: "Parser",
-		Message: fmt.Sprintf(
-			"%v at %v (line number %v)",
-			message,
-			currentPointer.Token,
-			currentPointer.LineNumber,
-		),
+		Message:        fmt.Sprintf("%v at %v", message, currentPointer.Token),
+		Span:           currentPointer.Span,
+		Code:           code,
 	}
 }
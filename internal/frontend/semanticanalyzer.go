@@ -1,23 +1,33 @@
 package frontend
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/SamJohn04/simple-lang-compiler/internal/common"
 )
 
 // converts the Parse Tree to AST
+// Returned when a name is used that was declared by an instruction the parser left out
+// for a syntax error. The syntax error tells of the problem, so this is not to be reported.
+var ErrSkippedDeclaration = errors.New("identifier declared in an instruction with a syntax error")
+
 func SemanticAnalyzer(
 	input common.ParseTreeNode,
 ) (common.ProgramAST, []common.IdentifierInformation, error) {
 	identifiers := []common.IdentifierInformation{}
-	program, identifiers, err := lowerProgram(input, identifiers)
+	program, identifiers, err := lowerProgram(
+		input, identifiers, newScope(nil, -1, common.Span{}),
+	)
 	return program, identifiers, err
 }
 
 func lowerProgram(
 	input common.ParseTreeNode,
 	identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ProgramAST, []common.IdentifierInformation, error) {
 	programAST := common.ProgramAST{
 		Instructions: []common.InstructionAST{},
@@ -44,6 +54,7 @@ func lowerProgram(
 		instructionAST, identifiers, err = lowerInstruction(
 			current.ChildNodes[0],
 			identifiers,
+			currentScope,
 		)
 		if err != nil {
 			return programAST, identifiers, err
@@ -61,35 +72,75 @@ func lowerProgram(
 func lowerInstruction(
 	instruction common.ParseTreeNode,
 	identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.InstructionAST, []common.IdentifierInformation, error) {
 	if len(instruction.ChildNodes) == 0 {
 		return nil, identifiers, semanticInternalError(
 			"instruction structure is not expected to be empty",
 		)
 	}
+	if instruction.InnerToken.Token == "I1>skipped" {
+		for _, childIdentifier := range instruction.ChildNodes {
+			currentScope.skipped[childIdentifier.InnerToken.Token] = true
+		}
+		return nil, identifiers, nil
+	}
 
 	switch instruction.ChildNodes[0].InnerToken.TokenKind {
 	case common.TokenIdent:
+		if instruction.InnerToken.Token == "I1>v: I9" {
+			// loop with a label
+			return lowerLabelledLoop(instruction, identifiers, currentScope)
+		}
+		if len(instruction.ChildNodes) == 3 &&
+			instruction.ChildNodes[1].InnerToken.TokenKind == common.TokenOpenParanthesis {
+			// function call
+			call, err := lowerCall(instruction, identifiers, currentScope)
+			return common.CallStatementAST{
+				Call: call,
+				Span: instruction.Span(),
+			}, identifiers, err
+		}
 		// reassignment
-		return lowerReassignment(instruction, identifiers)
+		return lowerReassignment(instruction, identifiers, currentScope)
 
 	case common.TokenLet:
 		// assignment
-		return lowerAssignment(instruction, identifiers)
+		return lowerAssignment(instruction, identifiers, currentScope)
 
 	case common.TokenIf:
 		// if
-		return lowerIfStatement(instruction, identifiers)
+		return lowerIfStatement(instruction, identifiers, currentScope)
 
 	case common.TokenWhile:
 		// while
-		return lowerWhileStatement(instruction, identifiers)
+		return lowerWhileStatement(instruction, identifiers, currentScope, &common.Loop{})
+
+	case common.TokenFor:
+		// for
+		return lowerForStatement(instruction, identifiers, currentScope, &common.Loop{})
+
+	case common.TokenBreak:
+		fallthrough
+	case common.TokenContinue:
+		// break or continue
+		jump, err := lowerLoopJump(instruction, currentScope)
+		return jump, identifiers, err
 
 	case common.TokenOutput:
 		// output
-		output, err := lowerOutputStatement(instruction, identifiers)
+		output, err := lowerOutputStatement(instruction, identifiers, currentScope)
 		return output, identifiers, err
 
+	case common.TokenFunction:
+		// function declaration
+		return lowerFunction(instruction, identifiers, currentScope)
+
+	case common.TokenReturn:
+		// return
+		returnStatement, err := lowerReturn(instruction, identifiers, currentScope)
+		return returnStatement, identifiers, err
+
 	default:
 		return nil, identifiers, semanticInternalError(
 			fmt.Sprintf(
@@ -103,6 +154,7 @@ func lowerInstruction(
 func lowerReassignment(
 	instruction common.ParseTreeNode,
 	identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.AssignmentAST, []common.IdentifierInformation, error) {
 	if len(instruction.ChildNodes) != 4 {
 		return common.AssignmentAST{}, identifiers, semanticInternalError(
@@ -110,20 +162,27 @@ func lowerReassignment(
 		)
 	}
 	childIdentifier := instruction.ChildNodes[0]
-	index := find(identifiers, childIdentifier.InnerToken.Token)
+	index := find(identifiers, currentScope, childIdentifier.InnerToken.Token)
 	if index < 0 {
-		return common.AssignmentAST{}, identifiers, semanticError(
-			"identifier used before being declared",
+		return common.AssignmentAST{}, identifiers, undeclaredError(
+			currentScope, childIdentifier.InnerToken.Token, childIdentifier.Span(),
 		)
 	}
+	recordUse(identifiers, index, childIdentifier.Span())
 
 	information := identifiers[index]
 	if !information.Mutable {
-		return common.AssignmentAST{}, identifiers, semanticError(
+		mutationError := semanticError(
+			childIdentifier.Span(),
+			common.CodeImmutableAssignment,
 			"identifier that was not declared as mutable being mutated",
 		)
+		return common.AssignmentAST{}, identifiers, withNote(
+			mutationError, information.Declaration,
+			fmt.Sprintf("%v declared here without mut", information.IdentifierName),
+		)
 	}
-	childArrayUsage, err := lowerArrayUsage(instruction.ChildNodes[1], identifiers)
+	childArrayUsage, err := lowerArrayUsage(instruction.ChildNodes[1], identifiers, currentScope)
 	if err != nil {
 		return common.AssignmentAST{}, identifiers, err
 	}
@@ -131,7 +190,7 @@ func lowerReassignment(
 	if childEquals.InnerToken.TokenKind != common.TokenAssignment {
 		return common.AssignmentAST{}, identifiers, semanticInternalError("'=' expected")
 	}
-	childR, err := lowerRelation(instruction.ChildNodes[3], identifiers)
+	childR, err := lowerRelation(instruction.ChildNodes[3], identifiers, currentScope)
 	if err != nil {
 		return common.AssignmentAST{}, identifiers, err
 	}
@@ -140,11 +199,13 @@ func lowerReassignment(
 		AssignToIdentifier: index,
 		ArrayValues:        childArrayUsage,
 		AssignValue:        childR,
+		Span:               instruction.Span(),
 	}, identifiers, nil
 }
 
 func lowerArrayUsage(
 	arrayInstruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) ([]common.ExpressionAST, error) {
 	arrays := []common.ExpressionAST{}
 	for len(arrayInstruction.ChildNodes) > 0 {
@@ -159,7 +220,7 @@ func lowerArrayUsage(
 				"mismatching open and close square braces",
 			)
 		}
-		childE, err := lowerE(arrayInstruction.ChildNodes[1], identifiers)
+		childE, err := lowerE(arrayInstruction.ChildNodes[1], identifiers, currentScope)
 		if err != nil {
 			return []common.ExpressionAST{}, err
 		}
@@ -171,22 +232,41 @@ func lowerArrayUsage(
 
 func lowerAssignment(
 	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.InstructionAST, []common.IdentifierInformation, error) {
 	if len(instruction.ChildNodes) != 2 {
 		return common.AssignmentAST{}, identifiers, semanticInternalError(
 			"let instruction not having 2 length",
 		)
 	}
-	childInstruction, identifiers, err := lowerAssignmentAfterLet(instruction.ChildNodes[1], identifiers)
+	childInstruction, identifiers, err := lowerAssignmentAfterLet(
+		instruction.ChildNodes[1], identifiers, currentScope,
+	)
 	if err != nil {
 		return common.AssignmentAST{}, identifiers, err
 	}
+	// the span starts at the let
+	if assignment, ok := childInstruction.(common.AssignmentAST); ok {
+		assignment.Span = instruction.Span()
+		childInstruction = assignment
+	}
+	if declaration, ok := childInstruction.(common.ArrayDeclarationAST); ok {
+		declaration.Span = instruction.Span()
+		childInstruction = declaration
+	}
 	return childInstruction, identifiers, nil
 }
 
 func lowerAssignmentAfterLet(
 	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.InstructionAST, []common.IdentifierInformation, error) {
+	if instruction.InnerToken.Token == "I6>v:T=R" || instruction.InnerToken.Token == "I6>mut v:T I8" {
+		return lowerAnnotatedAssignment(instruction, identifiers, currentScope)
+	}
+	if instruction.InnerToken.Token == "I6>v:T" {
+		return lowerArrayDeclaration(instruction, identifiers, currentScope)
+	}
 	if len(instruction.ChildNodes) != 3 {
 		return common.AssignmentAST{}, identifiers, semanticInternalError(
 			"instruction after let should have length 3",
@@ -196,38 +276,44 @@ func lowerAssignmentAfterLet(
 	case common.TokenIdent:
 		// v = R
 		childIdentifier := instruction.ChildNodes[0]
-		index := find(identifiers, childIdentifier.InnerToken.Token)
-		if index >= 0 {
-			return nil, identifiers, semanticError("identifier already declared")
+		if currentScope.isDeclared(childIdentifier.InnerToken.Token) {
+			return nil, identifiers, redeclarationError(
+				currentScope, identifiers, childIdentifier,
+			)
 		}
 		if instruction.ChildNodes[1].InnerToken.TokenKind != common.TokenAssignment {
 			return common.AssignmentAST{}, identifiers, semanticInternalError(
 				"instruction after let does not have assignment",
 			)
 		}
-		childR, err := lowerRelation(instruction.ChildNodes[2], identifiers)
+		childR, err := lowerRelation(instruction.ChildNodes[2], identifiers, currentScope)
 		if err != nil {
 			return common.AssignmentAST{}, identifiers, err
 		}
 		identifiers = append(identifiers, common.IdentifierInformation{
 			IdentifierName: childIdentifier.InnerToken.Token,
 			Mutable:        false,
+			Declaration:    childIdentifier.Span(),
+			Scope:          currentScope.span,
 		})
+		currentScope.declare(childIdentifier.InnerToken.Token, len(identifiers)-1)
 		return common.AssignmentAST{
 			AssignToIdentifier: len(identifiers) - 1,
 			ArrayValues:        []common.ExpressionAST{},
 			AssignValue:        childR,
+			Span:               instruction.Span(),
 		}, identifiers, nil
 
 	case common.TokenMutable:
 		// mut v ...
 		childIdentifier := instruction.ChildNodes[1]
-		index := find(identifiers, childIdentifier.InnerToken.Token)
-		if index >= 0 {
-			return nil, identifiers, semanticError("identifier already declared")
+		if currentScope.isDeclared(childIdentifier.InnerToken.Token) {
+			return nil, identifiers, redeclarationError(
+				currentScope, identifiers, childIdentifier,
+			)
 		}
 		childExpression, identifiers, err := lowerMutableAssignment(
-			instruction.ChildNodes[2], childIdentifier, identifiers,
+			instruction.ChildNodes[2], childIdentifier, identifiers, currentScope,
 		)
 		if err != nil {
 			return nil, identifiers, err
@@ -239,15 +325,101 @@ func lowerAssignmentAfterLet(
 	}
 }
 
-func lowerMutableAssignment(
-	instruction, identifier common.ParseTreeNode, identifiers []common.IdentifierInformation,
+// v:T=R or mut v:T I8, which are lowered as without the annotation,
+// and then the declared identifier is given the annotation
+func lowerAnnotatedAssignment(
+	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
+) (common.InstructionAST, []common.IdentifierInformation, error) {
+	if len(instruction.ChildNodes) != 4 {
+		return nil, identifiers, semanticInternalError(
+			"instruction after let with a type should have length 4",
+		)
+	}
+	children := instruction.ChildNodes
+	withoutAnnotation := common.ParseTreeNode{InnerToken: instruction.InnerToken}
+	var childIdentifier, childT common.ParseTreeNode
+	if instruction.InnerToken.Token == "I6>v:T=R" {
+		childIdentifier, childT = children[0], children[1]
+		withoutAnnotation.InnerToken.Token = "I6>v=R"
+		withoutAnnotation.ChildNodes = []common.ParseTreeNode{children[0], children[2], children[3]}
+	} else {
+		childIdentifier, childT = children[1], children[2]
+		withoutAnnotation.InnerToken.Token = "I6>mut v I8"
+		withoutAnnotation.ChildNodes = []common.ParseTreeNode{children[0], children[1], children[3]}
+	}
+
+	annotation, err := lowerType(childT)
+	if err != nil {
+		return nil, identifiers, err
+	}
+	childInstruction, identifiers, err := lowerAssignmentAfterLet(
+		withoutAnnotation, identifiers, currentScope,
+	)
+	if err != nil {
+		return nil, identifiers, err
+	}
+
+	index := currentScope.names[childIdentifier.InnerToken.Token]
+	identifiers[index].Annotation = annotation
+	if _, isArray := annotation.(common.ArrayDatatype); isArray && childInstruction == nil {
+		// the elements of an array declared without a value start as 0
+		identifiers[index].Datatype = annotation
+		return common.ArrayDeclarationAST{Identifier: index, Span: instruction.Span()}, identifiers, nil
+	}
+	return childInstruction, identifiers, nil
+}
+
+// v:T, where only an array can be declared without a value unless it is mut,
+// since its elements start as 0
+func lowerArrayDeclaration(
+	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.InstructionAST, []common.IdentifierInformation, error) {
+	if len(instruction.ChildNodes) != 2 {
+		return nil, identifiers, semanticInternalError(
+			"declaration of an array without a value should have length 2",
+		)
+	}
+	childIdentifier, childT := instruction.ChildNodes[0], instruction.ChildNodes[1]
+	if currentScope.isDeclared(childIdentifier.InnerToken.Token) {
+		return nil, identifiers, redeclarationError(currentScope, identifiers, childIdentifier)
+	}
+	annotation, err := lowerType(childT)
+	if err != nil {
+		return nil, identifiers, err
+	}
+	if _, isArray := annotation.(common.ArrayDatatype); !isArray {
+		return nil, identifiers, semanticError(
+			childIdentifier.Span(), common.CodeImmutableAssignment,
+			fmt.Sprintf(
+				"%v needs a value where it is declared, since it is not mut",
+				childIdentifier.InnerToken.Token,
+			),
+		)
+	}
 	identifiers = append(identifiers, common.IdentifierInformation{
-		IdentifierName: identifier.InnerToken.Token,
-		Mutable:        true,
+		IdentifierName: childIdentifier.InnerToken.Token,
+		Datatype:       annotation,
+		Annotation:     annotation,
+		Mutable:        false,
+		Declaration:    childIdentifier.Span(),
+		Scope:          currentScope.span,
 	})
+	currentScope.declare(childIdentifier.InnerToken.Token, len(identifiers)-1)
+	return common.ArrayDeclarationAST{
+		Identifier: len(identifiers) - 1,
+		Span:       instruction.Span(),
+	}, identifiers, nil
+}
+
+func lowerMutableAssignment(
+	instruction, identifier common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
+) (common.InstructionAST, []common.IdentifierInformation, error) {
 	if len(instruction.ChildNodes) == 0 {
 		// simply declaring the variable
+		identifiers = declareMutable(identifier, identifiers, currentScope)
 		return nil, identifiers, nil
 	}
 	if len(instruction.ChildNodes) != 2 {
@@ -257,19 +429,37 @@ func lowerMutableAssignment(
 	if childEquals.InnerToken.TokenKind != common.TokenAssignment {
 		return nil, identifiers, semanticInternalError("'=' expected")
 	}
-	childExpression, err := lowerRelation(instruction.ChildNodes[1], identifiers)
+	// the value is lowered first, so that it still sees a shadowed identifier of the same name
+	childExpression, err := lowerRelation(instruction.ChildNodes[1], identifiers, currentScope)
 	if err != nil {
 		return nil, identifiers, err
 	}
+	identifiers = declareMutable(identifier, identifiers, currentScope)
 	return common.AssignmentAST{
 		AssignToIdentifier: len(identifiers) - 1,
 		ArrayValues:        []common.ExpressionAST{},
 		AssignValue:        childExpression,
+		Span:               identifier.Span().Join(instruction.Span()),
 	}, identifiers, nil
 }
 
+func declareMutable(
+	identifier common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
+) []common.IdentifierInformation {
+	identifiers = append(identifiers, common.IdentifierInformation{
+		IdentifierName: identifier.InnerToken.Token,
+		Mutable:        true,
+		Declaration:    identifier.Span(),
+		Scope:          currentScope.span,
+	})
+	currentScope.declare(identifier.InnerToken.Token, len(identifiers)-1)
+	return identifiers
+}
+
 func lowerIfStatement(
 	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.IfStatementAST, []common.IdentifierInformation, error) {
 	ifStatement := common.IfStatementAST{
 		IfExpressions: []common.IfExpression{},
@@ -277,6 +467,7 @@ func lowerIfStatement(
 	if len(instruction.ChildNodes) == 0 {
 		return ifStatement, identifiers, semanticInternalError("if does not have children")
 	}
+	ifStatement.Span = instruction.Span()
 	for len(instruction.ChildNodes) > 0 {
 		var childProgram common.ProgramAST
 		var err error
@@ -288,7 +479,7 @@ func lowerIfStatement(
 					"if has unexpected number of children",
 				)
 			}
-			childR, err := lowerRelation(instruction.ChildNodes[1], identifiers)
+			childR, err := lowerRelation(instruction.ChildNodes[1], identifiers, currentScope)
 			if err != nil {
 				return ifStatement, identifiers, err
 			}
@@ -299,7 +490,11 @@ func lowerIfStatement(
 				)
 			}
 
-			childProgram, identifiers, err = lowerProgram(instruction.ChildNodes[3], identifiers)
+			childProgram, identifiers, err = lowerBlock(
+				instruction.ChildNodes[3],
+				instruction.ChildNodes[2].Span().Join(instruction.ChildNodes[4].Span()),
+				identifiers, currentScope, nil,
+			)
 			if err != nil {
 				return ifStatement, identifiers, err
 			}
@@ -319,7 +514,11 @@ func lowerIfStatement(
 					"if does not have closing braces",
 				)
 			}
-			childProgram, identifiers, err = lowerProgram(instruction.ChildNodes[1], identifiers)
+			childProgram, identifiers, err = lowerBlock(
+				instruction.ChildNodes[1],
+				instruction.ChildNodes[0].Span().Join(instruction.ChildNodes[2].Span()),
+				identifiers, currentScope, nil,
+			)
 			if err != nil {
 				return ifStatement, identifiers, err
 			}
@@ -328,6 +527,7 @@ func lowerIfStatement(
 				Condition: common.Literal{
 					Value:    "true",
 					Datatype: common.TypedBool,
+					Span:     instruction.ChildNodes[0].Span(),
 				},
 				Program: childProgram,
 			})
@@ -353,13 +553,14 @@ func lowerIfStatement(
 
 func lowerWhileStatement(
 	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope, loop *common.Loop,
 ) (common.WhileStatementAST, []common.IdentifierInformation, error) {
 	if len(instruction.ChildNodes) != 5 {
 		return common.WhileStatementAST{}, identifiers, semanticInternalError(
 			"unexpected length of while",
 		)
 	}
-	childR, err := lowerRelation(instruction.ChildNodes[1], identifiers)
+	childR, err := lowerRelation(instruction.ChildNodes[1], identifiers, currentScope)
 	if err != nil {
 		return common.WhileStatementAST{}, identifiers, err
 	}
@@ -369,19 +570,170 @@ func lowerWhileStatement(
 			"open or closing brace missing",
 		)
 	}
-	childProgram, identifiers, err := lowerProgram(instruction.ChildNodes[3], identifiers)
+	childProgram, identifiers, err := lowerBlock(
+		instruction.ChildNodes[3],
+		instruction.ChildNodes[2].Span().Join(instruction.ChildNodes[4].Span()),
+		identifiers, currentScope, loop,
+	)
 	if err != nil {
 		return common.WhileStatementAST{}, identifiers, err
 	}
 	return common.WhileStatementAST{
 		Condition: childR,
 		Program:   childProgram,
+		Loop:      loop,
+		Span:      instruction.Span(),
 	}, identifiers, nil
 }
 
+func lowerForStatement(
+	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope, loop *common.Loop,
+) (common.ForStatementAST, []common.IdentifierInformation, error) {
+	forStatement := common.ForStatementAST{Loop: loop, Span: instruction.Span()}
+	children := instruction.ChildNodes
+	if len(children) != 7 && len(children) != 10 {
+		return forStatement, identifiers, semanticInternalError("unexpected length of for")
+	}
+	if children[1].InnerToken.TokenKind != common.TokenIdent ||
+		children[2].InnerToken.TokenKind != common.TokenIn {
+		return forStatement, identifiers, semanticInternalError("loop variable missing in for")
+	}
+
+	// what the loop goes over is lowered outside the body, where the loop variable is not declared yet
+	childR, err := lowerRelation(children[3], identifiers, currentScope)
+	if err != nil {
+		return forStatement, identifiers, err
+	}
+	if len(children) == 7 {
+		// for v in R { I }
+		forStatement.Array = childR
+	} else {
+		// for v in R..R S { I }
+		if children[4].InnerToken.TokenKind != common.TokenRange {
+			return forStatement, identifiers, semanticInternalError("range missing in for")
+		}
+		forStatement.Start = childR
+		forStatement.Inclusive = children[4].InnerToken.Token == "..="
+		forStatement.End, err = lowerRelation(children[5], identifiers, currentScope)
+		if err != nil {
+			return forStatement, identifiers, err
+		}
+		if len(children[6].ChildNodes) == 2 {
+			// S -> step R
+			forStatement.Step, err = lowerRelation(
+				children[6].ChildNodes[1], identifiers, currentScope,
+			)
+			if err != nil {
+				return forStatement, identifiers, err
+			}
+		}
+	}
+
+	openCurly := children[len(children)-3]
+	closeCurly := children[len(children)-1]
+	if openCurly.InnerToken.TokenKind != common.TokenOpenCurly ||
+		closeCurly.InnerToken.TokenKind != common.TokenCloseCurly {
+		return forStatement, identifiers, semanticInternalError("open or closing brace missing")
+	}
+
+	// the loop variable belongs to the body, so that it is not seen after the loop
+	blockScope := newScope(
+		currentScope, currentScope.function, openCurly.Span().Join(closeCurly.Span()),
+	)
+	blockScope.loop = loop
+	identifiers = append(identifiers, common.IdentifierInformation{
+		IdentifierName: children[1].InnerToken.Token,
+		Mutable:        false,
+		Declaration:    children[1].Span(),
+		Scope:          blockScope.span,
+	})
+	forStatement.Variable = len(identifiers) - 1
+	blockScope.declare(children[1].InnerToken.Token, forStatement.Variable)
+
+	forStatement.Program, identifiers, err = lowerProgram(
+		children[len(children)-2], identifiers, blockScope,
+	)
+	blockScope.close()
+	return forStatement, identifiers, err
+}
+
+func lowerLabelledLoop(
+	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
+) (common.InstructionAST, []common.IdentifierInformation, error) {
+	if len(instruction.ChildNodes) != 3 {
+		return nil, identifiers, semanticInternalError("unexpected length of a labelled loop")
+	}
+	loop := &common.Loop{Label: instruction.ChildNodes[0].InnerToken.Token}
+	childLoop := instruction.ChildNodes[2]
+	if len(childLoop.ChildNodes) == 0 {
+		return nil, identifiers, semanticInternalError("labelled loop is empty")
+	}
+	switch childLoop.ChildNodes[0].InnerToken.TokenKind {
+	case common.TokenWhile:
+		return lowerWhileStatement(childLoop, identifiers, currentScope, loop)
+	case common.TokenFor:
+		return lowerForStatement(childLoop, identifiers, currentScope, loop)
+	default:
+		return nil, identifiers, semanticInternalError("label on something other than a loop")
+	}
+}
+
+func lowerLoopJump(
+	instruction common.ParseTreeNode, currentScope *scope,
+) (common.InstructionAST, error) {
+	if len(instruction.ChildNodes) != 1 && len(instruction.ChildNodes) != 2 {
+		return nil, semanticInternalError("break or continue not having 1 or 2 children")
+	}
+	keyword := instruction.ChildNodes[0].InnerToken.Token
+	label := ""
+	if len(instruction.ChildNodes) == 2 {
+		label = instruction.ChildNodes[1].InnerToken.Token
+	}
+
+	loop := findLoop(currentScope, label)
+	if loop == nil && label == "" {
+		return nil, semanticError(
+			instruction.Span(), common.CodeJumpOutsideLoop, keyword+" outside of a loop",
+		)
+	}
+	if loop == nil {
+		return nil, semanticError(
+			instruction.ChildNodes[1].Span(), common.CodeUndeclaredLabel,
+			fmt.Sprintf("%v is not the label of a loop that the %v is in", label, keyword),
+		)
+	}
+
+	if instruction.ChildNodes[0].InnerToken.TokenKind == common.TokenBreak {
+		return common.BreakAST{Loop: loop, Span: instruction.Span()}, nil
+	}
+	return common.ContinueAST{Loop: loop, Span: instruction.Span()}, nil
+}
+
+// lowers the program inside { } in a scope of its own
+// braces is the span from the { to the }, and loop is the loop it is the body of, if any
+func lowerBlock(
+	input common.ParseTreeNode,
+	braces common.Span,
+	identifiers []common.IdentifierInformation,
+	currentScope *scope,
+	loop *common.Loop,
+) (common.ProgramAST, []common.IdentifierInformation, error) {
+	blockScope := newScope(currentScope, currentScope.function, braces)
+	blockScope.loop = loop
+	program, identifiers, err := lowerProgram(input, identifiers, blockScope)
+	blockScope.close()
+	return program, identifiers, err
+}
+
 func lowerOutputStatement(
 	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.OutputStatementAST, error) {
+	if instruction.InnerToken.Token == "I1>output (K)" {
+		return lowerPrintStatement(instruction, identifiers, currentScope)
+	}
 	if len(instruction.ChildNodes) != 3 {
 		return common.OutputStatementAST{}, semanticInternalError("output not having 3 children")
 	}
@@ -393,15 +745,17 @@ func lowerOutputStatement(
 					HasKnownLength: true,
 					CharacterCount: len(instruction.ChildNodes[1].InnerToken.Token) - 2,
 				},
+				Span: instruction.ChildNodes[1].Span(),
 			},
 		},
+		Span: instruction.Span(),
 	}
 	childC := instruction.ChildNodes[2]
 	for len(childC.ChildNodes) > 0 {
 		if len(childC.ChildNodes) != 2 {
 			return outputStatement, semanticInternalError("output continuation not having 0 or 2 children")
 		}
-		childR, err := lowerRelation(childC.ChildNodes[0], identifiers)
+		childR, err := lowerRelation(childC.ChildNodes[0], identifiers, currentScope)
 		if err != nil {
 			return outputStatement, err
 		}
@@ -411,17 +765,51 @@ func lowerOutputStatement(
 	return outputStatement, nil
 }
 
+func lowerPrintStatement(
+	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
+) (common.OutputStatementAST, error) {
+	if len(instruction.ChildNodes) != 2 {
+		return common.OutputStatementAST{}, semanticInternalError("print not having 2 children")
+	}
+	outputStatement := common.OutputStatementAST{
+		Function:  common.OutputPrint,
+		Arguments: []common.ExpressionAST{},
+		Span:      instruction.Span(),
+	}
+	if instruction.ChildNodes[0].InnerToken.Token == "println" {
+		outputStatement.Function = common.OutputPrintln
+	}
+
+	childK := instruction.ChildNodes[1]
+	for len(childK.ChildNodes) > 0 {
+		if len(childK.ChildNodes) != 2 {
+			return outputStatement, semanticInternalError("print arguments not having 0 or 2 children")
+		}
+		childR, err := lowerRelation(childK.ChildNodes[0], identifiers, currentScope)
+		if err != nil {
+			return outputStatement, err
+		}
+		outputStatement.Arguments = append(outputStatement.Arguments, childR)
+		childK = childK.ChildNodes[1]
+	}
+	return outputStatement, nil
+}
+
 func lowerRelation(
 	relationInstruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ExpressionAST, error) {
 	if len(relationInstruction.ChildNodes) != 2 {
 		return nil, semanticInternalError("Relation expected to have two children")
 	}
-	expression, err := lowerRa(relationInstruction.ChildNodes[0], identifiers)
+	expression, err := lowerRa(relationInstruction.ChildNodes[0], identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
-	expression, err = lowerRz(relationInstruction.ChildNodes[1], expression, identifiers)
+	expression, err = lowerRz(
+		relationInstruction.ChildNodes[1], expression, identifiers, currentScope,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -430,15 +818,18 @@ func lowerRelation(
 
 func lowerRa(
 	relationInstruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ExpressionAST, error) {
 	if len(relationInstruction.ChildNodes) != 2 {
 		return nil, semanticInternalError("Relation (Ra) expected to have two children")
 	}
-	expression, err := lowerRb(relationInstruction.ChildNodes[0], identifiers)
+	expression, err := lowerRb(relationInstruction.ChildNodes[0], identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
-	expression, err = lowerRy(relationInstruction.ChildNodes[1], expression, identifiers)
+	expression, err = lowerRy(
+		relationInstruction.ChildNodes[1], expression, identifiers, currentScope,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -448,6 +839,7 @@ func lowerRa(
 func lowerRz(
 	relationInstruction common.ParseTreeNode, calculationsUntilNow common.ExpressionAST,
 	identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ExpressionAST, error) {
 	if len(relationInstruction.ChildNodes) == 0 {
 		return calculationsUntilNow, nil
@@ -458,7 +850,7 @@ func lowerRz(
 	if relationInstruction.ChildNodes[0].InnerToken.TokenKind != common.TokenOr {
 		return nil, semanticInternalError("|| expected in Rz")
 	}
-	secondOperand, err := lowerRa(relationInstruction.ChildNodes[1], identifiers)
+	secondOperand, err := lowerRa(relationInstruction.ChildNodes[1], identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
@@ -466,8 +858,11 @@ func lowerRz(
 		Operator:      common.BinaryOr,
 		FirstOperand:  calculationsUntilNow,
 		SecondOperand: secondOperand,
+		Span:          calculationsUntilNow.GetSpan().Join(secondOperand.GetSpan()),
 	}
-	expression, err := lowerRz(relationInstruction.ChildNodes[2], binaryRelation, identifiers)
+	expression, err := lowerRz(
+		relationInstruction.ChildNodes[2], binaryRelation, identifiers, currentScope,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -476,13 +871,14 @@ func lowerRz(
 
 func lowerRb(
 	relationInstruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ExpressionAST, error) {
 	if len(relationInstruction.ChildNodes) != 2 {
 		return nil, semanticInternalError("unexpected number of children in Rb")
 	}
 	if relationInstruction.ChildNodes[0].InnerToken.TokenKind == common.TokenNot {
 		childCalculations, err := lowerRelation(
-			relationInstruction.ChildNodes[1], identifiers,
+			relationInstruction.ChildNodes[1], identifiers, currentScope,
 		)
 		if err != nil {
 			return nil, err
@@ -490,9 +886,10 @@ func lowerRb(
 		return common.UnaryExpression{
 			Operator: common.UnaryNot,
 			Operand:  childCalculations,
+			Span:     relationInstruction.Span(),
 		}, nil
 	}
-	firstExpression, err := lowerE(relationInstruction.ChildNodes[0], identifiers)
+	firstExpression, err := lowerE(relationInstruction.ChildNodes[0], identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
@@ -503,7 +900,9 @@ func lowerRb(
 		return nil, semanticInternalError("unexpected number of children in child of Rb")
 	}
 
-	secondExpression, err := lowerE(relationInstruction.ChildNodes[1].ChildNodes[1], identifiers)
+	secondExpression, err := lowerE(
+		relationInstruction.ChildNodes[1].ChildNodes[1], identifiers, currentScope,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -511,6 +910,7 @@ func lowerRb(
 	expression := common.BinaryExpression{
 		FirstOperand:  firstExpression,
 		SecondOperand: secondExpression,
+		Span:          firstExpression.GetSpan().Join(secondExpression.GetSpan()),
 	}
 
 	switch relationInstruction.ChildNodes[1].ChildNodes[0].InnerToken.TokenKind {
@@ -541,6 +941,7 @@ func lowerRb(
 func lowerRy(
 	relationInstruction common.ParseTreeNode, calculationsUntilNow common.ExpressionAST,
 	identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ExpressionAST, error) {
 	if len(relationInstruction.ChildNodes) == 0 {
 		return calculationsUntilNow, nil
@@ -551,7 +952,7 @@ func lowerRy(
 	if relationInstruction.ChildNodes[0].InnerToken.TokenKind != common.TokenAnd {
 		return nil, semanticInternalError("&& expected in Ry")
 	}
-	secondOperand, err := lowerRb(relationInstruction.ChildNodes[1], identifiers)
+	secondOperand, err := lowerRb(relationInstruction.ChildNodes[1], identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
@@ -559,8 +960,11 @@ func lowerRy(
 		Operator:      common.BinaryAnd,
 		FirstOperand:  calculationsUntilNow,
 		SecondOperand: secondOperand,
+		Span:          calculationsUntilNow.GetSpan().Join(secondOperand.GetSpan()),
 	}
-	expression, err := lowerRy(relationInstruction.ChildNodes[2], binaryRelation, identifiers)
+	expression, err := lowerRy(
+		relationInstruction.ChildNodes[2], binaryRelation, identifiers, currentScope,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -569,16 +973,17 @@ func lowerRy(
 
 func lowerE(
 	expression common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ExpressionAST, error) {
 	if len(expression.ChildNodes) != 2 {
 		expression.Display("", ">")
 		return nil, semanticInternalError("expression does not have two children")
 	}
-	childT, err := lowerT(expression.ChildNodes[0], identifiers)
+	childT, err := lowerT(expression.ChildNodes[0], identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
-	childE1, err := lowerE1(expression.ChildNodes[1], childT, identifiers)
+	childE1, err := lowerE1(expression.ChildNodes[1], childT, identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
@@ -587,15 +992,16 @@ func lowerE(
 
 func lowerT(
 	expression common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ExpressionAST, error) {
 	if len(expression.ChildNodes) != 2 {
 		return nil, semanticInternalError("expression T does not have two children")
 	}
-	childF, err := lowerF(expression.ChildNodes[0], identifiers)
+	childF, err := lowerF(expression.ChildNodes[0], identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
-	childT1, err := lowerT1(expression.ChildNodes[1], childF, identifiers)
+	childT1, err := lowerT1(expression.ChildNodes[1], childF, identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
@@ -605,6 +1011,7 @@ func lowerT(
 func lowerE1(
 	expression common.ParseTreeNode, calculationsUntilNow common.ExpressionAST,
 	identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ExpressionAST, error) {
 	if len(expression.ChildNodes) == 0 {
 		return calculationsUntilNow, nil
@@ -613,7 +1020,7 @@ func lowerE1(
 		return nil, semanticInternalError("E1 has unexpected number of array elements")
 	}
 
-	secondExpression, err := lowerT(expression.ChildNodes[1], identifiers)
+	secondExpression, err := lowerT(expression.ChildNodes[1], identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
@@ -621,6 +1028,7 @@ func lowerE1(
 	binaryExpression := common.BinaryExpression{
 		FirstOperand:  calculationsUntilNow,
 		SecondOperand: secondExpression,
+		Span:          calculationsUntilNow.GetSpan().Join(secondExpression.GetSpan()),
 	}
 
 	switch expression.ChildNodes[0].InnerToken.TokenKind {
@@ -634,15 +1042,20 @@ func lowerE1(
 		return nil, semanticInternalError("unexpected operator in E1")
 	}
 
-	return binaryExpression, nil
+	// left associative, so a - b - c is (a - b) - c
+	return lowerE1(expression.ChildNodes[2], binaryExpression, identifiers, currentScope)
 }
 
 func lowerF(
 	expression common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ExpressionAST, error) {
 	if len(expression.ChildNodes) == 0 {
 		return nil, semanticInternalError("expression F needs at least 1 element")
 	}
+	if expression.InnerToken.Token == "F>F as type" {
+		return lowerCast(expression, identifiers, currentScope)
+	}
 	switch expression.ChildNodes[0].InnerToken.TokenKind {
 	case common.TokenOpenSquareBraces:
 		if len(expression.ChildNodes) != 3 {
@@ -651,37 +1064,49 @@ func lowerF(
 		if expression.ChildNodes[2].InnerToken.TokenKind != common.TokenCloseSquareBraces {
 			return nil, semanticInternalError("expected ]")
 		}
-		array, err := lowerArrayExpression(expression.ChildNodes[1], identifiers)
+		array, err := lowerArrayExpression(expression.ChildNodes[1], identifiers, currentScope)
 		if err != nil {
 			return nil, err
 		}
+		array.Span = expression.Span()
 		return array, nil
 
 	case common.TokenExpressionSub:
 		if len(expression.ChildNodes) != 2 {
 			return nil, semanticInternalError("expression for unary minus needs 2 elements")
 		}
-		childExpression, err := lowerF(expression.ChildNodes[1], identifiers)
+		childExpression, err := lowerF(expression.ChildNodes[1], identifiers, currentScope)
 		if err != nil {
 			return nil, err
 		}
 		return common.UnaryExpression{
 			Operator: common.UnaryMinus,
 			Operand:  childExpression,
+			Span:     expression.Span(),
 		}, nil
 
 	case common.TokenBlock:
 		if len(expression.ChildNodes) != 1 {
 			return nil, semanticInternalError("expression block should have no siblings")
 		}
-		childExpression, err := lowerRelation(expression.ChildNodes[0], identifiers)
+		childExpression, err := lowerRelation(expression.ChildNodes[0], identifiers, currentScope)
 		if err != nil {
 			return nil, err
 		}
 		return childExpression, nil
 
 	case common.TokenIdent:
-		childIdentifier, err := lowerIdentifierAfterDeclaration(expression, identifiers)
+		if len(expression.ChildNodes) == 3 &&
+			expression.ChildNodes[1].InnerToken.TokenKind == common.TokenOpenParanthesis {
+			childCall, err := lowerCall(expression, identifiers, currentScope)
+			if err != nil {
+				return nil, err
+			}
+			return childCall, nil
+		}
+		childIdentifier, err := lowerIdentifierAfterDeclaration(
+			expression, identifiers, currentScope,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -691,10 +1116,7 @@ func lowerF(
 		if len(expression.ChildNodes) != 1 {
 			return nil, semanticInternalError("F should have no siblings")
 		}
-		return common.Literal{
-			Value:    expression.ChildNodes[0].InnerToken.Token,
-			Datatype: common.TypedInt,
-		}, nil
+		return numberLiteral(expression.ChildNodes[0].InnerToken.Token, common.TypedInt, expression.Span())
 
 	case common.TokenLiteralChar:
 		if len(expression.ChildNodes) != 1 {
@@ -703,6 +1125,7 @@ func lowerF(
 		return common.Literal{
 			Value:    expression.ChildNodes[0].InnerToken.Token,
 			Datatype: common.TypedChar,
+			Span:     expression.Span(),
 		}, nil
 
 	case common.TokenLiteralBool:
@@ -712,16 +1135,14 @@ func lowerF(
 		return common.Literal{
 			Value:    expression.ChildNodes[0].InnerToken.Token,
 			Datatype: common.TypedBool,
+			Span:     expression.Span(),
 		}, nil
 
 	case common.TokenLiteralFloat:
 		if len(expression.ChildNodes) != 1 {
 			return nil, semanticInternalError("F should have no siblings")
 		}
-		return common.Literal{
-			Value:    expression.ChildNodes[0].InnerToken.Token,
-			Datatype: common.TypedFloat,
-		}, nil
+		return numberLiteral(expression.ChildNodes[0].InnerToken.Token, common.TypedFloat, expression.Span())
 
 	case common.TokenLiteralString:
 		if len(expression.ChildNodes) != 1 {
@@ -731,15 +1152,31 @@ func lowerF(
 			Value: expression.ChildNodes[0].InnerToken.Token,
 			Datatype: common.StringDatatype{
 				HasKnownLength: true,
-				CharacterCount: len(expression.InnerToken.Token) - 2,
+				CharacterCount: len(expression.ChildNodes[0].InnerToken.Token) - 2,
 			},
+			Span: expression.Span(),
+		}, nil
+
+	case common.TokenLength:
+		if len(expression.ChildNodes) != 3 {
+			return nil, semanticInternalError("len should have 3 children")
+		}
+		childOperand, err := lowerRelation(expression.ChildNodes[1], identifiers, currentScope)
+		if err != nil {
+			return nil, err
+		}
+		return common.LengthExpression{
+			Operand: childOperand,
+			Span:    expression.Span(),
 		}, nil
 
 	case common.TokenInput:
 		if len(expression.ChildNodes) != 1 {
 			return nil, semanticInternalError("input statement should have no siblings")
 		}
-		return common.InputExpression{}, nil
+		return common.InputExpression{
+			Span: expression.Span(),
+		}, nil
 
 	default:
 		fmt.Println(common.NameMapWithTokenKind[expression.ChildNodes[0].InnerToken.TokenKind])
@@ -747,9 +1184,33 @@ func lowerF(
 	}
 }
 
+// F -> F as type
+func lowerCast(
+	expression common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
+) (common.ExpressionAST, error) {
+	if len(expression.ChildNodes) != 3 {
+		return nil, semanticInternalError("cast should have 3 children")
+	}
+	childOperand, err := lowerF(expression.ChildNodes[0], identifiers, currentScope)
+	if err != nil {
+		return nil, err
+	}
+	datatype, err := lowerDatatype(expression.ChildNodes[2])
+	if err != nil {
+		return nil, err
+	}
+	return common.CastExpression{
+		Operand:  childOperand,
+		Datatype: datatype,
+		Span:     expression.Span(),
+	}, nil
+}
+
 func lowerT1(
 	expression common.ParseTreeNode, calculationsUntilNow common.ExpressionAST,
 	identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ExpressionAST, error) {
 	if len(expression.ChildNodes) == 0 {
 		return calculationsUntilNow, nil
@@ -758,7 +1219,7 @@ func lowerT1(
 		return nil, semanticInternalError("T1 has unexpected number of elements")
 	}
 
-	secondExpression, err := lowerF(expression.ChildNodes[1], identifiers)
+	secondExpression, err := lowerF(expression.ChildNodes[1], identifiers, currentScope)
 	if err != nil {
 		return nil, err
 	}
@@ -766,6 +1227,7 @@ func lowerT1(
 	binaryOperation := common.BinaryExpression{
 		FirstOperand:  calculationsUntilNow,
 		SecondOperand: secondExpression,
+		Span:          calculationsUntilNow.GetSpan().Join(secondExpression.GetSpan()),
 	}
 
 	switch expression.ChildNodes[0].InnerToken.TokenKind {
@@ -782,11 +1244,13 @@ func lowerT1(
 		return nil, semanticInternalError("unexpected operand in T1")
 	}
 
-	return binaryOperation, nil
+	// left associative, so a / b / c is (a / b) / c
+	return lowerT1(expression.ChildNodes[2], binaryOperation, identifiers, currentScope)
 }
 
 func lowerArrayExpression(
 	arrayExpression common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.ArrayExpression, error) {
 	array := common.ArrayExpression{
 		Elements: []common.ExpressionAST{},
@@ -795,7 +1259,7 @@ func lowerArrayExpression(
 		if len(arrayExpression.ChildNodes) != 2 {
 			return array, semanticInternalError("expected 2 children while parsing array")
 		}
-		element, err := lowerRelation(arrayExpression.ChildNodes[0], identifiers)
+		element, err := lowerRelation(arrayExpression.ChildNodes[0], identifiers, currentScope)
 		if err != nil {
 			return array, err
 		}
@@ -807,6 +1271,7 @@ func lowerArrayExpression(
 
 func lowerIdentifierAfterDeclaration(
 	input common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
 ) (common.Identifier, error) {
 	if len(input.ChildNodes) != 2 {
 		return common.Identifier{}, semanticInternalError("identifier expected to be two elements")
@@ -815,25 +1280,347 @@ func lowerIdentifierAfterDeclaration(
 		input.ChildNodes[1].InnerToken.TokenKind != common.TokenBlock {
 		return common.Identifier{}, semanticInternalError("identifier and block expected")
 	}
-	arrayUsage, err := lowerArrayUsage(input.ChildNodes[1], identifiers)
+	arrayUsage, err := lowerArrayUsage(input.ChildNodes[1], identifiers, currentScope)
 	if err != nil {
 		return common.Identifier{}, err
 	}
-	index := find(identifiers, input.ChildNodes[0].InnerToken.Token)
+	index := find(identifiers, currentScope, input.ChildNodes[0].InnerToken.Token)
 	if index < 0 {
-		return common.Identifier{}, semanticError("identifier used before being declared")
+		return common.Identifier{}, undeclaredError(
+			currentScope, input.ChildNodes[0].InnerToken.Token, input.ChildNodes[0].Span(),
+		)
+	}
+	recordUse(identifiers, index, input.ChildNodes[0].Span())
+	if _, ok := identifiers[index].Datatype.(common.FunctionDatatype); ok {
+		return common.Identifier{}, semanticError(
+			input.ChildNodes[0].Span(),
+			common.CodeFunctionAsValue,
+			"function used as a value without being called",
+		)
 	}
 	return common.Identifier{
 		Id:          index,
 		ArrayValues: arrayUsage,
+		Span:        input.Span(),
 	}, nil
 }
 
-func semanticError(message string) *common.CompilationError {
+func lowerFunction(
+	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
+) (common.FunctionAST, []common.IdentifierInformation, error) {
+	if len(instruction.ChildNodes) != 7 {
+		return common.FunctionAST{}, identifiers, semanticInternalError(
+			"unexpected length of fn",
+		)
+	}
+	if currentScope.function >= 0 {
+		return common.FunctionAST{}, identifiers, semanticError(
+			instruction.ChildNodes[0].Span().Join(instruction.ChildNodes[1].Span()),
+			common.CodeNestedFunction,
+			"functions cannot be declared inside another function",
+		)
+	}
+	functionName := instruction.ChildNodes[1].InnerToken.Token
+	if currentScope.isDeclared(functionName) {
+		return common.FunctionAST{}, identifiers, redeclarationError(
+			currentScope, identifiers, instruction.ChildNodes[1],
+		)
+	}
+
+	// collect the parameters before declaring anything
+	parameterNames := []string{}
+	parameterSpans := []common.Span{}
+	functionDatatype := common.FunctionDatatype{
+		ParameterTypes: []common.Datatype{},
+		ReturnType:     common.VoidDatatype{},
+	}
+	childP := instruction.ChildNodes[2]
+	for len(childP.ChildNodes) > 0 {
+		if len(childP.ChildNodes) != 3 {
+			return common.FunctionAST{}, identifiers, semanticInternalError(
+				"parameter expected to have 3 children",
+			)
+		}
+		name := childP.ChildNodes[0].InnerToken.Token
+		for _, previousName := range parameterNames {
+			if previousName == name {
+				return common.FunctionAST{}, identifiers, semanticError(
+					childP.ChildNodes[0].Span(),
+					common.CodeDuplicateParameter,
+					"parameter name used more than once",
+				)
+			}
+		}
+		datatype, err := lowerDatatype(childP.ChildNodes[1])
+		if err != nil {
+			return common.FunctionAST{}, identifiers, err
+		}
+		parameterNames = append(parameterNames, name)
+		parameterSpans = append(parameterSpans, childP.ChildNodes[0].Span())
+		functionDatatype.ParameterTypes = append(functionDatatype.ParameterTypes, datatype)
+		childP = childP.ChildNodes[2]
+	}
+	if childQ := instruction.ChildNodes[3]; len(childQ.ChildNodes) > 0 {
+		datatype, err := lowerDatatype(childQ.ChildNodes[0])
+		if err != nil {
+			return common.FunctionAST{}, identifiers, err
+		}
+		functionDatatype.ReturnType = datatype
+	}
+
+	// the function is declared before its body so that it may call itself
+	identifiers = append(identifiers, common.IdentifierInformation{
+		IdentifierName: functionName,
+		Datatype:       functionDatatype,
+		Mutable:        false,
+		Declaration:    instruction.ChildNodes[1].Span(),
+		Scope:          currentScope.span,
+	})
+	function := common.FunctionAST{
+		Function:   len(identifiers) - 1,
+		Parameters: []int{},
+		Locals:     []int{},
+		Span:       instruction.Span(),
+	}
+	currentScope.declare(functionName, function.Function)
+
+	functionScope := newScope(
+		currentScope, function.Function,
+		instruction.ChildNodes[4].Span().Join(instruction.ChildNodes[6].Span()),
+	)
+	for index, name := range parameterNames {
+		identifiers = append(identifiers, common.IdentifierInformation{
+			IdentifierName: name,
+			Datatype:       functionDatatype.ParameterTypes[index],
+			Mutable:        false,
+			Declaration:    parameterSpans[index],
+			Scope:          functionScope.span,
+		})
+		function.Parameters = append(function.Parameters, len(identifiers)-1)
+		functionScope.declare(name, len(identifiers)-1)
+	}
+
+	if instruction.ChildNodes[4].InnerToken.TokenKind != common.TokenOpenCurly ||
+		instruction.ChildNodes[6].InnerToken.TokenKind != common.TokenCloseCurly {
+		return common.FunctionAST{}, identifiers, semanticInternalError(
+			"open or closing brace missing",
+		)
+	}
+	localsFrom := len(identifiers)
+	childProgram, identifiers, err := lowerProgram(
+		instruction.ChildNodes[5], identifiers, functionScope,
+	)
+	functionScope.close()
+	if err != nil {
+		return common.FunctionAST{}, identifiers, err
+	}
+	for index := localsFrom; index < len(identifiers); index++ {
+		function.Locals = append(function.Locals, index)
+	}
+	function.Program = childProgram
+	return function, identifiers, nil
+}
+
+// a datatype, or an array type such as [[int; 3]; 2]
+func lowerType(datatype common.ParseTreeNode) (common.Datatype, error) {
+	if datatype.InnerToken.Token != "T>[T;int]" {
+		return lowerDatatype(datatype)
+	}
+	if len(datatype.ChildNodes) != 4 {
+		return nil, semanticInternalError("array type should have length 4")
+	}
+	elementType, err := lowerType(datatype.ChildNodes[1])
+	if err != nil {
+		return nil, err
+	}
+	childLength := datatype.ChildNodes[2]
+	length, err := strconv.Atoi(childLength.InnerToken.Token)
+	if err != nil || length <= 0 {
+		return nil, semanticError(
+			childLength.Span(), common.CodeInvalidArrayLength,
+			"the length of an array should be at least 1",
+		)
+	}
+	return common.ArrayDatatype{ElementType: elementType, NumberOfElements: length}, nil
+}
+
+func lowerDatatype(datatype common.ParseTreeNode) (common.Datatype, error) {
+	if datatype.InnerToken.TokenKind != common.TokenDatatype {
+		return nil, semanticInternalError("datatype expected")
+	}
+	switch datatype.InnerToken.Token {
+	case "int":
+		return common.TypedInt, nil
+
+	case "float":
+		return common.TypedFloat, nil
+
+	case "char":
+		return common.TypedChar, nil
+
+	case "bool":
+		return common.TypedBool, nil
+
+	case "i8":
+		return common.TypedI8, nil
+
+	case "i16":
+		return common.TypedI16, nil
+
+	case "i32":
+		return common.TypedI32, nil
+
+	case "i64":
+		return common.TypedI64, nil
+
+	case "u8":
+		return common.TypedU8, nil
+
+	case "u16":
+		return common.TypedU16, nil
+
+	case "u32":
+		return common.TypedU32, nil
+
+	case "u64":
+		return common.TypedU64, nil
+
+	case "f32":
+		return common.TypedF32, nil
+
+	default:
+		return nil, semanticInternalError("unknown datatype")
+	}
+}
+
+// A number literal takes the datatype of its suffix, if it has one, e.g., 255u8 is a u8 of 255.
+// The range of the value is checked by the type checker, once its sign is known.
+func numberLiteral(
+	token string, datatype common.Datatype, span common.Span,
+) (common.Literal, error) {
+	index := strings.IndexAny(token, "iuf")
+	if index < 0 {
+		return common.Literal{Value: token, Datatype: datatype, Span: span}, nil
+	}
+	suffix, err := lowerDatatype(common.ParseTreeNode{
+		InnerToken: common.Token{TokenKind: common.TokenDatatype, Token: token[index:]},
+	})
+	if err != nil {
+		return common.Literal{}, err
+	}
+	value := token[:index]
+	if suffix == common.TypedF32 && !strings.Contains(value, ".") {
+		// 1f32 is the float 1.0
+		value += ".0"
+	}
+	return common.Literal{Value: value, Datatype: suffix, Span: span}, nil
+}
+
+func lowerReturn(
+	instruction common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
+) (common.ReturnAST, error) {
+	if currentScope.function < 0 {
+		return common.ReturnAST{}, semanticError(
+			instruction.Span(), common.CodeReturnOutsideFunction, "return outside of a function",
+		)
+	}
+	returnStatement := common.ReturnAST{
+		Function: currentScope.function,
+		Span:     instruction.Span(),
+	}
+	switch len(instruction.ChildNodes) {
+	case 1:
+		return returnStatement, nil
+
+	case 2:
+		childR, err := lowerRelation(instruction.ChildNodes[1], identifiers, currentScope)
+		if err != nil {
+			return common.ReturnAST{}, err
+		}
+		returnStatement.Value = childR
+		return returnStatement, nil
+
+	default:
+		return common.ReturnAST{}, semanticInternalError("return should have 1 or 2 children")
+	}
+}
+
+func lowerCall(
+	input common.ParseTreeNode, identifiers []common.IdentifierInformation,
+	currentScope *scope,
+) (common.CallExpression, error) {
+	if len(input.ChildNodes) != 3 {
+		return common.CallExpression{}, semanticInternalError("call expected to have 3 children")
+	}
+	index := find(identifiers, currentScope, input.ChildNodes[0].InnerToken.Token)
+	if index < 0 {
+		return common.CallExpression{}, undeclaredError(
+			currentScope, input.ChildNodes[0].InnerToken.Token, input.ChildNodes[0].Span(),
+		)
+	}
+	recordUse(identifiers, index, input.ChildNodes[0].Span())
+	if _, ok := identifiers[index].Datatype.(common.FunctionDatatype); !ok {
+		return common.CallExpression{}, withNote(
+			semanticError(
+				input.ChildNodes[0].Span(),
+				common.CodeNotAFunction,
+				"called identifier is not a function",
+			),
+			identifiers[index].Declaration,
+			fmt.Sprintf("%v declared here", identifiers[index].IdentifierName),
+		)
+	}
+
+	call := common.CallExpression{
+		Function:  index,
+		Arguments: []common.ExpressionAST{},
+		Span:      input.Span(),
+	}
+	childK := input.ChildNodes[2]
+	for len(childK.ChildNodes) > 0 {
+		if len(childK.ChildNodes) != 2 {
+			return call, semanticInternalError("expected 2 children while parsing arguments")
+		}
+		argument, err := lowerRelation(childK.ChildNodes[0], identifiers, currentScope)
+		if err != nil {
+			return call, err
+		}
+		call.Arguments = append(call.Arguments, argument)
+		childK = childK.ChildNodes[1]
+	}
+	return call, nil
+}
+
+func semanticError(span common.Span, code string, message string) *common.CompilationError {
 	return &common.CompilationError{
 		PointOfFailure: "Semantic Analyzer",
 		Message:        message,
+		Span:           span,
+		Code:           code,
+	}
+}
+
+// a note is only added where the span is known
+func withNote(
+	err *common.CompilationError, span common.Span, message string,
+) *common.CompilationError {
+	if !span.IsZero() {
+		err.Notes = append(err.Notes, common.Note{Message: message, Span: span})
 	}
+	return err
+}
+
+// points to the earlier declaration of the same name in the scope
+func redeclarationError(
+	currentScope *scope, identifiers []common.IdentifierInformation, identifier common.ParseTreeNode,
+) *common.CompilationError {
+	err := semanticError(
+		identifier.Span(), common.CodeAlreadyDeclared, "identifier already declared in this scope",
+	)
+	previous := identifiers[currentScope.names[identifier.InnerToken.Token]]
+	return withNote(err, previous.Declaration, "previously declared here")
 }
 
 func semanticInternalError(message string) *common.InternalError {
@@ -843,13 +1630,115 @@ func semanticInternalError(message string) *common.InternalError {
 	}
 }
 
+// the identifiers declared directly inside a block, a function body or the main program
+type scope struct {
+	parent *scope
+	// index of the function this scope belongs to, -1 for the main program
+	function int
+	names    map[string]int
+	// names declared in blocks that have already been closed
+	closedNames map[string]bool
+	// names declared by instructions left out for a syntax error
+	skipped map[string]bool
+	// from the { to the }, zero for the main program
+	span common.Span
+	// the loop whose body this is, nil if it is not the body of a loop
+	loop *common.Loop
+}
+
+func newScope(parent *scope, function int, span common.Span) *scope {
+	return &scope{
+		parent:      parent,
+		function:    function,
+		names:       map[string]int{},
+		closedNames: map[string]bool{},
+		skipped:     map[string]bool{},
+		span:        span,
+	}
+}
+
+func (s *scope) declare(name string, index int) {
+	s.names[name] = index
+}
+
+// an identifier may shadow one from an outer scope, but not one from the same scope
+func (s *scope) isDeclared(name string) bool {
+	_, ok := s.names[name]
+	return ok
+}
+
+// remembers the names of the scope in its parent, for better error messages
+func (s *scope) close() {
+	if s.parent == nil {
+		return
+	}
+	for name := range s.names {
+		s.parent.closedNames[name] = true
+	}
+	for name := range s.closedNames {
+		s.parent.closedNames[name] = true
+	}
+}
+
+func undeclaredError(
+	currentScope *scope, name string, span common.Span,
+) error {
+	for s := currentScope; s != nil; s = s.parent {
+		if s.skipped[name] {
+			return ErrSkippedDeclaration
+		}
+	}
+	for s := currentScope; s != nil; s = s.parent {
+		if _, ok := s.names[name]; ok && s.function != currentScope.function {
+			return semanticError(
+				span,
+				common.CodeIdentifierOutsideFunction,
+				"identifier declared outside of the function being used inside it",
+			)
+		}
+		if s.closedNames[name] {
+			return semanticError(
+				span, common.CodeIdentifierOutOfScope, "identifier used outside of its scope",
+			)
+		}
+	}
+	return semanticError(
+		span, common.CodeUndeclaredIdentifier, "identifier used before being declared",
+	)
+}
+
+// Looks for the innermost loop with the label, or the innermost loop when the label is empty.
+// A loop outside the current function cannot be broken out of.
+func findLoop(currentScope *scope, label string) *common.Loop {
+	for s := currentScope; s != nil && s.function == currentScope.function; s = s.parent {
+		if s.loop != nil && (label == "" || s.loop.Label == label) {
+			return s.loop
+		}
+	}
+	return nil
+}
+
+// remembers where the identifier is used, for the language server
+func recordUse(identifiers []common.IdentifierInformation, index int, span common.Span) {
+	identifiers[index].Uses = append(identifiers[index].Uses, span)
+}
+
+// Looks for the identifier from the current scope outwards.
+// Only functions are visible across a function boundary,
+// since the variables of the main program are not reachable from a function body.
 func find(
-	identifiers []common.IdentifierInformation, lookingFor string,
+	identifiers []common.IdentifierInformation, currentScope *scope, lookingFor string,
 ) int {
-	for index, identifier := range identifiers {
-		if identifier.IdentifierName == lookingFor {
-			return index
+	for s := currentScope; s != nil; s = s.parent {
+		index, ok := s.names[lookingFor]
+		if !ok {
+			continue
+		}
+		if _, isFunction := identifiers[index].Datatype.(common.FunctionDatatype); !isFunction &&
+			s.function != currentScope.function {
+			return -1
 		}
+		return index
 	}
 	return -1
 }
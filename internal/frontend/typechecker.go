@@ -1,6 +1,10 @@
 package frontend
 
-import "github.com/SamJohn04/simple-lang-compiler/internal/common"
+import (
+	"errors"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+)
 
 func TypeChecker(
 	input common.ProgramAST, identifiers []common.IdentifierInformation,
@@ -10,14 +14,21 @@ func TypeChecker(
 	// as such, SyntaxTreeNode.Datatype is not necessary till here
 	err := input.PerformAllChecks(identifiers)
 	if err != nil {
-		return common.ProgramAST{}, typeCheckerCompilationError(err.Error())
+		return common.ProgramAST{}, typeCheckerCompilationError(err)
 	}
 	return input, nil
 }
 
-func typeCheckerCompilationError(message string) *common.CompilationError {
-	return &common.CompilationError{
+func typeCheckerCompilationError(err error) *common.CompilationError {
+	compilationError := &common.CompilationError{
 		PointOfFailure: "Type Checker",
-		Message:        message,
+		Message:        err.Error(),
+	}
+	var spanError *common.SpanError
+	if errors.As(err, &spanError) {
+		compilationError.Span = spanError.Span
+		compilationError.Code = spanError.Code
+		compilationError.Notes = spanError.Notes
 	}
+	return compilationError
 }
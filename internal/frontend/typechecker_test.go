@@ -0,0 +1,106 @@
+package frontend
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+)
+
+// runs the front end on the source, whose first mistake is returned
+func typeCheck(source string) error {
+	lex := make(chan common.Token)
+	go Lexer(strings.NewReader(source), "test.sl", lex)
+	root, err := Parser(lex, 0)
+	if err != nil {
+		return err
+	}
+	program, identifiers, err := SemanticAnalyzer(root)
+	if err != nil {
+		return err
+	}
+	_, err = TypeChecker(program, identifiers)
+	return err
+}
+
+func TestArrayIndex(t *testing.T) {
+	for _, source := range []string{
+		"let a = [1, 2]; let b = a[1];",
+		"let a = [1, 2]; let b = a[1u8] + a[1 as i16];",
+		"let a = [1, 2]; let c = '\\0'; let b = a[c];",
+		"let mut a = [[1], [2]]; a[1u64][0] = 3;",
+		"let s = \"ab\"; let c = s[1i8];",
+	} {
+		if err := typeCheck(source); err != nil {
+			t.Errorf("%q: %v", source, err)
+		}
+	}
+
+	for _, source := range []string{
+		"let a = [1, 2]; let b = a[1.5];",
+		"let a = [1, 2]; let b = a[0.5f32];",
+		"let a = [1, 2]; let b = a[true];",
+		"let a = [1, 2]; let b = a[a];",
+		"let a = [[1], [2]]; let b = a[1][0.0];",
+		"let mut a = [1, 2]; a[0.5] = 3;",
+		"let s = \"ab\"; let c = s[1.0];",
+	} {
+		var compilationError *common.CompilationError
+		err := typeCheck(source)
+		if err == nil || !strings.Contains(err.Error(), "an index should be an integer") ||
+			!errors.As(err, &compilationError) || compilationError.Code != common.CodeInvalidArrayAccess {
+			t.Errorf("%q: got %v, want an error for the index", source, err)
+		}
+	}
+}
+
+func TestSkippedDeclarations(t *testing.T) {
+	tests := []struct {
+		source  string
+		skipped bool
+	}{
+		{"let a = 1 +;\nlet b = a;", true},
+		{"let mut a: [int; 2] = ;\na[0] = 1;", true},
+		{"fn f( {\n};\nf();", true},
+		{"fn f() {\n    let y = 1 *;\n    let z = y;\n};", true},
+		{"let a = 1 let b = 2;\nlet c = a + b;", true},
+		// only the names of the instruction with the error are left out
+		{"let a = 1 +;\nlet b = c;", false},
+		{"fn f() {\n    let y = 1 *;\n};\nlet z = y;", false},
+	}
+	for _, test := range tests {
+		lex := make(chan common.Token)
+		go Lexer(strings.NewReader(test.source), "test.sl", lex)
+		root, syntaxErr := Parser(lex, 0)
+		if syntaxErr == nil {
+			t.Errorf("%q: no syntax error", test.source)
+			continue
+		}
+		_, _, err := SemanticAnalyzer(root)
+		if errors.Is(err, ErrSkippedDeclaration) != test.skipped {
+			t.Errorf("%q: got %v", test.source, err)
+		}
+		var compilationError *common.CompilationError
+		if test.skipped || !errors.As(err, &compilationError) {
+			continue
+		}
+		if compilationError.Code != common.CodeUndeclaredIdentifier &&
+			compilationError.Code != common.CodeIdentifierOutOfScope {
+			t.Errorf("%q: got %v, want an undeclared identifier", test.source, err)
+		}
+	}
+}
+
+func TestUnsupportedConversion(t *testing.T) {
+	for _, source := range []string{
+		`printf("%q\n", 1);`,
+		`printf("%qd\n", 1);`,
+	} {
+		err := typeCheck(source)
+		var compilationError *common.CompilationError
+		if !errors.As(err, &compilationError) || compilationError.Code != common.CodeInvalidFormat {
+			t.Errorf("%q: got %v, want an unsupported conversion", source, err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The intermediate representation is 3-address code,
+// where every instruction has at most one operator and three operands.
+// Each instruction prints as a line of text, which Parse reads back.
+
+type Operand interface {
+	String() string
+}
+
+// an identifier from the identifier table, printed as _tN
+type Identifier int
+
+// used where an identifier is optional, e.g., the result of a call that is discarded
+const NoIdentifier Identifier = -1
+
+func (i Identifier) String() string {
+	return fmt.Sprintf("_t%d", int(i))
+}
+
+type LiteralKind int
+
+const (
+	LiteralInt LiteralKind = iota
+	LiteralFloat
+	LiteralChar
+	LiteralBool
+	LiteralString
+)
+
+// a literal as written in the program, e.g., 'a' or "%lld\n", quotes included
+type Literal struct {
+	Kind  LiteralKind
+	Value string
+}
+
+func (l Literal) String() string {
+	return l.Value
+}
+
+func Int(value int) Literal {
+	return Literal{
+		Kind:  LiteralInt,
+		Value: fmt.Sprint(value),
+	}
+}
+
+// a function provided by the language, rather than declared in the program
+type Builtin string
+
+const (
+	Printf Builtin = "printf"
+	// prints each parameter as its datatype is printed, one after the other
+	PrintValues Builtin = "print"
+	Getchar     Builtin = "getchar"
+	// the number of characters in a string
+	Length Builtin = "len"
+)
+
+func (b Builtin) String() string {
+	return string(b)
+}
+
+type Operator string
+
+const (
+	Add      Operator = "+"
+	Subtract Operator = "-"
+	Multiply Operator = "*"
+	Divide   Operator = "/"
+	Modulo   Operator = "%"
+
+	Equals             Operator = "=="
+	NotEquals          Operator = "!="
+	GreaterThan        Operator = ">"
+	GreaterThanOrEqual Operator = ">="
+	LesserThan         Operator = "<"
+	LesserThanOrEqual  Operator = "<="
+
+	Negate Operator = "-"
+	Not    Operator = "!"
+)
+
+type Instruction interface {
+	String() string
+}
+
+// x = a
+type Assign struct {
+	Destination Identifier
+	Source      Operand
+}
+
+func (a Assign) String() string {
+	return fmt.Sprintf("%v = %v", a.Destination, a.Source)
+}
+
+// x = a op b
+type BinOp struct {
+	Destination Identifier
+	Operator    Operator
+	First       Operand
+	Second      Operand
+}
+
+func (b BinOp) String() string {
+	return fmt.Sprintf("%v = %v %v %v", b.Destination, b.First, b.Operator, b.Second)
+}
+
+// x = op a
+type UnOp struct {
+	Destination Identifier
+	Operator    Operator
+	Operand     Operand
+}
+
+func (u UnOp) String() string {
+	return fmt.Sprintf("%v = %v %v", u.Destination, u.Operator, u.Operand)
+}
+
+// x = a [] i
+// when x is an array, it is filled with the elements of a from i onwards
+type IndexLoad struct {
+	Destination Identifier
+	Array       Identifier
+	Index       Operand
+}
+
+func (i IndexLoad) String() string {
+	return fmt.Sprintf("%v = %v [] %v", i.Destination, i.Array, i.Index)
+}
+
+// a [] i = v
+type IndexStore struct {
+	Array Identifier
+	Index Operand
+	Value Operand
+}
+
+func (i IndexStore) String() string {
+	return fmt.Sprintf("%v [] %v = %v", i.Array, i.Index, i.Value)
+}
+
+// goto L
+type Jump struct {
+	Label string
+}
+
+func (j Jump) String() string {
+	return fmt.Sprintf("goto %v", j.Label)
+}
+
+// if a goto L
+type CondJump struct {
+	Condition Operand
+	Label     string
+}
+
+func (c CondJump) String() string {
+	return fmt.Sprintf("if %v goto %v", c.Condition, c.Label)
+}
+
+// L:
+type Label struct {
+	Name string
+}
+
+func (l Label) String() string {
+	return fmt.Sprintf("%v:", l.Name)
+}
+
+// param a
+// the parameters are given to the next call
+type Param struct {
+	Value Operand
+}
+
+func (p Param) String() string {
+	return fmt.Sprintf("param %v", p.Value)
+}
+
+// x = call f n, or call f n when Result is NoIdentifier
+type Call struct {
+	Result Identifier
+	// an Identifier or a Builtin
+	Function  Operand
+	Arguments int
+}
+
+func (c Call) String() string {
+	if c.Result == NoIdentifier {
+		return fmt.Sprintf("call %v %v", c.Function, c.Arguments)
+	}
+	return fmt.Sprintf("%v = call %v %v", c.Result, c.Function, c.Arguments)
+}
+
+// function f p1 p2 ...
+// every instruction until the next FunctionEnd belongs to the function
+type FunctionStart struct {
+	Function   Identifier
+	Parameters []Identifier
+}
+
+func (f FunctionStart) String() string {
+	header := []string{"function", f.Function.String()}
+	for _, parameter := range f.Parameters {
+		header = append(header, parameter.String())
+	}
+	return strings.Join(header, " ")
+}
+
+// local x
+// x belongs to the function being declared
+type Local struct {
+	Identifier Identifier
+}
+
+func (l Local) String() string {
+	return fmt.Sprintf("local %v", l.Identifier)
+}
+
+// end function
+type FunctionEnd struct{}
+
+func (f FunctionEnd) String() string {
+	return "end function"
+}
+
+// return a, or return when Value is nil
+type Return struct {
+	Value Operand
+}
+
+func (r Return) String() string {
+	if r.Value == nil {
+		return "return"
+	}
+	return fmt.Sprintf("return %v", r.Value)
+}
+
+// prints the instructions, one per line
+func Print(instructions []Instruction) string {
+	lines := []string{}
+	for _, instruction := range instructions {
+		lines = append(lines, instruction.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,281 @@
+package ir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// reads back the instructions printed by Print
+func Parse(text string) ([]Instruction, error) {
+	instructions := []Instruction{}
+	for number, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		instruction, err := ParseInstruction(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", number+1, err)
+		}
+		instructions = append(instructions, instruction)
+	}
+	return instructions, nil
+}
+
+func ParseInstruction(line string) (Instruction, error) {
+	words := split(line)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty instruction")
+	}
+
+	switch {
+	case len(words) == 1 && strings.HasSuffix(words[0], ":"):
+		// L:
+		return Label{Name: strings.TrimSuffix(words[0], ":")}, nil
+
+	case words[0] == "goto" && len(words) == 2:
+		// goto L
+		return Jump{Label: words[1]}, nil
+
+	case words[0] == "if" && len(words) == 4 && words[2] == "goto":
+		// if a goto L
+		condition, err := parseOperand(words[1])
+		if err != nil {
+			return nil, err
+		}
+		return CondJump{Condition: condition, Label: words[3]}, nil
+
+	case words[0] == "param" && len(words) == 2:
+		// param a
+		value, err := parseOperand(words[1])
+		if err != nil {
+			return nil, err
+		}
+		return Param{Value: value}, nil
+
+	case words[0] == "call" && len(words) == 3:
+		// call f n
+		return parseCall(NoIdentifier, words[1], words[2])
+
+	case words[0] == "return" && len(words) <= 2:
+		// return | return a
+		if len(words) == 1 {
+			return Return{}, nil
+		}
+		value, err := parseOperand(words[1])
+		if err != nil {
+			return nil, err
+		}
+		return Return{Value: value}, nil
+
+	case words[0] == "function" && len(words) >= 2:
+		// function f p1 p2 ...
+		function, err := parseIdentifier(words[1])
+		if err != nil {
+			return nil, err
+		}
+		parameters := []Identifier{}
+		for _, word := range words[2:] {
+			parameter, err := parseIdentifier(word)
+			if err != nil {
+				return nil, err
+			}
+			parameters = append(parameters, parameter)
+		}
+		return FunctionStart{Function: function, Parameters: parameters}, nil
+
+	case words[0] == "local" && len(words) == 2:
+		// local x
+		local, err := parseIdentifier(words[1])
+		if err != nil {
+			return nil, err
+		}
+		return Local{Identifier: local}, nil
+
+	case len(words) == 2 && words[0] == "end" && words[1] == "function":
+		return FunctionEnd{}, nil
+
+	case len(words) == 5 && words[1] == "[]" && words[3] == "=":
+		// a [] i = v
+		array, err := parseIdentifier(words[0])
+		if err != nil {
+			return nil, err
+		}
+		index, err := parseOperand(words[2])
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseOperand(words[4])
+		if err != nil {
+			return nil, err
+		}
+		return IndexStore{Array: array, Index: index, Value: value}, nil
+	}
+
+	if len(words) < 3 || words[1] != "=" {
+		return nil, fmt.Errorf("unknown instruction %v", line)
+	}
+	destination, err := parseIdentifier(words[0])
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(words) == 5 && words[2] == "call":
+		// x = call f n
+		return parseCall(destination, words[3], words[4])
+
+	case len(words) == 3:
+		// x = a
+		source, err := parseOperand(words[2])
+		if err != nil {
+			return nil, err
+		}
+		return Assign{Destination: destination, Source: source}, nil
+
+	case len(words) == 4:
+		// x = op a
+		operator := Operator(words[2])
+		if operator != Negate && operator != Not {
+			return nil, fmt.Errorf("unknown unary operator %v", operator)
+		}
+		operand, err := parseOperand(words[3])
+		if err != nil {
+			return nil, err
+		}
+		return UnOp{Destination: destination, Operator: operator, Operand: operand}, nil
+
+	case len(words) == 5 && words[3] == "[]":
+		// x = a [] i
+		array, err := parseIdentifier(words[2])
+		if err != nil {
+			return nil, err
+		}
+		index, err := parseOperand(words[4])
+		if err != nil {
+			return nil, err
+		}
+		return IndexLoad{Destination: destination, Array: array, Index: index}, nil
+
+	case len(words) == 5:
+		// x = a op b
+		operator := Operator(words[3])
+		if !isBinaryOperator(operator) {
+			return nil, fmt.Errorf("unknown binary operator %v", operator)
+		}
+		first, err := parseOperand(words[2])
+		if err != nil {
+			return nil, err
+		}
+		second, err := parseOperand(words[4])
+		if err != nil {
+			return nil, err
+		}
+		return BinOp{
+			Destination: destination,
+			Operator:    operator,
+			First:       first,
+			Second:      second,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown instruction %v", line)
+}
+
+func parseCall(result Identifier, function, arguments string) (Instruction, error) {
+	count, err := strconv.Atoi(arguments)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid number of arguments %v", arguments)
+	}
+	call := Call{Result: result, Arguments: count}
+
+	switch Builtin(function) {
+	case Printf, PrintValues, Getchar, Length:
+		call.Function = Builtin(function)
+	default:
+		call.Function, err = parseIdentifier(function)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return call, nil
+}
+
+func isBinaryOperator(operator Operator) bool {
+	switch operator {
+	case Add, Subtract, Multiply, Divide, Modulo,
+		Equals, NotEquals, GreaterThan, GreaterThanOrEqual, LesserThan, LesserThanOrEqual:
+		return true
+	}
+	return false
+}
+
+func parseIdentifier(word string) (Identifier, error) {
+	if !strings.HasPrefix(word, "_t") {
+		return 0, fmt.Errorf("identifier expected, found %v", word)
+	}
+	index, err := strconv.Atoi(word[2:])
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("invalid identifier %v", word)
+	}
+	return Identifier(index), nil
+}
+
+func parseOperand(word string) (Operand, error) {
+	switch {
+	case strings.HasPrefix(word, "_t"):
+		return parseIdentifier(word)
+
+	case strings.HasPrefix(word, "\""):
+		if len(word) < 2 || !strings.HasSuffix(word, "\"") {
+			return nil, fmt.Errorf("unterminated string %v", word)
+		}
+		return Literal{Kind: LiteralString, Value: word}, nil
+
+	case strings.HasPrefix(word, "'"):
+		if len(word) < 3 || !strings.HasSuffix(word, "'") {
+			return nil, fmt.Errorf("invalid character %v", word)
+		}
+		return Literal{Kind: LiteralChar, Value: word}, nil
+
+	case word == "true" || word == "false":
+		return Literal{Kind: LiteralBool, Value: word}, nil
+	}
+
+	if _, err := strconv.ParseInt(word, 10, 64); err == nil {
+		return Literal{Kind: LiteralInt, Value: word}, nil
+	}
+	if _, err := strconv.ParseFloat(word, 64); err == nil {
+		return Literal{Kind: LiteralFloat, Value: word}, nil
+	}
+	return nil, fmt.Errorf("unknown operand %v", word)
+}
+
+// splits a line into its words, keeping string and character literals with spaces together
+func split(line string) []string {
+	words := []string{}
+	current := strings.Builder{}
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case quote != 0 && line[i] == '\\' && i+1 < len(line):
+			current.WriteByte(line[i])
+			i++
+		case quote != 0 && line[i] == quote:
+			quote = 0
+		case quote == 0 && (line[i] == '"' || line[i] == '\''):
+			quote = line[i]
+		case quote == 0 && line[i] == ' ':
+			if current.Len() > 0 {
+				words = append(words, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteByte(line[i])
+	}
+	if current.Len() > 0 {
+		words = append(words, current.String())
+	}
+	return words
+}
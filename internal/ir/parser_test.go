@@ -0,0 +1,124 @@
+package ir
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	codes := []Instruction{
+		FunctionStart{Function: 0, Parameters: []Identifier{1, 2}},
+		Local{Identifier: 3},
+		BinOp{Destination: 3, Operator: Add, First: Identifier(1), Second: Identifier(2)},
+		Return{Value: Identifier(3)},
+		FunctionEnd{},
+		FunctionStart{Function: 4, Parameters: []Identifier{}},
+		Return{},
+		FunctionEnd{},
+
+		Assign{Destination: 5, Source: Literal{Kind: LiteralInt, Value: "-42"}},
+		Assign{Destination: 6, Source: Literal{Kind: LiteralFloat, Value: "2.5"}},
+		Assign{Destination: 6, Source: Literal{Kind: LiteralFloat, Value: "-0.125"}},
+		Assign{Destination: 7, Source: Literal{Kind: LiteralChar, Value: "'a'"}},
+		Assign{Destination: 7, Source: Literal{Kind: LiteralChar, Value: "' '"}},
+		Assign{Destination: 7, Source: Literal{Kind: LiteralChar, Value: `'\''`}},
+		Assign{Destination: 7, Source: Literal{Kind: LiteralChar, Value: `'\n'`}},
+		Assign{Destination: 8, Source: Literal{Kind: LiteralBool, Value: "true"}},
+		Assign{Destination: 8, Source: Literal{Kind: LiteralBool, Value: "false"}},
+		Assign{Destination: 9, Source: Literal{Kind: LiteralString, Value: `"hello, world"`}},
+		Assign{Destination: 9, Source: Literal{Kind: LiteralString, Value: `"a \"quoted\" word\t\\ "`}},
+		Assign{Destination: 9, Source: Literal{Kind: LiteralString, Value: `"  goto L1 = _t3  "`}},
+		Assign{Destination: 9, Source: Literal{Kind: LiteralString, Value: `""`}},
+		Assign{Destination: 10, Source: Identifier(5)},
+
+		BinOp{Destination: 11, Operator: Subtract, First: Identifier(5), Second: Int(1)},
+		BinOp{Destination: 11, Operator: Multiply, First: Int(2), Second: Identifier(5)},
+		BinOp{Destination: 11, Operator: Divide, First: Identifier(5), Second: Int(-3)},
+		BinOp{Destination: 11, Operator: Modulo, First: Identifier(5), Second: Int(7)},
+		BinOp{Destination: 12, Operator: Equals, First: Identifier(9), Second: Literal{
+			Kind: LiteralString, Value: `"a b"`,
+		}},
+		BinOp{Destination: 12, Operator: NotEquals, First: Identifier(7), Second: Literal{
+			Kind: LiteralChar, Value: "' '",
+		}},
+		BinOp{Destination: 12, Operator: GreaterThan, First: Identifier(6), Second: Identifier(5)},
+		BinOp{Destination: 12, Operator: GreaterThanOrEqual, First: Identifier(6), Second: Int(0)},
+		BinOp{Destination: 12, Operator: LesserThan, First: Identifier(6), Second: Int(0)},
+		BinOp{Destination: 12, Operator: LesserThanOrEqual, First: Identifier(6), Second: Int(0)},
+		UnOp{Destination: 13, Operator: Negate, Operand: Identifier(5)},
+		UnOp{Destination: 12, Operator: Not, Operand: Identifier(12)},
+
+		IndexLoad{Destination: 14, Array: 15, Index: Int(2)},
+		IndexLoad{Destination: 14, Array: 15, Index: Identifier(5)},
+		IndexStore{Array: 15, Index: Identifier(5), Value: Int(3)},
+		IndexStore{Array: 15, Index: Int(0), Value: Literal{Kind: LiteralChar, Value: "' '"}},
+
+		Label{Name: "L0"},
+		CondJump{Condition: Identifier(12), Label: "L1"},
+		CondJump{Condition: Literal{Kind: LiteralBool, Value: "true"}, Label: "L0"},
+		Jump{Label: "L0"},
+		Label{Name: "L1"},
+
+		Param{Value: Literal{Kind: LiteralString, Value: `"%lld and %s\n"`}},
+		Param{Value: Identifier(5)},
+		Param{Value: Literal{Kind: LiteralString, Value: `"two words"`}},
+		Call{Result: NoIdentifier, Function: Printf, Arguments: 3},
+		Param{Value: Identifier(6)},
+		Call{Result: NoIdentifier, Function: PrintValues, Arguments: 1},
+		Call{Result: 7, Function: Getchar, Arguments: 0},
+		Param{Value: Identifier(9)},
+		Call{Result: 5, Function: Length, Arguments: 1},
+		Param{Value: Int(1)},
+		Param{Value: Int(2)},
+		Call{Result: 5, Function: Identifier(0), Arguments: 2},
+		Call{Result: NoIdentifier, Function: Identifier(4), Arguments: 0},
+	}
+
+	text := Print(codes)
+	parsed, err := Parse(text)
+	if err != nil {
+		t.Fatalf("Parse: %v\n%v", err, text)
+	}
+	if len(parsed) != len(codes) {
+		t.Fatalf("parsed %v instructions, want %v", len(parsed), len(codes))
+	}
+	for index := range codes {
+		if !reflect.DeepEqual(parsed[index], codes[index]) {
+			t.Errorf("line %v: %q parsed as %#v, want %#v",
+				index+1, codes[index], parsed[index], codes[index])
+		}
+	}
+	if again := Print(parsed); again != text {
+		t.Errorf("printing the parsed instructions gave\n%v\nwant\n%v", again, text)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, line := range []string{
+		"_t1 = ",
+		"_t1 = \"unterminated",
+		"_t1 = _t2 ? _t3",
+		"_tx = 1",
+		"call f -1",
+		"goto",
+		"if _t1 goto",
+		"x = 1",
+		"_t1 = 'ab",
+	} {
+		if instruction, err := ParseInstruction(line); err == nil {
+			t.Errorf("%q: expected an error, got %#v", line, instruction)
+		}
+	}
+}
+
+func TestParseSkipsBlankLines(t *testing.T) {
+	parsed, err := Parse(strings.Join([]string{"", "L0:", "   ", "goto L0", ""}, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Instruction{Label{Name: "L0"}, Jump{Label: "L0"}}
+	if !reflect.DeepEqual(parsed, want) {
+		t.Errorf("got %#v, want %#v", parsed, want)
+	}
+}
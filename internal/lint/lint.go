@@ -0,0 +1,145 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/diagnostics"
+)
+
+// A check for code that compiles but is likely a mistake.
+type Rule struct {
+	// used to enable and disable the rule, and shown as the code of its warnings
+	ID          string
+	Description string
+	check       func(program common.ProgramAST, identifiers []common.IdentifierInformation) []warning
+}
+
+// what a rule found, before it is made into a diagnostic
+type warning struct {
+	span    common.Span
+	message string
+	notes   []common.Note
+}
+
+// all the rules, in the order they run
+var Rules = []Rule{
+	{
+		ID:          "unnecessary-mut",
+		Description: "a variable declared with mut that is never reassigned",
+		check:       checkUnnecessaryMut,
+	},
+	{
+		ID:          "unused-variable",
+		Description: "a variable or parameter that is never read",
+		check:       checkUnusedVariable,
+	},
+	{
+		ID:          "infinite-loop",
+		Description: "a while loop on true that has no way out",
+		check:       checkInfiniteLoop,
+	},
+	{
+		ID:          "char-float-comparison",
+		Description: "a comparison between a char and a float",
+		check:       checkCharFloatComparison,
+	},
+	{
+		ID:          "lossy-cast",
+		Description: "a cast with as that may change the value, such as float as int",
+		check:       checkLossyCast,
+	},
+	{
+		ID:          "self-assignment",
+		Description: "a variable assigned to itself",
+		check:       checkSelfAssignment,
+	},
+}
+
+// whether each rule is enabled, by ID
+type Config map[string]bool
+
+// every rule is enabled by default
+func DefaultConfig() Config {
+	config := Config{}
+	for _, rule := range Rules {
+		config[rule.ID] = true
+	}
+	return config
+}
+
+// Enables or disables the rule, failing if there is no rule with the ID.
+func (c Config) Set(id string, enabled bool) error {
+	if _, ok := c[id]; !ok {
+		return fmt.Errorf("unknown lint rule %v, the rules are %v", id, ruleIDs())
+	}
+	c[id] = enabled
+	return nil
+}
+
+// Applies the rules of a config file on top of the config.
+// The file is JSON, e.g.,
+//
+//	{"rules": {"unused-variable": false, "self-assignment": true}}
+//
+// and the rules that are not in it are left as they are.
+func (c Config) ReadFile(fileName string) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Rules map[string]bool `json:"rules"`
+	}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("invalid lint config %v: %w", fileName, err)
+	}
+	for id, enabled := range file.Rules {
+		if err := c.Set(id, enabled); err != nil {
+			return fmt.Errorf("invalid lint config %v: %w", fileName, err)
+		}
+	}
+	return nil
+}
+
+// Runs the enabled rules on a program that has passed type checking.
+// The warnings are ordered by where they are in the source.
+func Linter(
+	program common.ProgramAST, identifiers []common.IdentifierInformation, config Config,
+) []diagnostics.Diagnostic {
+	found := []diagnostics.Diagnostic{}
+	for _, rule := range Rules {
+		if !config[rule.ID] {
+			continue
+		}
+		for _, warning := range rule.check(program, identifiers) {
+			found = append(found, diagnostics.Diagnostic{
+				Severity: diagnostics.SeverityWarning,
+				Code:     rule.ID,
+				Phase:    "Linter",
+				Message:  warning.message,
+				Span:     warning.span,
+				Notes:    warning.notes,
+			})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Span.StartLine != found[j].Span.StartLine {
+			return found[i].Span.StartLine < found[j].Span.StartLine
+		}
+		return found[i].Span.StartCol < found[j].Span.StartCol
+	})
+	return found
+}
+
+func ruleIDs() string {
+	ids := []string{}
+	for _, rule := range Rules {
+		ids = append(ids, rule.ID)
+	}
+	return strings.Join(ids, ", ")
+}
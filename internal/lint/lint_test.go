@@ -0,0 +1,100 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/diagnostics"
+	"github.com/SamJohn04/simple-lang-compiler/internal/frontend"
+)
+
+// runs the front end and every rule on the source, which must compile
+func lintSource(t *testing.T, source string) []diagnostics.Diagnostic {
+	t.Helper()
+	lex := make(chan common.Token)
+	go frontend.Lexer(strings.NewReader(source), "test.sl", lex)
+	root, err := frontend.Parser(lex, 0)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", source, err)
+	}
+	program, identifiers, err := frontend.SemanticAnalyzer(root)
+	if err != nil {
+		t.Fatalf("lowering %q: %v", source, err)
+	}
+	program, err = frontend.TypeChecker(program, identifiers)
+	if err != nil {
+		t.Fatalf("type checking %q: %v", source, err)
+	}
+	return Linter(program, identifiers, DefaultConfig())
+}
+
+func TestLossyCast(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{"let _c = 300 as u8;", "int as u8 may change the value"},
+		{"let _c = 3.7 as int;", "float as int may change the value"},
+		{"let _c = 1000 as char;", "int as char may change the value"},
+		{"let _c = -2.5 as int;", "float as int may change the value"},
+		{"let _c = -1 as u32;", "int as u32 may change the value"},
+		{"let _c = 2 as bool;", "int as bool may change the value"},
+		{"let _c = 0.1 as f32;", "float as f32 may change the value"},
+		{"let x = 2.5; let _c = x as int;", "float as int may change the value"},
+		{"let x = 300; let _c = x as u8;", "int as u8 may change the value"},
+
+		// the literal keeps its value
+		{"let _c = 65 as char;", ""},
+		{"let _c = 255 as u8;", ""},
+		{"let _c = -128 as i8;", ""},
+		{"let _c = 3.0 as int;", ""},
+		{"let _c = 0.5 as f32;", ""},
+		{"let _c = 1 as bool;", ""},
+		{"let _c = 'A' as u8;", ""},
+		// exact for every value
+		{"let x = 1u8; let _c = x as int;", ""},
+	}
+	for _, test := range tests {
+		found := []string{}
+		for _, warning := range lintSource(t, test.source) {
+			if warning.Code == "lossy-cast" {
+				found = append(found, warning.Message)
+			}
+		}
+		switch {
+		case test.message == "" && len(found) > 0:
+			t.Errorf("%q: unexpected warnings %q", test.source, found)
+		case test.message != "" && (len(found) != 1 || found[0] != test.message):
+			t.Errorf("%q: got %q, want [%q]", test.source, found, test.message)
+		}
+	}
+}
+
+func TestInfiniteLoop(t *testing.T) {
+	tests := []struct {
+		source   string
+		warnings int
+	}{
+		{"while true {};", 1},
+		{"while true { break; };", 0},
+		{"fn f() { while true { return; }; }; f();", 0},
+		// the break leaves only the inner loop
+		{"while true { while true { break; }; };", 1},
+		// the break leaves the inner loop as well as the outer one
+		{"outer: while true { while true { break outer; }; };", 0},
+		{"outer: while true { for i in 0..3 { if i == 1 { break outer; }; }; };", 0},
+		{"outer: while true { while true { while true {}; break outer; }; };", 1},
+	}
+	for _, test := range tests {
+		found := 0
+		for _, warning := range lintSource(t, test.source) {
+			if warning.Code == "infinite-loop" {
+				found++
+			}
+		}
+		if found != test.warnings {
+			t.Errorf("%q: got %v infinite-loop warnings, want %v", test.source, found, test.warnings)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package lint
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+)
+
+func checkUnnecessaryMut(
+	program common.ProgramAST, identifiers []common.IdentifierInformation,
+) []warning {
+	reassigned := map[int]bool{}
+	forEachInstruction(program, func(instruction common.InstructionAST) {
+		if assignment, ok := instruction.(common.AssignmentAST); ok &&
+			!declares(assignment, identifiers) {
+			reassigned[assignment.AssignToIdentifier] = true
+		}
+	})
+
+	warnings := []warning{}
+	for index, identifier := range identifiers {
+		if !identifier.Mutable || identifier.Temporary || reassigned[index] {
+			continue
+		}
+		warnings = append(warnings, warning{
+			span: identifier.Declaration,
+			message: fmt.Sprintf(
+				"%v is declared with mut but never reassigned", identifier.IdentifierName,
+			),
+			notes: []common.Note{{Message: "remove the mut"}},
+		})
+	}
+	return warnings
+}
+
+func checkUnusedVariable(
+	program common.ProgramAST, identifiers []common.IdentifierInformation,
+) []warning {
+	read := map[int]bool{}
+	forEachInstruction(program, func(instruction common.InstructionAST) {
+		for _, expression := range instructionExpressions(instruction) {
+			forEachExpression(expression, func(expression common.ExpressionAST) {
+				if identifier, ok := expression.(common.Identifier); ok {
+					read[identifier.Id] = true
+				}
+			})
+		}
+	})
+
+	warnings := []warning{}
+	for index, identifier := range identifiers {
+		if identifier.Temporary || read[index] || identifier.Declaration.IsZero() ||
+			strings.HasPrefix(identifier.IdentifierName, "_") {
+			continue
+		}
+		if _, ok := identifier.Datatype.(common.FunctionDatatype); ok {
+			continue
+		}
+		warnings = append(warnings, warning{
+			span:    identifier.Declaration,
+			message: fmt.Sprintf("%v is never read", identifier.IdentifierName),
+			notes: []common.Note{
+				{Message: "start the name with _ if this is on purpose"},
+			},
+		})
+	}
+	return warnings
+}
+
+func checkInfiniteLoop(
+	program common.ProgramAST, identifiers []common.IdentifierInformation,
+) []warning {
+	warnings := []warning{}
+	forEachInstruction(program, func(instruction common.InstructionAST) {
+		while, ok := instruction.(common.WhileStatementAST)
+		if !ok || !isLiteral(while.Condition, "true") {
+			return
+		}
+		// a break of a loop inside this one does not leave it,
+		// while a break of this loop or of one around it does
+		inner := map[*common.Loop]bool{}
+		forEachInstruction(while.Program, func(instruction common.InstructionAST) {
+			switch instruction := instruction.(type) {
+			case common.WhileStatementAST:
+				inner[instruction.Loop] = true
+			case common.ForStatementAST:
+				inner[instruction.Loop] = true
+			}
+		})
+		exits := false
+		forEachInstruction(while.Program, func(instruction common.InstructionAST) {
+			switch instruction := instruction.(type) {
+			case common.ReturnAST:
+				exits = true
+			case common.BreakAST:
+				exits = exits || !inner[instruction.Loop]
+			}
+		})
+		if !exits {
+			warnings = append(warnings, warning{
+				span:    while.Condition.GetSpan(),
+				message: "the loop never ends, since its condition is always true and it has no return or break",
+			})
+		}
+	})
+	return warnings
+}
+
+func checkCharFloatComparison(
+	program common.ProgramAST, identifiers []common.IdentifierInformation,
+) []warning {
+	warnings := []warning{}
+	forEachInstruction(program, func(instruction common.InstructionAST) {
+		for _, expression := range instructionExpressions(instruction) {
+			forEachExpression(expression, func(expression common.ExpressionAST) {
+				binary, ok := expression.(common.BinaryExpression)
+				if !ok || !isComparison(binary.Operator) {
+					return
+				}
+				first, err := binary.FirstOperand.GetDatatype(identifiers)
+				if err != nil {
+					return
+				}
+				second, err := binary.SecondOperand.GetDatatype(identifiers)
+				if err != nil {
+					return
+				}
+				if first.IsDatatype(common.TypedChar) && second.IsDatatype(common.TypedFloat) ||
+					first.IsDatatype(common.TypedFloat) && second.IsDatatype(common.TypedChar) {
+					warnings = append(warnings, warning{
+						span:    binary.Span,
+						message: "a char is compared with a float",
+						notes: []common.Note{
+							{Message: "the char is compared by its ascii value"},
+						},
+					})
+				}
+			})
+		}
+	})
+	return warnings
+}
+
+func checkLossyCast(
+	program common.ProgramAST, identifiers []common.IdentifierInformation,
+) []warning {
+	warnings := []warning{}
+	forEachInstruction(program, func(instruction common.InstructionAST) {
+		for _, expression := range instructionExpressions(instruction) {
+			forEachExpression(expression, func(expression common.ExpressionAST) {
+				cast, ok := expression.(common.CastExpression)
+				if !ok {
+					return
+				}
+				datatype, err := cast.Operand.GetDatatype(identifiers)
+				if err != nil ||
+					common.ConversionBetween(datatype, cast.Datatype) != common.ConversionLossy ||
+					literalSurvives(cast.Operand, cast.Datatype) {
+					return
+				}
+				warnings = append(warnings, warning{
+					span: cast.Span,
+					message: fmt.Sprintf(
+						"%v as %v may change the value",
+						common.DatatypeName(datatype), common.DatatypeName(cast.Datatype),
+					),
+					notes: []common.Note{{Message: lossyCastNote(datatype, cast.Datatype)}},
+				})
+			})
+		}
+	})
+	return warnings
+}
+
+// Whether the operand is a literal that keeps its value through the conversion, e.g., 65 as char or 3.0 as int.
+// A literal that does not, such as 300 as u8 or 3.7 as int, is warned about like any other value.
+func literalSurvives(operand common.ExpressionAST, to common.Datatype) bool {
+	negative := false
+	if unary, ok := operand.(common.UnaryExpression); ok && unary.Operator == common.UnaryMinus {
+		operand, negative = unary.Operand, true
+	}
+	literal, ok := operand.(common.Literal)
+	primitive, isPrimitive := to.(common.PrimitiveDatatype)
+	if !ok || !isPrimitive {
+		return false
+	}
+
+	value := literal.Value
+	switch {
+	case literal.Datatype.IsDatatype(common.TypedChar):
+		// only a plain character, whose value is its ascii code, e.g., 'A' is 65
+		if len(value) != 3 || value[1] == '\\' {
+			return false
+		}
+		value = strconv.Itoa(int(value[1]))
+
+	case literal.Datatype.IsDatatype(common.TypedFloat), literal.Datatype.IsDatatype(common.TypedF32):
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		if primitive == common.TypedF32 {
+			return float64(float32(number)) == number
+		}
+		if number != math.Trunc(number) || number >= math.MaxInt64 {
+			return false
+		}
+		value = strconv.FormatInt(int64(number), 10)
+	}
+
+	if primitive == common.TypedBool {
+		// 0 and 1 are false and true
+		return value == "0" || value == "1" && !negative
+	}
+	return common.LiteralFits(value, negative, primitive)
+}
+
+// what happens to the values that do not fit
+func lossyCastNote(from common.Datatype, to common.Datatype) string {
+	switch {
+	case to.IsDatatype(common.TypedBool):
+		return "every value other than 0 becomes true"
+	case to.IsDatatype(common.TypedF32):
+		return "the value is rounded to the nearest f32"
+	case from.IsDatatype(common.TypedFloat), from.IsDatatype(common.TypedF32):
+		return "the fraction is dropped"
+	}
+	bits := 8
+	if primitive, ok := to.(common.PrimitiveDatatype); ok && primitive.IsSized() {
+		bits = primitive.Bits()
+	}
+	return fmt.Sprintf("only the lowest %v bits are kept", bits)
+}
+
+func checkSelfAssignment(
+	program common.ProgramAST, identifiers []common.IdentifierInformation,
+) []warning {
+	warnings := []warning{}
+	forEachInstruction(program, func(instruction common.InstructionAST) {
+		assignment, ok := instruction.(common.AssignmentAST)
+		if !ok || len(assignment.ArrayValues) > 0 || declares(assignment, identifiers) {
+			return
+		}
+		value, ok := assignment.AssignValue.(common.Identifier)
+		if ok && value.Id == assignment.AssignToIdentifier && len(value.ArrayValues) == 0 {
+			warnings = append(warnings, warning{
+				span: assignment.Span,
+				message: fmt.Sprintf(
+					"%v is assigned to itself", identifiers[value.Id].IdentifierName,
+				),
+			})
+		}
+	})
+	return warnings
+}
+
+// whether the assignment is the let that declares the identifier, rather than a reassignment
+func declares(assignment common.AssignmentAST, identifiers []common.IdentifierInformation) bool {
+	declaration := identifiers[assignment.AssignToIdentifier].Declaration
+	return declaration.StartLine == assignment.Span.StartLine &&
+		declaration.StartCol >= assignment.Span.StartCol &&
+		declaration.EndLine <= assignment.Span.EndLine
+}
+
+func isLiteral(expression common.ExpressionAST, value string) bool {
+	literal, ok := expression.(common.Literal)
+	return ok && literal.Value == value
+}
+
+func isComparison(operator common.BinaryOperatorNode) bool {
+	switch operator {
+	case common.BinaryRelationalEquals,
+		common.BinaryRelationalNotEquals,
+		common.BinaryRelationalGreaterThan,
+		common.BinaryRelationalGreaterThanOrEquals,
+		common.BinaryRelationalLesserThan,
+		common.BinaryRelationalLesserThanOrEquals:
+		return true
+	}
+	return false
+}
+
+// calls visit on every instruction, including those in blocks and function bodies
+func forEachInstruction(program common.ProgramAST, visit func(instruction common.InstructionAST)) {
+	for _, instruction := range program.Instructions {
+		visit(instruction)
+		switch instruction := instruction.(type) {
+		case common.IfStatementAST:
+			for _, ifExpression := range instruction.IfExpressions {
+				forEachInstruction(ifExpression.Program, visit)
+			}
+		case common.WhileStatementAST:
+			forEachInstruction(instruction.Program, visit)
+		case common.ForStatementAST:
+			forEachInstruction(instruction.Program, visit)
+		case common.FunctionAST:
+			forEachInstruction(instruction.Program, visit)
+		}
+	}
+}
+
+// the expressions the instruction reads, not including those in its blocks
+func instructionExpressions(instruction common.InstructionAST) []common.ExpressionAST {
+	switch instruction := instruction.(type) {
+	case common.AssignmentAST:
+		return append([]common.ExpressionAST{instruction.AssignValue}, instruction.ArrayValues...)
+	case common.IfStatementAST:
+		conditions := []common.ExpressionAST{}
+		for _, ifExpression := range instruction.IfExpressions {
+			conditions = append(conditions, ifExpression.Condition)
+		}
+		return conditions
+	case common.WhileStatementAST:
+		return []common.ExpressionAST{instruction.Condition}
+	case common.ForStatementAST:
+		if instruction.Array != nil {
+			return []common.ExpressionAST{instruction.Array}
+		}
+		expressions := []common.ExpressionAST{instruction.Start, instruction.End}
+		if instruction.Step != nil {
+			expressions = append(expressions, instruction.Step)
+		}
+		return expressions
+	case common.OutputStatementAST:
+		return instruction.Arguments
+	case common.ReturnAST:
+		if instruction.Value != nil {
+			return []common.ExpressionAST{instruction.Value}
+		}
+	case common.CallStatementAST:
+		return []common.ExpressionAST{instruction.Call}
+	}
+	return []common.ExpressionAST{}
+}
+
+// calls visit on the expression and every expression inside it
+func forEachExpression(expression common.ExpressionAST, visit func(expression common.ExpressionAST)) {
+	visit(expression)
+	switch expression := expression.(type) {
+	case common.UnaryExpression:
+		forEachExpression(expression.Operand, visit)
+	case common.BinaryExpression:
+		forEachExpression(expression.FirstOperand, visit)
+		forEachExpression(expression.SecondOperand, visit)
+	case common.CallExpression:
+		for _, argument := range expression.Arguments {
+			forEachExpression(argument, visit)
+		}
+	case common.LengthExpression:
+		forEachExpression(expression.Operand, visit)
+	case common.CastExpression:
+		forEachExpression(expression.Operand, visit)
+	case common.ArrayExpression:
+		for _, element := range expression.Elements {
+			forEachExpression(element, visit)
+		}
+	case common.Identifier:
+		for _, index := range expression.ArrayValues {
+			forEachExpression(index, visit)
+		}
+	}
+}
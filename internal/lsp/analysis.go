@@ -0,0 +1,185 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/diagnostics"
+	"github.com/SamJohn04/simple-lang-compiler/internal/frontend"
+)
+
+// what the front end knows about a document
+type analysis struct {
+	diagnostics []diagnostics.Diagnostic
+	identifiers []common.IdentifierInformation
+	// the span of every function, since the variables outside one are not visible in it
+	functions []common.Span
+}
+
+// Runs the parser, semantic analyzer and type checker on the text.
+// The identifiers are kept even if a pass fails, as far as the pass got.
+func analyze(uri string, text string) (result analysis) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = analysis{diagnostics: diagnostics.FromErrors(&common.InternalError{
+				PointOfFailure: "Language Server",
+				Message:        fmt.Sprint(r),
+			})}
+		}
+	}()
+
+	lex := make(chan common.Token)
+	go frontend.Lexer(strings.NewReader(text), uri, lex)
+	// no limit, so that the whole document is read
+	root, syntaxErr := frontend.Parser(lex, 0)
+
+	program, identifiers, err := frontend.SemanticAnalyzer(root)
+	if errors.Is(err, frontend.ErrSkippedDeclaration) {
+		// the syntax error that left out the declaration is reported instead
+		err = nil
+	} else if err == nil {
+		_, err = frontend.TypeChecker(program, identifiers)
+	}
+	result.identifiers = identifiers
+	for _, instruction := range program.Instructions {
+		if function, ok := instruction.(common.FunctionAST); ok {
+			result.functions = append(result.functions, function.Span)
+		}
+	}
+	if err = errors.Join(syntaxErr, err); err != nil {
+		result.diagnostics = diagnostics.FromErrors(err)
+	}
+	return result
+}
+
+// the identifier declared or used at the (1-based) line and byte column
+func (a analysis) identifierAt(line int, column int) (common.IdentifierInformation, common.Span, bool) {
+	for _, identifier := range a.identifiers {
+		if identifier.Temporary {
+			continue
+		}
+		if spanContains(identifier.Declaration, line, column) {
+			return identifier, identifier.Declaration, true
+		}
+		for _, use := range identifier.Uses {
+			if spanContains(use, line, column) {
+				return identifier, use, true
+			}
+		}
+	}
+	return common.IdentifierInformation{}, common.Span{}, false
+}
+
+// the identifiers that can be named at the line and column, the innermost first
+func (a analysis) identifiersInScope(line int, column int) []common.IdentifierInformation {
+	visible := []common.IdentifierInformation{}
+	seen := map[string]bool{}
+	for index := len(a.identifiers) - 1; index >= 0; index-- {
+		identifier := a.identifiers[index]
+		if identifier.Temporary || identifier.Declaration.IsZero() || seen[identifier.IdentifierName] {
+			continue
+		}
+		if !spanBefore(identifier.Declaration, line, column) {
+			continue
+		}
+		if !identifier.Scope.IsZero() && !spanContains(identifier.Scope, line, column) {
+			continue
+		}
+		_, isFunction := identifier.Datatype.(common.FunctionDatatype)
+		if !isFunction && !a.sameFunction(identifier.Declaration, line, column) {
+			continue
+		}
+		seen[identifier.IdentifierName] = true
+		visible = append(visible, identifier)
+	}
+	return visible
+}
+
+// whether the declaration and the line and column are in the same function, or both outside all of them
+func (a analysis) sameFunction(declaration common.Span, line int, column int) bool {
+	for _, function := range a.functions {
+		inside := spanContains(function, line, column)
+		if inside != spanContains(function, declaration.StartLine, declaration.StartCol) {
+			return false
+		}
+	}
+	return true
+}
+
+// e.g., "let mut x: int" or "fn add(int, int) -> int"
+func describe(identifier common.IdentifierInformation) string {
+	datatype := common.DatatypeName(identifier.Datatype)
+	if _, ok := identifier.Datatype.(common.FunctionDatatype); ok {
+		return "fn " + identifier.IdentifierName + strings.TrimPrefix(datatype, "fn")
+	}
+	if identifier.Mutable {
+		return "let mut " + identifier.IdentifierName + ": " + datatype
+	}
+	return "let " + identifier.IdentifierName + ": " + datatype
+}
+
+func spanContains(span common.Span, line int, column int) bool {
+	if span.IsZero() || line < span.StartLine || line > span.EndLine {
+		return false
+	}
+	if line == span.StartLine && column < span.StartCol {
+		return false
+	}
+	// the end column is that of the last byte, and the cursor may be just after it
+	return line != span.EndLine || column <= span.EndCol+1
+}
+
+func spanBefore(span common.Span, line int, column int) bool {
+	return span.EndLine < line || (span.EndLine == line && span.EndCol < column)
+}
+
+// Spans count lines from 1 and columns in bytes from 1,
+// while the protocol counts both from 0 and columns in UTF-16 code units.
+
+func toRange(lines []string, span common.Span) lspRange {
+	return lspRange{
+		Start: toPosition(lines, span.StartLine, span.StartCol),
+		// the protocol's end is exclusive
+		End: toPosition(lines, span.EndLine, span.EndCol+1),
+	}
+}
+
+func toPosition(lines []string, line int, column int) position {
+	if line < 1 {
+		return position{}
+	}
+	text := lineText(lines, line)
+	column = min(max(column-1, 0), len(text))
+	return position{
+		Line:      line - 1,
+		Character: len(utf16.Encode([]rune(text[:column]))),
+	}
+}
+
+// the line and byte column, both from 1
+func fromPosition(lines []string, at position) (int, int) {
+	text := lineText(lines, at.Line+1)
+	units := 0
+	for offset, character := range text {
+		if units >= at.Character {
+			return at.Line + 1, offset + 1
+		}
+		// runes outside the basic multilingual plane take a surrogate pair
+		if character > 0xFFFF {
+			units += 2
+		} else {
+			units++
+		}
+	}
+	return at.Line + 1, len(text) + 1
+}
+
+func lineText(lines []string, line int) string {
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[line-1], "\r")
+}
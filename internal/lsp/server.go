@@ -0,0 +1,302 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/SamJohn04/simple-lang-compiler/internal/common"
+	"github.com/SamJohn04/simple-lang-compiler/internal/diagnostics"
+)
+
+// the words the lexer reserves
+var keywords = []string{
+	"let", "mut", "fn", "return", "if", "else", "while", "for", "in", "step", "break", "continue", "as",
+	"printf", "print", "println", "getchar", "len", "true", "false", "int", "float", "char", "bool",
+	"i8", "i16", "i32", "i64", "u8", "u16", "u32", "u64", "f32",
+}
+
+type document struct {
+	lines    []string
+	analysis analysis
+}
+
+type server struct {
+	output    io.Writer
+	documents map[string]*document
+	// set once the client asks to shut down, so that exit is clean
+	shutdown bool
+}
+
+// Serves the Language Server Protocol over the reader and writer, e.g., stdin and stdout,
+// until the client sends exit.
+// Only full document synchronisation is supported.
+func Serve(input io.Reader, output io.Writer) error {
+	s := &server{output: output, documents: map[string]*document{}}
+	reader := bufio.NewReader(input)
+	for {
+		content, err := readMessage(reader)
+		if err == io.EOF {
+			return errors.New("the client closed the connection without exit")
+		}
+		if err != nil {
+			return err
+		}
+
+		var message requestMessage
+		if err := json.Unmarshal(content, &message); err != nil {
+			if err := s.respondError(nil, errorParse, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if message.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("the client exited without shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(message); err != nil {
+			return err
+		}
+	}
+}
+
+// only errors in writing the output are returned, since the connection is lost then
+func (s *server) handle(message requestMessage) error {
+	switch message.Method {
+	case "initialize":
+		return s.respond(message.ID, map[string]any{
+			"capabilities": map[string]any{
+				// the whole document is sent on every change
+				"textDocumentSync":   1,
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"referencesProvider": true,
+				"completionProvider": map[string]any{},
+			},
+			"serverInfo": map[string]any{"name": "slc"},
+		})
+
+	case "shutdown":
+		s.shutdown = true
+		return s.respond(message.ID, nil)
+
+	case "textDocument/didOpen":
+		var params didOpenTextDocumentParams
+		if json.Unmarshal(message.Params, &params) != nil {
+			return nil
+		}
+		return s.update(params.TextDocument.URI, params.TextDocument.Text)
+
+	case "textDocument/didChange":
+		var params didChangeTextDocumentParams
+		if json.Unmarshal(message.Params, &params) != nil || len(params.ContentChanges) == 0 {
+			return nil
+		}
+		return s.update(
+			params.TextDocument.URI,
+			params.ContentChanges[len(params.ContentChanges)-1].Text,
+		)
+
+	case "textDocument/didClose":
+		var params didCloseTextDocumentParams
+		if json.Unmarshal(message.Params, &params) != nil {
+			return nil
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []lspDiagnostic{},
+		})
+
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(message.Params, &params); err != nil {
+			return s.respondError(message.ID, errorInvalidParams, err.Error())
+		}
+		return s.respond(message.ID, s.hover(params))
+
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(message.Params, &params); err != nil {
+			return s.respondError(message.ID, errorInvalidParams, err.Error())
+		}
+		return s.respond(message.ID, s.definition(params))
+
+	case "textDocument/references":
+		var params referenceParams
+		if err := json.Unmarshal(message.Params, &params); err != nil {
+			return s.respondError(message.ID, errorInvalidParams, err.Error())
+		}
+		return s.respond(message.ID, s.references(params))
+
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(message.Params, &params); err != nil {
+			return s.respondError(message.ID, errorInvalidParams, err.Error())
+		}
+		return s.respond(message.ID, s.completion(params))
+	}
+
+	// notifications that are not understood are ignored, e.g., initialized
+	if message.ID == nil {
+		return nil
+	}
+	return s.respondError(message.ID, errorMethodNotFound, "unsupported method "+message.Method)
+}
+
+// analyzes the new text and publishes what was found
+func (s *server) update(uri string, text string) error {
+	current := &document{
+		lines:    strings.Split(text, "\n"),
+		analysis: analyze(uri, text),
+	}
+	s.documents[uri] = current
+
+	published := []lspDiagnostic{}
+	for _, diagnostic := range current.analysis.diagnostics {
+		published = append(published, s.toLSPDiagnostic(current, diagnostic))
+	}
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: published,
+	})
+}
+
+func (s *server) toLSPDiagnostic(current *document, diagnostic diagnostics.Diagnostic) lspDiagnostic {
+	message := diagnostic.Message
+	related := []diagnosticRelatedInformation{}
+	for _, note := range diagnostic.Notes {
+		if note.Span.IsZero() {
+			message += "\nnote: " + note.Message
+			continue
+		}
+		related = append(related, diagnosticRelatedInformation{
+			Location: s.toLocation(note.Span),
+			Message:  note.Message,
+		})
+	}
+	severity := 1
+	if diagnostic.Severity == diagnostics.SeverityWarning {
+		severity = 2
+	}
+	return lspDiagnostic{
+		// errors without a position are shown at the start of the document
+		Range:              toRange(current.lines, diagnostic.Span),
+		Severity:           severity,
+		Code:               diagnostic.Code,
+		Source:             "slc",
+		Message:            message,
+		RelatedInformation: related,
+	}
+}
+
+func (s *server) hover(params textDocumentPositionParams) any {
+	current, line, column, ok := s.at(params)
+	if !ok {
+		return nil
+	}
+	identifier, span, ok := current.analysis.identifierAt(line, column)
+	if !ok {
+		return nil
+	}
+	return hover{
+		Contents: markupContent{
+			Kind:  "markdown",
+			Value: "```\n" + describe(identifier) + "\n```",
+		},
+		Range: toRange(current.lines, span),
+	}
+}
+
+func (s *server) definition(params textDocumentPositionParams) any {
+	current, line, column, ok := s.at(params)
+	if !ok {
+		return nil
+	}
+	identifier, _, ok := current.analysis.identifierAt(line, column)
+	if !ok || identifier.Declaration.IsZero() {
+		return nil
+	}
+	return s.toLocation(identifier.Declaration)
+}
+
+func (s *server) references(params referenceParams) []location {
+	locations := []location{}
+	current, line, column, ok := s.at(params.textDocumentPositionParams)
+	if !ok {
+		return locations
+	}
+	identifier, _, ok := current.analysis.identifierAt(line, column)
+	if !ok {
+		return locations
+	}
+	if params.Context.IncludeDeclaration && !identifier.Declaration.IsZero() {
+		locations = append(locations, s.toLocation(identifier.Declaration))
+	}
+	for _, use := range identifier.Uses {
+		locations = append(locations, s.toLocation(use))
+	}
+	return locations
+}
+
+func (s *server) completion(params textDocumentPositionParams) []completionItem {
+	items := []completionItem{}
+	for _, keyword := range keywords {
+		items = append(items, completionItem{Label: keyword, Kind: completionKeyword})
+	}
+	current, line, column, ok := s.at(params)
+	if !ok {
+		return items
+	}
+	for _, identifier := range current.analysis.identifiersInScope(line, column) {
+		kind := completionVariable
+		if _, ok := identifier.Datatype.(common.FunctionDatatype); ok {
+			kind = completionFunction
+		}
+		items = append(items, completionItem{
+			Label:  identifier.IdentifierName,
+			Kind:   kind,
+			Detail: describe(identifier),
+		})
+	}
+	return items
+}
+
+// the document, and the position in it as a line and byte column
+func (s *server) at(params textDocumentPositionParams) (*document, int, int, bool) {
+	current, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, 0, 0, false
+	}
+	line, column := fromPosition(current.lines, params.Position)
+	return current, line, column, true
+}
+
+// spans carry the uri of their document, since it is given to the lexer as the file name
+func (s *server) toLocation(span common.Span) location {
+	var lines []string
+	if current, ok := s.documents[span.File]; ok {
+		lines = current.lines
+	}
+	return location{URI: span.File, Range: toRange(lines, span)}
+}
+
+func (s *server) respond(id *json.RawMessage, result any) error {
+	return writeMessage(s.output, responseMessage{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *server) respondError(id *json.RawMessage, code int, message string) error {
+	return writeMessage(s.output, errorMessage{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   responseError{Code: code, Message: message},
+	})
+}
+
+func (s *server) notify(method string, params any) error {
+	return writeMessage(s.output, notificationMessage{JSONRPC: "2.0", Method: method, Params: params})
+}
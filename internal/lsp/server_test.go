@@ -0,0 +1,295 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.sl"
+
+// a message written by the server, with the fields of requests, responses and notifications
+type received struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int            `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Result  json.RawMessage `json:"result"`
+	Error   *responseError  `json:"error"`
+}
+
+// frames each message with its Content-Length header, as a client would
+func script(t *testing.T, messages ...any) *bytes.Buffer {
+	t.Helper()
+	input := &bytes.Buffer{}
+	for _, message := range messages {
+		if err := writeMessage(input, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return input
+}
+
+func request(id int, method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notification(method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func positionParams(line int, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": testURI},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+// Splits the output into its messages, checking that each is framed by a Content-Length header
+// with the exact length of its JSON body.
+func readOutput(t *testing.T, output []byte) []received {
+	t.Helper()
+	messages := []received{}
+	for len(output) > 0 {
+		header, rest, ok := bytes.Cut(output, []byte("\r\n\r\n"))
+		if !ok {
+			t.Fatalf("message without the end of its header: %q", output)
+		}
+		value, ok := strings.CutPrefix(string(header), "Content-Length: ")
+		if !ok {
+			t.Fatalf("header %q is not a Content-Length", header)
+		}
+		length, err := strconv.Atoi(value)
+		if err != nil || length > len(rest) {
+			t.Fatalf("Content-Length %q does not fit the %v bytes left", value, len(rest))
+		}
+		body := rest[:length]
+		var message received
+		if err := json.Unmarshal(body, &message); err != nil {
+			t.Fatalf("body %q is not JSON: %v", body, err)
+		}
+		if message.JSONRPC != "2.0" {
+			t.Errorf("message %s is not JSON-RPC 2.0", body)
+		}
+		messages = append(messages, message)
+		output = rest[length:]
+	}
+	return messages
+}
+
+// the response to the request with the id, failing if there is not exactly one
+func response(t *testing.T, messages []received, id int) received {
+	t.Helper()
+	found := []received{}
+	for _, message := range messages {
+		if message.ID != nil && *message.ID == id && message.Method == "" {
+			found = append(found, message)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("%v responses to request %v", len(found), id)
+	}
+	if found[0].Error != nil {
+		t.Fatalf("request %v failed: %v", id, found[0].Error.Message)
+	}
+	return found[0]
+}
+
+func published(t *testing.T, messages []received) []publishDiagnosticsParams {
+	t.Helper()
+	found := []publishDiagnosticsParams{}
+	for _, message := range messages {
+		if message.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		if message.ID != nil {
+			t.Errorf("the notification %v has an id", message.Method)
+		}
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(message.Params, &params); err != nil {
+			t.Fatal(err)
+		}
+		found = append(found, params)
+	}
+	return found
+}
+
+func decode[T any](t *testing.T, message received) T {
+	t.Helper()
+	var result T
+	if err := json.Unmarshal(message.Result, &result); err != nil {
+		t.Fatalf("result %s: %v", message.Result, err)
+	}
+	return result
+}
+
+func TestSession(t *testing.T) {
+	text := "let x = 5;\nlet y = x + true;\nprintln(x);\n"
+	fixed := "let x = 5;\nlet y = x + 1;\nprintln(y);\n"
+	input := script(t,
+		request(1, "initialize", map[string]any{"capabilities": map[string]any{}}),
+		notification("initialized", map[string]any{}),
+		notification("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{
+				"uri": testURI, "languageId": "sl", "version": 1, "text": text,
+			},
+		}),
+		// on the x of x + true
+		request(2, "textDocument/hover", positionParams(1, 8)),
+		request(3, "textDocument/definition", positionParams(2, 8)),
+		request(4, "textDocument/references", map[string]any{
+			"textDocument": map[string]any{"uri": testURI},
+			"position":     map[string]any{"line": 0, "character": 4},
+			"context":      map[string]any{"includeDeclaration": true},
+		}),
+		request(5, "textDocument/completion", positionParams(2, 0)),
+		notification("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": testURI, "version": 2},
+			"contentChanges": []any{map[string]any{"text": fixed}},
+		}),
+		request(6, "textDocument/hover", positionParams(2, 8)),
+		request(7, "textDocument/unknown", map[string]any{}),
+		request(8, "shutdown", nil),
+		notification("exit", nil),
+	)
+	output := &bytes.Buffer{}
+	if err := Serve(input, output); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	messages := readOutput(t, output.Bytes())
+
+	initialize := decode[struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}](t, response(t, messages, 1))
+	for _, capability := range []string{
+		"textDocumentSync", "hoverProvider", "definitionProvider", "referencesProvider", "completionProvider",
+	} {
+		if _, ok := initialize.Capabilities[capability]; !ok {
+			t.Errorf("initialize does not advertise %v", capability)
+		}
+	}
+
+	diagnostics := published(t, messages)
+	if len(diagnostics) != 2 {
+		t.Fatalf("got %v publishDiagnostics, want one for didOpen and one for didChange", len(diagnostics))
+	}
+	opened := diagnostics[0]
+	if opened.URI != testURI || len(opened.Diagnostics) != 1 {
+		t.Fatalf("didOpen published %+v, want one error for %v", opened, testURI)
+	}
+	mismatch := opened.Diagnostics[0]
+	if mismatch.Severity != 1 || mismatch.Source != "slc" || mismatch.Code == "" ||
+		mismatch.Range.Start.Line != 1 {
+		t.Errorf("unexpected diagnostic %+v", mismatch)
+	}
+	if len(diagnostics[1].Diagnostics) != 0 {
+		t.Errorf("didChange to a correct program published %+v", diagnostics[1].Diagnostics)
+	}
+
+	hovered := decode[hover](t, response(t, messages, 2))
+	if hovered.Contents.Kind != "markdown" || !strings.Contains(hovered.Contents.Value, "let x: int") {
+		t.Errorf("hover on x gave %+v", hovered.Contents)
+	}
+	if hovered.Range != (lspRange{Start: position{1, 8}, End: position{1, 9}}) {
+		t.Errorf("hover range %+v", hovered.Range)
+	}
+
+	definition := decode[location](t, response(t, messages, 3))
+	want := location{URI: testURI, Range: lspRange{Start: position{0, 4}, End: position{0, 5}}}
+	if definition != want {
+		t.Errorf("definition of x is %+v, want %+v", definition, want)
+	}
+
+	references := decode[[]location](t, response(t, messages, 4))
+	lines := []int{}
+	for _, reference := range references {
+		lines = append(lines, reference.Range.Start.Line)
+	}
+	if fmt.Sprint(lines) != "[0 1 2]" {
+		t.Errorf("references of x are on the lines %v, want [0 1 2]", lines)
+	}
+
+	completion := decode[[]completionItem](t, response(t, messages, 5))
+	labels := map[string]int{}
+	for _, item := range completion {
+		labels[item.Label] = item.Kind
+	}
+	if labels["x"] != completionVariable || labels["let"] != completionKeyword {
+		t.Errorf("completion is missing x or let: %+v", completion)
+	}
+
+	// the hover is of the changed document, where y is printed
+	changed := decode[hover](t, response(t, messages, 6))
+	if !strings.Contains(changed.Contents.Value, "let y: int") {
+		t.Errorf("hover after didChange gave %+v", changed.Contents)
+	}
+
+	unknown := []received{}
+	for _, message := range messages {
+		if message.ID != nil && *message.ID == 7 {
+			unknown = append(unknown, message)
+		}
+	}
+	if len(unknown) != 1 || unknown[0].Error == nil || unknown[0].Error.Code != errorMethodNotFound {
+		t.Errorf("unknown method gave %+v", unknown)
+	}
+
+	if result := response(t, messages, 8).Result; string(result) != "null" {
+		t.Errorf("shutdown returned %s, want null", result)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	input := script(t, request(1, "initialize", map[string]any{}), notification("exit", nil))
+	if err := Serve(input, &bytes.Buffer{}); err == nil {
+		t.Error("exit without shutdown should be an error")
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		input string
+		body  string
+		fails bool
+	}{
+		{input: "Content-Length: 2\r\n\r\n{}", body: "{}"},
+		// other headers are skipped, and the name is not case sensitive
+		{input: "Content-Type: application/json\r\ncontent-length: 2\r\n\r\n{}", body: "{}"},
+		// only Content-Length bytes are read
+		{input: "Content-Length: 2\r\n\r\n{}{}", body: "{}"},
+		{input: "\r\n{}", fails: true},
+		{input: "Content-Length: two\r\n\r\n{}", fails: true},
+		{input: "Content-Length 2\r\n\r\n{}", fails: true},
+		{input: "Content-Length: 5\r\n\r\n{}", fails: true},
+	}
+	for _, test := range tests {
+		body, err := readMessage(bufio.NewReader(strings.NewReader(test.input)))
+		if test.fails {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %q", test.input, body)
+			}
+			continue
+		}
+		if err != nil || string(body) != test.body {
+			t.Errorf("%q: got %q, %v, want %q", test.input, body, err, test.body)
+		}
+	}
+}
+
+func TestMalformedJSON(t *testing.T) {
+	input := &bytes.Buffer{}
+	fmt.Fprintf(input, "Content-Length: 7\r\n\r\n{broken")
+	input.Write(script(t, request(1, "shutdown", nil), notification("exit", nil)).Bytes())
+	output := &bytes.Buffer{}
+	if err := Serve(input, output); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	messages := readOutput(t, output.Bytes())
+	if len(messages) != 2 || messages[0].Error == nil || messages[0].Error.Code != errorParse {
+		t.Errorf("malformed JSON gave %+v", messages)
+	}
+}
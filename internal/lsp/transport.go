@@ -0,0 +1,55 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Every message is a header, a blank line and a JSON body, e.g.,
+//
+//	Content-Length: 52\r\n
+//	\r\n
+//	{"jsonrpc":"2.0","id":1,"method":"shutdown"}
+func readMessage(reader *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed header %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("malformed Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without a Content-Length")
+	}
+
+	content := make([]byte, length)
+	_, err := io.ReadFull(reader, content)
+	return content, err
+}
+
+func writeMessage(writer io.Writer, message any) error {
+	content, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "Content-Length: %v\r\n\r\n%s", len(content), content)
+	return err
+}